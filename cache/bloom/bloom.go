@@ -0,0 +1,143 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+)
+
+const (
+	DefaultFalsePositiveRate = 0.01
+
+	// maxBits is the size limit of a redis string, 512MB.
+	maxBits = 1 << 32
+)
+
+type (
+	// Option size a Filter for ExpectedItems with the wanted false positive
+	// rate, adding more items than expected raise the false positive rate.
+	Option struct {
+		ExpectedItems     uint64
+		FalsePositiveRate float64
+	}
+
+	// Filter is a bloom filter stored in a redis bitmap with SETBIT and
+	// GETBIT, so it does not need the RedisBloom module. Exists never
+	// return false for an added item, it may return true for an item that
+	// was not added.
+	Filter interface {
+		// Add return true when the item was not in the filter yet.
+		Add(item string) (bool, error)
+		Exists(item string) (bool, error)
+
+		Bits() uint64
+		Hashes() int
+	}
+
+	filter struct {
+		cache  cache.Cache
+		key    string
+		bits   uint64
+		hashes int
+	}
+)
+
+func New(c cache.Cache, key string, option *Option) (Filter, error) {
+	if c == nil {
+		return nil, errors.New("cache is required!")
+	}
+
+	if key == "" {
+		return nil, errors.New("key is required!")
+	}
+
+	if option.ExpectedItems == 0 {
+		return nil, errors.New("expected items is required!")
+	}
+
+	rate := option.FalsePositiveRate
+	if rate <= 0 || rate >= 1 {
+		rate = DefaultFalsePositiveRate
+	}
+
+	bits, hashes := size(option.ExpectedItems, rate)
+	if bits > maxBits {
+		return nil, errors.Errorf("filter of %d bits exceed the redis string limit", bits)
+	}
+
+	return &filter{cache: c, key: key, bits: bits, hashes: hashes}, nil
+}
+
+func (f *filter) Add(item string) (bool, error) {
+	previous, err := f.cache.SetBits(f.key, f.offsets(item)...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to add item to filter %s", f.key)
+	}
+
+	for _, set := range previous {
+		if !set {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (f *filter) Exists(item string) (bool, error) {
+	values, err := f.cache.GetBits(f.key, f.offsets(item)...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check item in filter %s", f.key)
+	}
+
+	for _, set := range values {
+		if !set {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (f *filter) Bits() uint64 {
+	return f.bits
+}
+
+func (f *filter) Hashes() int {
+	return f.hashes
+}
+
+// - private
+
+// offsets derive the k bit offsets from the two halves of a 128 bits hash
+// (Kirsch-Mitzenmacher double hashing).
+func (f *filter) offsets(item string) []int64 {
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(item))
+	sum := h.Sum(nil)
+
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:])
+
+	offsets := make([]int64, f.hashes)
+	for i := range offsets {
+		offsets[i] = int64((h1 + uint64(i)*h2) % f.bits)
+	}
+
+	return offsets
+}
+
+// size return the optimal bit count m = -n ln(p) / ln(2)^2 and hash count
+// k = m/n ln(2).
+func size(n uint64, p float64) (uint64, int) {
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return uint64(m), k
+}
@@ -0,0 +1,58 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redistest"
+)
+
+func Test_Size(t *testing.T) {
+	bits, hashes := size(1000, 0.01)
+
+	assert2.Equal(t, uint64(9586), bits)
+	assert2.Equal(t, 7, hashes)
+}
+
+func Test_Filter(t *testing.T) {
+	_, c := redistest.NewClient(t)
+
+	f, err := New(c, "visitors", &Option{ExpectedItems: 1000, FalsePositiveRate: 0.01})
+	assert2.NoError(t, err)
+
+	t.Run("when item is added", func(t *testing.T) {
+		added, err := f.Add("user:0")
+		assert2.NoError(t, err)
+		assert2.True(t, added)
+
+		added, err = f.Add("user:0")
+		assert2.NoError(t, err)
+		assert2.False(t, added)
+	})
+
+	t.Run("when filter hold the expected items", func(t *testing.T) {
+		for i := 0; i < 1000; i++ {
+			_, err := f.Add(fmt.Sprintf("user:%d", i))
+			assert2.NoError(t, err)
+		}
+
+		for i := 0; i < 1000; i++ {
+			exists, err := f.Exists(fmt.Sprintf("user:%d", i))
+			assert2.NoError(t, err)
+			assert2.True(t, exists)
+		}
+
+		falsePositives := 0
+		for i := 0; i < 1000; i++ {
+			exists, err := f.Exists(fmt.Sprintf("guest:%d", i))
+			assert2.NoError(t, err)
+			if exists {
+				falsePositives++
+			}
+		}
+
+		assert2.Less(t, falsePositives, 30)
+	})
+}
@@ -23,6 +23,15 @@ type (
 		Close() error
 	}
 
+	// Subscriber is implemented by the caches able to open a pubsub of its
+	// own, Cache.Subscribe share one pubsub per channel between its callers
+	// so closing it end the subscription of all of them. The pubsub
+	// subscribe to every channel on a single connection, Publish publish
+	// to the first one.
+	Subscriber interface {
+		NewPubSub(channels ...string) (PubSub, error)
+	}
+
 	Cache interface {
 		util.Ping
 		SetWithExpiration(string, interface{}, time.Duration) error
@@ -61,8 +70,46 @@ type (
 		ZIncrBy(key string, increment float64, member string) (float64, error)
 		Incr(key string) error
 		IncrBy(key string, value int64) error
+
+		ConfigSet(parameter, value string) error
+
+		// Eval run a lua script, keys must hash to the same slot on cluster.
+		Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+
+		// PFAdd add elements to a HyperLogLog and return true when its
+		// estimation changed.
+		PFAdd(key string, elements ...interface{}) (bool, error)
+		// PFCount estimate the cardinality of the union of the HyperLogLogs.
+		PFCount(keys ...string) (int64, error)
+		PFMerge(dest string, keys ...string) error
+
+		// SetBits set the bits at offsets to 1 in one round trip and return
+		// their previous values.
+		SetBits(key string, offsets ...int64) ([]bool, error)
+		GetBits(key string, offsets ...int64) ([]bool, error)
+
+		// Scan iterate the keys matching match with SCAN, on cluster and
+		// sharded clients every node is scanned.
+		Scan(match string, count int64, callback ScanCallback) error
+		// Dump return the serialized value of key, Restore create a key from
+		// it, a zero ttl create the key without expiry.
+		Dump(key string) (string, error)
+		Restore(key string, ttl time.Duration, value string, replace bool) error
+
+		GeoAdd(key string, locations ...*redis.GeoLocation) (int64, error)
+		// GeoPos return nil for members not in the set.
+		GeoPos(key string, members ...string) ([]*redis.GeoPos, error)
+		GeoDist(key, member1, member2, unit string) (float64, error)
+		// GeoRadius search with GEORADIUS, GeoSearch with GEOSEARCH which
+		// also support boxes but require redis 6.2.
+		GeoRadius(key string, query *GeoQuery) ([]redis.GeoLocation, error)
+		GeoSearch(key string, query *GeoQuery) ([]redis.GeoLocation, error)
 	}
 
+	// ScanCallback receive a batch of scanned keys, returning an error stop
+	// the scan.
+	ScanCallback func(keys []string) error
+
 	PoolCallback func(client Cache)
 
 	Pool interface {
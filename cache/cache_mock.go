@@ -170,6 +170,48 @@ func (mr *MockPubSubMockRecorder) Receive() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Receive", reflect.TypeOf((*MockPubSub)(nil).Receive))
 }
 
+// MockSubscriber is a mock of Subscriber interface.
+type MockSubscriber struct {
+	ctrl     *gomock.Controller
+	recorder *MockSubscriberMockRecorder
+}
+
+// MockSubscriberMockRecorder is the mock recorder for MockSubscriber.
+type MockSubscriberMockRecorder struct {
+	mock *MockSubscriber
+}
+
+// NewMockSubscriber creates a new mock instance.
+func NewMockSubscriber(ctrl *gomock.Controller) *MockSubscriber {
+	mock := &MockSubscriber{ctrl: ctrl}
+	mock.recorder = &MockSubscriberMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSubscriber) EXPECT() *MockSubscriberMockRecorder {
+	return m.recorder
+}
+
+// NewPubSub mocks base method.
+func (m *MockSubscriber) NewPubSub(channels ...string) (PubSub, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range channels {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "NewPubSub", varargs...)
+	ret0, _ := ret[0].(PubSub)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NewPubSub indicates an expected call of NewPubSub.
+func (mr *MockSubscriberMockRecorder) NewPubSub(channels ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewPubSub", reflect.TypeOf((*MockSubscriber)(nil).NewPubSub), channels...)
+}
+
 // MockCache is a mock of Cache interface.
 type MockCache struct {
 	ctrl     *gomock.Controller
@@ -221,6 +263,55 @@ func (mr *MockCacheMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockCache)(nil).Close))
 }
 
+// ConfigSet mocks base method.
+func (m *MockCache) ConfigSet(parameter, value string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfigSet", parameter, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfigSet indicates an expected call of ConfigSet.
+func (mr *MockCacheMockRecorder) ConfigSet(parameter, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfigSet", reflect.TypeOf((*MockCache)(nil).ConfigSet), parameter, value)
+}
+
+// Dump mocks base method.
+func (m *MockCache) Dump(key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dump", key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Dump indicates an expected call of Dump.
+func (mr *MockCacheMockRecorder) Dump(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dump", reflect.TypeOf((*MockCache)(nil).Dump), key)
+}
+
+// Eval mocks base method.
+func (m *MockCache) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{script, keys}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Eval", varargs...)
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Eval indicates an expected call of Eval.
+func (mr *MockCacheMockRecorder) Eval(script, keys interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{script, keys}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Eval", reflect.TypeOf((*MockCache)(nil).Eval), varargs...)
+}
+
 // FlushAll mocks base method.
 func (m *MockCache) FlushAll() error {
 	m.ctrl.T.Helper()
@@ -249,6 +340,91 @@ func (mr *MockCacheMockRecorder) FlushDatabase() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlushDatabase", reflect.TypeOf((*MockCache)(nil).FlushDatabase))
 }
 
+// GeoAdd mocks base method.
+func (m *MockCache) GeoAdd(key string, locations ...*redis.GeoLocation) (int64, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{key}
+	for _, a := range locations {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GeoAdd", varargs...)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GeoAdd indicates an expected call of GeoAdd.
+func (mr *MockCacheMockRecorder) GeoAdd(key interface{}, locations ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{key}, locations...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeoAdd", reflect.TypeOf((*MockCache)(nil).GeoAdd), varargs...)
+}
+
+// GeoDist mocks base method.
+func (m *MockCache) GeoDist(key, member1, member2, unit string) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GeoDist", key, member1, member2, unit)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GeoDist indicates an expected call of GeoDist.
+func (mr *MockCacheMockRecorder) GeoDist(key, member1, member2, unit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeoDist", reflect.TypeOf((*MockCache)(nil).GeoDist), key, member1, member2, unit)
+}
+
+// GeoPos mocks base method.
+func (m *MockCache) GeoPos(key string, members ...string) ([]*redis.GeoPos, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{key}
+	for _, a := range members {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GeoPos", varargs...)
+	ret0, _ := ret[0].([]*redis.GeoPos)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GeoPos indicates an expected call of GeoPos.
+func (mr *MockCacheMockRecorder) GeoPos(key interface{}, members ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{key}, members...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeoPos", reflect.TypeOf((*MockCache)(nil).GeoPos), varargs...)
+}
+
+// GeoRadius mocks base method.
+func (m *MockCache) GeoRadius(key string, query *GeoQuery) ([]redis.GeoLocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GeoRadius", key, query)
+	ret0, _ := ret[0].([]redis.GeoLocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GeoRadius indicates an expected call of GeoRadius.
+func (mr *MockCacheMockRecorder) GeoRadius(key, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeoRadius", reflect.TypeOf((*MockCache)(nil).GeoRadius), key, query)
+}
+
+// GeoSearch mocks base method.
+func (m *MockCache) GeoSearch(key string, query *GeoQuery) ([]redis.GeoLocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GeoSearch", key, query)
+	ret0, _ := ret[0].([]redis.GeoLocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GeoSearch indicates an expected call of GeoSearch.
+func (mr *MockCacheMockRecorder) GeoSearch(key, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeoSearch", reflect.TypeOf((*MockCache)(nil).GeoSearch), key, query)
+}
+
 // Get mocks base method.
 func (m *MockCache) Get(arg0 string, arg1 interface{}) error {
 	m.ctrl.T.Helper()
@@ -263,6 +439,26 @@ func (mr *MockCacheMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockCache)(nil).Get), arg0, arg1)
 }
 
+// GetBits mocks base method.
+func (m *MockCache) GetBits(key string, offsets ...int64) ([]bool, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{key}
+	for _, a := range offsets {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetBits", varargs...)
+	ret0, _ := ret[0].([]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBits indicates an expected call of GetBits.
+func (mr *MockCacheMockRecorder) GetBits(key interface{}, offsets ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{key}, offsets...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBits", reflect.TypeOf((*MockCache)(nil).GetBits), varargs...)
+}
+
 // GetZSet mocks base method.
 func (m *MockCache) GetZSet(arg0 string) ([]redis.Z, error) {
 	m.ctrl.T.Helper()
@@ -488,6 +684,64 @@ func (mr *MockCacheMockRecorder) MSetWithExpiration(keys, values, ttls interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MSetWithExpiration", reflect.TypeOf((*MockCache)(nil).MSetWithExpiration), keys, values, ttls)
 }
 
+// PFAdd mocks base method.
+func (m *MockCache) PFAdd(key string, elements ...interface{}) (bool, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{key}
+	for _, a := range elements {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PFAdd", varargs...)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PFAdd indicates an expected call of PFAdd.
+func (mr *MockCacheMockRecorder) PFAdd(key interface{}, elements ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{key}, elements...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PFAdd", reflect.TypeOf((*MockCache)(nil).PFAdd), varargs...)
+}
+
+// PFCount mocks base method.
+func (m *MockCache) PFCount(keys ...string) (int64, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PFCount", varargs...)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PFCount indicates an expected call of PFCount.
+func (mr *MockCacheMockRecorder) PFCount(keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PFCount", reflect.TypeOf((*MockCache)(nil).PFCount), keys...)
+}
+
+// PFMerge mocks base method.
+func (m *MockCache) PFMerge(dest string, keys ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{dest}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PFMerge", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PFMerge indicates an expected call of PFMerge.
+func (mr *MockCacheMockRecorder) PFMerge(dest interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{dest}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PFMerge", reflect.TypeOf((*MockCache)(nil).PFMerge), varargs...)
+}
+
 // Ping mocks base method.
 func (m *MockCache) Ping() error {
 	m.ctrl.T.Helper()
@@ -544,6 +798,34 @@ func (mr *MockCacheMockRecorder) RemoveByPattern(arg0, arg1 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveByPattern", reflect.TypeOf((*MockCache)(nil).RemoveByPattern), arg0, arg1)
 }
 
+// Restore mocks base method.
+func (m *MockCache) Restore(key string, ttl time.Duration, value string, replace bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", key, ttl, value, replace)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockCacheMockRecorder) Restore(key, ttl, value, replace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockCache)(nil).Restore), key, ttl, value, replace)
+}
+
+// Scan mocks base method.
+func (m *MockCache) Scan(match string, count int64, callback ScanCallback) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Scan", match, count, callback)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockCacheMockRecorder) Scan(match, count, callback interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockCache)(nil).Scan), match, count, callback)
+}
+
 // Set mocks base method.
 func (m *MockCache) Set(arg0 string, arg1 interface{}) error {
 	m.ctrl.T.Helper()
@@ -558,6 +840,26 @@ func (mr *MockCacheMockRecorder) Set(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockCache)(nil).Set), arg0, arg1)
 }
 
+// SetBits mocks base method.
+func (m *MockCache) SetBits(key string, offsets ...int64) ([]bool, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{key}
+	for _, a := range offsets {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "SetBits", varargs...)
+	ret0, _ := ret[0].([]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetBits indicates an expected call of SetBits.
+func (mr *MockCacheMockRecorder) SetBits(key interface{}, offsets ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{key}, offsets...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBits", reflect.TypeOf((*MockCache)(nil).SetBits), varargs...)
+}
+
 // SetNx mocks base method.
 func (m *MockCache) SetNx(key string, value interface{}, ttl time.Duration) (bool, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+)
+
+type (
+	// GeoQuery search the members of a geo set around a member or a
+	// coordinate, within Radius or, with GeoSearch only, within a Width x
+	// Height box. Unit is m, km, mi or ft, km when empty. Count limit the
+	// results, Any return the first Count matches found instead of the
+	// nearest ones (GeoSearch only). Sort is ASC, DESC or empty.
+	GeoQuery struct {
+		Member              string
+		Longitude, Latitude float64
+
+		Radius        float64
+		Width, Height float64
+		Unit          string
+
+		Count int
+		Any   bool
+		Sort  string
+
+		WithCoord bool
+		WithDist  bool
+		WithHash  bool
+	}
+)
+
+// RadiusQuery convert the query to the go-redis GEORADIUS query.
+func (q *GeoQuery) RadiusQuery() *redis.GeoRadiusQuery {
+	return &redis.GeoRadiusQuery{
+		Radius:      q.Radius,
+		Unit:        q.unit(),
+		WithCoord:   q.WithCoord,
+		WithDist:    q.WithDist,
+		WithGeoHash: q.WithHash,
+		Count:       q.Count,
+		Sort:        q.Sort,
+	}
+}
+
+// SearchArgs build the GEOSEARCH command, available since redis 6.2.
+func (q *GeoQuery) SearchArgs(key string) ([]interface{}, error) {
+	args := []interface{}{"geosearch", key}
+
+	if q.Member != "" {
+		args = append(args, "frommember", q.Member)
+	} else {
+		args = append(args, "fromlonlat", q.Longitude, q.Latitude)
+	}
+
+	switch {
+	case q.Radius > 0:
+		args = append(args, "byradius", q.Radius, q.unit())
+	case q.Width > 0 && q.Height > 0:
+		args = append(args, "bybox", q.Width, q.Height, q.unit())
+	default:
+		return nil, errors.New("geo search require a radius or a box")
+	}
+
+	if q.Sort != "" {
+		args = append(args, strings.ToLower(q.Sort))
+	}
+
+	if q.Count > 0 {
+		args = append(args, "count", q.Count)
+		if q.Any {
+			args = append(args, "any")
+		}
+	}
+
+	if q.WithCoord {
+		args = append(args, "withcoord")
+	}
+	if q.WithDist {
+		args = append(args, "withdist")
+	}
+	if q.WithHash {
+		args = append(args, "withhash")
+	}
+
+	return args, nil
+}
+
+// ParseLocations read a GEOSEARCH or GEORADIUS reply, members are plain
+// names without WITH options, otherwise arrays of the name followed by the
+// distance, the hash and the coordinates in that order.
+func (q *GeoQuery) ParseLocations(reply interface{}) ([]redis.GeoLocation, error) {
+	values, ok := reply.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("unexpected geo reply %T", reply)
+	}
+
+	locations := make([]redis.GeoLocation, 0, len(values))
+
+	for _, value := range values {
+		if name, ok := value.(string); ok {
+			locations = append(locations, redis.GeoLocation{Name: name})
+			continue
+		}
+
+		fields, ok := value.([]interface{})
+		if !ok || len(fields) == 0 {
+			return nil, errors.Errorf("unexpected geo reply item %T", value)
+		}
+
+		location := redis.GeoLocation{}
+		location.Name, _ = fields[0].(string)
+		fields = fields[1:]
+
+		var err error
+
+		if q.WithDist && len(fields) > 0 {
+			if location.Dist, err = parseFloat(fields[0]); err != nil {
+				return nil, err
+			}
+			fields = fields[1:]
+		}
+
+		if q.WithHash && len(fields) > 0 {
+			location.GeoHash, _ = fields[0].(int64)
+			fields = fields[1:]
+		}
+
+		if q.WithCoord && len(fields) > 0 {
+			coord, _ := fields[0].([]interface{})
+			if len(coord) != 2 {
+				return nil, errors.New("unexpected geo coordinates reply")
+			}
+
+			if location.Longitude, err = parseFloat(coord[0]); err != nil {
+				return nil, err
+			}
+			if location.Latitude, err = parseFloat(coord[1]); err != nil {
+				return nil, err
+			}
+		}
+
+		locations = append(locations, location)
+	}
+
+	return locations, nil
+}
+
+func (q *GeoQuery) unit() string {
+	if q.Unit == "" {
+		return "km"
+	}
+
+	return q.Unit
+}
+
+func parseFloat(value interface{}) (float64, error) {
+	s, _ := value.(string)
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse geo reply %v", value)
+	}
+
+	return f, nil
+}
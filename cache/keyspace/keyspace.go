@@ -0,0 +1,279 @@
+package keyspace
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+)
+
+const (
+	Expired Operation = "expired"
+	Evicted Operation = "evicted"
+	Set     Operation = "set"
+	Del     Operation = "del"
+
+	keyEventChannel = "__keyevent@%d__:%s"
+	keySpaceChannel = "__keyspace@%d__:%s"
+)
+
+var (
+	operations = []Operation{Expired, Evicted, Set, Del}
+
+	// flags are notify-keyspace-events classes that publish each operation.
+	flags = map[Operation]string{
+		Expired: "x",
+		Evicted: "e",
+		Set:     "$",
+		Del:     "g",
+	}
+)
+
+type (
+	Operation string
+
+	Event struct {
+		Key       string
+		Operation Operation
+		DB        int
+	}
+
+	Handler func(Event)
+
+	// Option configure a Listener. Enable set notify-keyspace-events on
+	// redis, CONFIG SET replace the whole value so leave it disabled and
+	// configure redis itself when other features rely on other classes or
+	// when CONFIG is not allowed, as in most managed redis.
+	Option struct {
+		DB        int
+		Enable    bool
+		KeyPrefix string
+		Logger    logs.Logger
+	}
+
+	// Listener deliver keyevent notifications (every key for an operation)
+	// with On and keyspace notifications (every operation for a key) with
+	// OnKey. Handlers must be registered before Listen.
+	//
+	// Every listener subscribe to all its channels with a pubsub of its own,
+	// a single connection whatever the number of keys, it need a cache
+	// implementing cache.Subscriber, the single node redis client. Redis
+	// cluster publish notifications only on the node owning the key so the
+	// cluster clients are not supported.
+	Listener interface {
+		On(operation Operation, handler Handler)
+		OnKey(key string, handler Handler)
+		Listen() error
+		Close() error
+	}
+
+	listener struct {
+		cache      cache.Cache
+		subscriber cache.Subscriber
+		option     *Option
+
+		mu         sync.RWMutex
+		operations map[Operation][]Handler
+		keys       map[string][]Handler
+		pubsub     cache.PubSub
+		wg         sync.WaitGroup
+	}
+)
+
+func New(c cache.Cache, option *Option) (Listener, error) {
+	if c == nil {
+		return nil, errors.New("cache is required!")
+	}
+
+	subscriber, ok := c.(cache.Subscriber)
+	if !ok {
+		return nil, errors.New("keyspace notifications require a single node redis client!")
+	}
+
+	return &listener{
+		cache:      c,
+		subscriber: subscriber,
+		option:     option,
+		operations: make(map[Operation][]Handler),
+		keys:       make(map[string][]Handler),
+	}, nil
+}
+
+func (l *listener) On(operation Operation, handler Handler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.operations[operation] = append(l.operations[operation], handler)
+}
+
+func (l *listener) OnKey(key string, handler Handler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.keys[key] = append(l.keys[key], handler)
+}
+
+func (l *listener) Listen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.operations) == 0 && len(l.keys) == 0 {
+		return errors.New("no handler registered!")
+	}
+
+	if l.option.Enable {
+		if err := l.cache.ConfigSet("notify-keyspace-events", l.flags()); err != nil {
+			return errors.Wrap(err, "failed to enable keyspace notifications")
+		}
+	}
+
+	channels := make([]string, 0, len(l.operations)+len(l.keys))
+	for operation := range l.operations {
+		channels = append(channels, fmt.Sprintf(keyEventChannel, l.option.DB, operation))
+	}
+	for key := range l.keys {
+		channels = append(channels, fmt.Sprintf(keySpaceChannel, l.option.DB, key))
+	}
+
+	return l.subscribe(channels)
+}
+
+func (l *listener) Close() error {
+	l.mu.Lock()
+	err := l.unsubscribe()
+	l.mu.Unlock()
+
+	l.wg.Wait()
+
+	return err
+}
+
+// - private
+
+func (l *listener) flags() string {
+	classes := ""
+
+	if len(l.operations) > 0 {
+		classes += "E"
+	}
+
+	if len(l.keys) > 0 {
+		classes += "K"
+	}
+
+	for _, operation := range operations {
+		if _, ok := l.operations[operation]; ok || len(l.keys) > 0 {
+			classes += flags[operation]
+		}
+	}
+
+	return classes
+}
+
+// subscribe open one pubsub for every channel and dispatch its messages by
+// channel, l.mu must be held.
+func (l *listener) subscribe(channels []string) error {
+	if l.pubsub != nil {
+		return errors.New("listener is already listening!")
+	}
+
+	p, err := l.subscriber.NewPubSub(channels...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to subscribe to %s", strings.Join(channels, ", "))
+	}
+
+	if err := p.Receive(); err != nil {
+		_ = p.Close()
+		return errors.Wrapf(err, "failed to subscribe to %s", strings.Join(channels, ", "))
+	}
+
+	l.pubsub = p
+	l.wg.Add(1)
+
+	go func() {
+		defer l.wg.Done()
+
+		for message := range p.Channel() {
+			l.dispatch(message)
+		}
+	}()
+
+	return nil
+}
+
+// unsubscribe close the pubsub, its goroutine end once it is closed, l.mu
+// must be held.
+func (l *listener) unsubscribe() error {
+	p := l.pubsub
+	l.pubsub = nil
+
+	if p == nil {
+		return nil
+	}
+
+	if err := p.Close(); err != nil {
+		return errors.Wrap(err, "failed to close subscription")
+	}
+
+	return nil
+}
+
+func (l *listener) dispatch(message *redis.Message) {
+	event, ok := parse(message)
+	if !ok {
+		return
+	}
+
+	event.DB = l.option.DB
+
+	if !strings.HasPrefix(event.Key, l.option.KeyPrefix) {
+		return
+	}
+
+	l.mu.RLock()
+	handlers := l.operations[event.Operation]
+	if strings.HasPrefix(message.Channel, "__keyspace@") {
+		handlers = l.keys[event.Key]
+	}
+	l.mu.RUnlock()
+
+	for _, handler := range handlers {
+		l.call(handler, event)
+	}
+}
+
+func (l *listener) call(handler Handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil && l.option.Logger != nil {
+			l.option.Logger.Errorf("keyspace handler of %s %s panic: %v", event.Operation, event.Key, r)
+		}
+	}()
+
+	handler(event)
+}
+
+// parse read keyevent (`__keyevent@0__:expired` with the key as payload)
+// and keyspace (`__keyspace@0__:key` with the operation as payload) messages.
+func parse(message *redis.Message) (Event, bool) {
+	i := strings.Index(message.Channel, "__:")
+	if i < 0 {
+		return Event{}, false
+	}
+
+	suffix := message.Channel[i+3:]
+
+	if strings.HasPrefix(message.Channel, "__keyevent@") {
+		return Event{Key: message.Payload, Operation: Operation(suffix)}, true
+	}
+
+	if strings.HasPrefix(message.Channel, "__keyspace@") {
+		return Event{Key: suffix, Operation: Operation(message.Payload)}, true
+	}
+
+	return Event{}, false
+}
@@ -0,0 +1,148 @@
+package keyspace_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/keyspace"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redistest"
+)
+
+type subscriberCache struct {
+	*cache.MockCache
+	*cache.MockSubscriber
+}
+
+func listen(t *testing.T, c cache.Cache, register func(l keyspace.Listener)) keyspace.Listener {
+	l, err := keyspace.New(c, &keyspace.Option{Enable: true})
+	assert2.NoError(t, err)
+
+	register(l)
+	assert2.NoError(t, l.Listen())
+
+	return l
+}
+
+func receive(t *testing.T, events <-chan keyspace.Event) keyspace.Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("event not received")
+		return keyspace.Event{}
+	}
+}
+
+func Test_Listener(t *testing.T) {
+
+	t.Run("when key is set, deleted and expired", func(t *testing.T) {
+		s, c := redistest.NewClient(t)
+
+		events := make(chan keyspace.Event, 10)
+		l := listen(t, c, func(l keyspace.Listener) {
+			l.On(keyspace.Expired, func(e keyspace.Event) { events <- e })
+			l.OnKey("user:1", func(e keyspace.Event) { events <- e })
+		})
+		defer l.Close()
+
+		assert2.NoError(t, c.Set("user:1", "andree"))
+		assert2.Equal(t, keyspace.Event{Key: "user:1", Operation: keyspace.Set}, receive(t, events))
+
+		assert2.NoError(t, c.Remove("user:1"))
+		assert2.Equal(t, keyspace.Event{Key: "user:1", Operation: keyspace.Del}, receive(t, events))
+
+		assert2.NoError(t, c.SetWithExpiration("user:2", "andree", time.Second))
+		s.FastForward(2 * time.Second)
+		assert2.Equal(t, keyspace.Event{Key: "user:2", Operation: keyspace.Expired}, receive(t, events))
+	})
+
+	t.Run("when watching many keys, subscribe on a single connection", func(t *testing.T) {
+		s, c := redistest.NewClient(t)
+
+		events := make(chan keyspace.Event, 10)
+		l := listen(t, c, func(l keyspace.Listener) {
+			l.On(keyspace.Expired, func(e keyspace.Event) { events <- e })
+			for _, key := range []string{"user:1", "user:2", "user:3", "user:4"} {
+				l.OnKey(key, func(e keyspace.Event) { events <- e })
+			}
+		})
+
+		assert2.Equal(t, 1, s.Subscribers())
+
+		for _, key := range []string{"user:1", "user:2", "user:3", "user:4"} {
+			assert2.NoError(t, c.Set(key, "andree"))
+			assert2.Equal(t, keyspace.Event{Key: key, Operation: keyspace.Set}, receive(t, events))
+		}
+
+		assert2.NoError(t, c.Set("user:5", "andree"))
+		assert2.Error(t, l.Listen())
+
+		assert2.NoError(t, l.Close())
+		assert2.Eventually(t, func() bool {
+			return s.Subscribers() == 0
+		}, time.Second, 10*time.Millisecond)
+		assert2.Len(t, events, 0)
+	})
+
+	t.Run("when listeners share a channel", func(t *testing.T) {
+		_, c := redistest.NewClient(t)
+
+		first := make(chan keyspace.Event, 10)
+		second := make(chan keyspace.Event, 10)
+
+		l1 := listen(t, c, func(l keyspace.Listener) {
+			l.On(keyspace.Set, func(e keyspace.Event) { first <- e })
+		})
+		l2 := listen(t, c, func(l keyspace.Listener) {
+			l.On(keyspace.Set, func(e keyspace.Event) { second <- e })
+		})
+		defer l2.Close()
+
+		assert2.NoError(t, c.Set("user:1", "andree"))
+		assert2.Equal(t, "user:1", receive(t, first).Key)
+		assert2.Equal(t, "user:1", receive(t, second).Key)
+
+		assert2.NoError(t, l1.Close())
+
+		assert2.NoError(t, c.Set("user:2", "andree"))
+		assert2.Equal(t, "user:2", receive(t, second).Key)
+	})
+
+	t.Run("when cache cannot open its own pubsub", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		_, err := keyspace.New(cache.NewMockCache(ctrl), &keyspace.Option{})
+		assert2.Error(t, err)
+	})
+
+	t.Run("when the subscription fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		p := cache.NewMockPubSub(ctrl)
+		p.EXPECT().Receive().Return(errors.New("connection reset"))
+		p.EXPECT().Close().Return(nil)
+
+		c := subscriberCache{MockCache: cache.NewMockCache(ctrl), MockSubscriber: cache.NewMockSubscriber(ctrl)}
+		gomock.InOrder(
+			c.MockSubscriber.EXPECT().NewPubSub(gomock.Any(), gomock.Any()).Return(nil, errors.New("connection refused")),
+			c.MockSubscriber.EXPECT().NewPubSub(gomock.Any(), gomock.Any()).Return(p, nil),
+		)
+
+		l, err := keyspace.New(c, &keyspace.Option{})
+		assert2.NoError(t, err)
+
+		l.On(keyspace.Set, func(keyspace.Event) {})
+		l.On(keyspace.Del, func(keyspace.Event) {})
+
+		assert2.Error(t, l.Listen())
+		assert2.Error(t, l.Listen())
+		assert2.NoError(t, l.Close())
+	})
+}
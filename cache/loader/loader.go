@@ -0,0 +1,139 @@
+package loader
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+	andretime "github.com/AndreeJait/GO-ANDREE-UTILITIES/util/andreTime"
+)
+
+type (
+	// Func load the value from the source of truth, the returned value is
+	// stored as json.
+	Func func() (interface{}, error)
+
+	// entry is the envelope stored in cache, it keep the loaded value along
+	// with how long it took to compute and when it should be recomputed.
+	entry struct {
+		Value   json.RawMessage `json:"value"`
+		Delta   time.Duration   `json:"delta"`
+		Expiry  int64           `json:"expiry"`
+		StaleAt int64           `json:"stale_at,omitempty"`
+	}
+
+	base struct {
+		cache  cache.Cache
+		logger logs.Logger
+		clock  andretime.AndreTime
+		group  singleflight.Group
+
+		refreshing sync.Map
+	}
+)
+
+func (e *entry) MarshalBinary() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (e *entry) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, e)
+}
+
+func (e *entry) decode(object interface{}) error {
+	if err := json.Unmarshal(e.Value, object); err != nil {
+		return errors.Wrap(err, "failed to unmarshal cached value")
+	}
+
+	return nil
+}
+
+func newBase(c cache.Cache, logger logs.Logger, clock andretime.AndreTime) base {
+	if clock == nil {
+		clock = andretime.NewRealTime()
+	}
+
+	return base{cache: c, logger: logger, clock: clock}
+}
+
+// read return nil entry without error when the key does not exist.
+func (b *base) read(key string) (*entry, error) {
+	e := &entry{}
+
+	if err := b.cache.Get(key, e); err != nil {
+		if errors.Cause(err) == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// load call fn once per key at a time and store the result, concurrent
+// callers of the same key share the result.
+func (b *base) load(key string, fn Func, build func(now time.Time, delta time.Duration) (*entry, time.Duration)) (*entry, error) {
+	res, err, _ := b.group.Do(key, func() (interface{}, error) {
+		start := b.clock.Now()
+
+		value, err := fn()
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal value of key %s", key)
+		}
+
+		now := b.clock.Now()
+		e, ttl := build(now, now.Sub(start))
+		e.Value = raw
+
+		if err := b.cache.SetWithExpiration(key, e, ttl); err != nil {
+			b.errorf("failed to store key %s: %s", key, err)
+		}
+
+		return e, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*entry), nil
+}
+
+// refresh run load in background, refresh of a key already in flight is
+// ignored. done, if any, is called with the error of the refresh once it is
+// over, nil on success.
+func (b *base) refresh(key string, fn Func, build func(now time.Time, delta time.Duration) (*entry, time.Duration), done func(error)) {
+	if _, loaded := b.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+
+	go func() {
+		defer b.refreshing.Delete(key)
+
+		_, err := b.load(key, fn, build)
+		if err != nil {
+			b.errorf("failed to refresh key %s: %s", key, err)
+		}
+
+		if done != nil {
+			done(err)
+		}
+	}()
+}
+
+func (b *base) errorf(format string, args ...interface{}) {
+	if b.logger != nil {
+		b.logger.Errorf(format, args...)
+	}
+}
@@ -0,0 +1,114 @@
+package loader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+	andretime "github.com/AndreeJait/GO-ANDREE-UTILITIES/util/andreTime"
+)
+
+type (
+	// StaleOption configure Stale, an entry is fresh until SoftTTL, then
+	// stale until HardTTL and is removed afterward. RetryAfter delay the
+	// next background refresh after a failed one.
+	StaleOption struct {
+		SoftTTL    time.Duration
+		HardTTL    time.Duration
+		RetryAfter time.Duration
+		Logger     logs.Logger
+		Time       andretime.AndreTime
+	}
+
+	// Stale implement stale-while-revalidate and serve-stale-on-error on top
+	// of any cache.Cache. A stale entry is returned right away while it is
+	// refreshed in background, when the refresh fails the stale entry keep
+	// being served until its hard ttl.
+	Stale struct {
+		base
+		option *StaleOption
+		failed sync.Map
+
+		// refreshed is called once a background refresh is over and its
+		// failure recorded, for tests.
+		refreshed func(key string, err error)
+	}
+)
+
+func NewStale(c cache.Cache, option *StaleOption) (*Stale, error) {
+	if option.SoftTTL <= 0 || option.HardTTL < option.SoftTTL {
+		return nil, errors.New("soft ttl is required and must not exceed hard ttl!")
+	}
+
+	return &Stale{base: newBase(c, option.Logger, option.Time), option: option}, nil
+}
+
+// Fetch read key into object using the soft and hard ttl from option.
+func (s *Stale) Fetch(key string, object interface{}, fn Func) error {
+	return s.FetchWithTTL(key, s.option.SoftTTL, s.option.HardTTL, object, fn)
+}
+
+// FetchWithTTL read key into object, fn is only called synchronously when
+// the key does not exist or is past its hard ttl.
+func (s *Stale) FetchWithTTL(key string, softTTL, hardTTL time.Duration, object interface{}, fn Func) error {
+	if hardTTL < softTTL {
+		hardTTL = softTTL
+	}
+
+	e, err := s.read(key)
+	if err != nil {
+		s.errorf("failed to read key %s: %s", key, err)
+	}
+
+	build := func(now time.Time, delta time.Duration) (*entry, time.Duration) {
+		return &entry{
+			Delta:   delta,
+			StaleAt: now.Add(softTTL).UnixNano(),
+			Expiry:  now.Add(hardTTL).UnixNano(),
+		}, hardTTL
+	}
+
+	now := s.clock.Now()
+
+	if e == nil || now.UnixNano() >= e.Expiry {
+		if e, err = s.load(key, fn, build); err != nil {
+			return err
+		}
+
+		s.failed.Delete(key)
+		return e.decode(object)
+	}
+
+	if now.UnixNano() >= e.StaleAt && s.shouldRetry(key, now) {
+		s.refresh(key, fn, build, func(err error) {
+			if err != nil {
+				s.failed.Store(key, s.clock.Now())
+			} else {
+				s.failed.Delete(key)
+			}
+
+			if s.refreshed != nil {
+				s.refreshed(key, err)
+			}
+		})
+	}
+
+	return e.decode(object)
+}
+
+func (s *Stale) shouldRetry(key string, now time.Time) bool {
+	failedAt, ok := s.failed.Load(key)
+	if !ok {
+		return true
+	}
+
+	if now.Sub(failedAt.(time.Time)) < s.option.RetryAfter {
+		return false
+	}
+
+	s.failed.Delete(key)
+	return true
+}
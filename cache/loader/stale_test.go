@@ -0,0 +1,111 @@
+package loader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	andretime "github.com/AndreeJait/GO-ANDREE-UTILITIES/util/andreTime"
+)
+
+func Test_Stale(t *testing.T) {
+	now := time.Date(2020, time.August, 31, 0, 0, 0, 0, time.UTC)
+
+	t.Run("when key does not exist", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		c := cache.NewMockCache(ctrl)
+		c.EXPECT().Get("key", gomock.Any()).Return(errors.Wrap(redis.Nil, "key key does not exits"))
+		c.EXPECT().SetWithExpiration("key", gomock.Any(), time.Hour).Return(nil)
+
+		s, err := NewStale(c, &StaleOption{SoftTTL: time.Minute, HardTTL: time.Hour, Time: andretime.NewFakeTimeAt(now)})
+		assert2.NoError(t, err)
+
+		var value string
+		err = s.Fetch("key", &value, func() (interface{}, error) {
+			return "fresh", nil
+		})
+
+		assert2.NoError(t, err)
+		assert2.Equal(t, "fresh", value)
+	})
+
+	t.Run("when key is stale and refresh fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		stale := &entry{Value: []byte(`"stale"`), StaleAt: now.Add(-time.Minute).UnixNano(), Expiry: now.Add(time.Hour).UnixNano()}
+		data, _ := stale.MarshalBinary()
+
+		c := cache.NewMockCache(ctrl)
+		c.EXPECT().Get("key", gomock.Any()).DoAndReturn(func(key string, object interface{}) error {
+			return object.(*entry).UnmarshalBinary(data)
+		}).Times(2)
+
+		s, err := NewStale(c, &StaleOption{SoftTTL: time.Minute, HardTTL: time.Hour, RetryAfter: time.Hour, Time: andretime.NewFakeTimeAt(now)})
+		assert2.NoError(t, err)
+
+		refreshed := make(chan error, 1)
+		s.refreshed = func(_ string, err error) {
+			refreshed <- err
+		}
+
+		called := make(chan struct{}, 2)
+		fn := func() (interface{}, error) {
+			called <- struct{}{}
+			return nil, errors.New("source is down")
+		}
+
+		var value string
+		assert2.NoError(t, s.Fetch("key", &value, fn))
+		assert2.Equal(t, "stale", value)
+
+		assert2.Error(t, <-refreshed)
+		<-called
+
+		assert2.NoError(t, s.Fetch("key", &value, fn))
+		assert2.Equal(t, "stale", value)
+		assert2.Len(t, called, 0)
+	})
+}
+
+func Test_StaleRefreshSucceeds(t *testing.T) {
+	now := time.Date(2020, time.August, 31, 0, 0, 0, 0, time.UTC)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stale := &entry{Value: []byte(`"stale"`), StaleAt: now.Add(-time.Minute).UnixNano(), Expiry: now.Add(time.Hour).UnixNano()}
+	data, _ := stale.MarshalBinary()
+
+	c := cache.NewMockCache(ctrl)
+	c.EXPECT().Get("key", gomock.Any()).DoAndReturn(func(key string, object interface{}) error {
+		return object.(*entry).UnmarshalBinary(data)
+	})
+	c.EXPECT().SetWithExpiration("key", gomock.Any(), time.Hour).Return(nil)
+
+	s, err := NewStale(c, &StaleOption{SoftTTL: time.Minute, HardTTL: time.Hour, Time: andretime.NewFakeTimeAt(now)})
+	assert2.NoError(t, err)
+
+	refreshed := make(chan error, 1)
+	s.refreshed = func(_ string, err error) {
+		refreshed <- err
+	}
+
+	var value string
+	assert2.NoError(t, s.Fetch("key", &value, func() (interface{}, error) {
+		return "fresh", nil
+	}))
+	assert2.Equal(t, "stale", value)
+
+	assert2.NoError(t, <-refreshed)
+
+	_, failed := s.failed.Load("key")
+	assert2.False(t, failed)
+}
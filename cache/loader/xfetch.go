@@ -0,0 +1,87 @@
+package loader
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+	andretime "github.com/AndreeJait/GO-ANDREE-UTILITIES/util/andreTime"
+)
+
+const DefaultBeta = 1.0
+
+type (
+	// XFetchOption configure XFetch, Beta above 1 favor earlier recomputation
+	// and below 1 favor later recomputation.
+	XFetchOption struct {
+		Beta   float64
+		Logger logs.Logger
+		Time   andretime.AndreTime
+	}
+
+	// XFetch implement probabilistic early recomputation, every read of a
+	// key that is about to expire has a chance to refresh it in background,
+	// the chance grow as the expiry get closer and as the value get more
+	// expensive to compute. So readers spread on many pods refresh the key
+	// before it expires instead of all of them at the same moment.
+	XFetch struct {
+		base
+		beta   float64
+		random func() float64
+	}
+)
+
+func NewXFetch(c cache.Cache, option *XFetchOption) *XFetch {
+	beta := option.Beta
+	if beta <= 0 {
+		beta = DefaultBeta
+	}
+
+	return &XFetch{
+		base:   newBase(c, option.Logger, option.Time),
+		beta:   beta,
+		random: rand.Float64,
+	}
+}
+
+// Fetch read key into object, on miss fn is called and its result stored
+// with ttl. On hit the value is returned right away and a background
+// refresh may be triggered before the ttl expires.
+func (x *XFetch) Fetch(key string, ttl time.Duration, object interface{}, fn Func) error {
+	e, err := x.read(key)
+	if err != nil {
+		x.errorf("failed to read key %s: %s", key, err)
+	}
+
+	build := func(now time.Time, delta time.Duration) (*entry, time.Duration) {
+		return &entry{Delta: delta, Expiry: now.Add(ttl).UnixNano()}, ttl
+	}
+
+	if e == nil {
+		if e, err = x.load(key, fn, build); err != nil {
+			return err
+		}
+		return e.decode(object)
+	}
+
+	if x.shouldRefresh(e) {
+		x.refresh(key, fn, build, nil)
+	}
+
+	return e.decode(object)
+}
+
+// shouldRefresh is the XFetch condition now - delta * beta * ln(rand) >= expiry.
+func (x *XFetch) shouldRefresh(e *entry) bool {
+	r := x.random()
+	if r <= 0 {
+		return true
+	}
+
+	gap := -float64(e.Delta) * x.beta * math.Log(r)
+	now := x.clock.Now().UnixNano()
+
+	return float64(now)+gap >= float64(e.Expiry)
+}
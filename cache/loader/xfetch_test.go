@@ -0,0 +1,128 @@
+package loader
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redistest"
+	andretime "github.com/AndreeJait/GO-ANDREE-UTILITIES/util/andreTime"
+)
+
+// clock is a fake time the loader functions advance to take time.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func Test_XFetchShouldRefresh(t *testing.T) {
+	now := time.Date(2020, time.August, 31, 0, 0, 0, 0, time.UTC)
+
+	// - an entry expiring in 1s and taking 100ms to compute is refreshed when
+	//   -100ms * beta * ln(rand) >= 1s, with beta 1 when rand <= e^-10
+	e := &entry{Delta: 100 * time.Millisecond, Expiry: now.Add(time.Second).UnixNano()}
+
+	newXFetch := func(beta, random float64) *XFetch {
+		x := NewXFetch(nil, &XFetchOption{Beta: beta, Time: andretime.NewFakeTimeAt(now)})
+		x.random = func() float64 { return random }
+		return x
+	}
+
+	t.Run("when far from expiry, do not refresh", func(t *testing.T) {
+		assert2.False(t, newXFetch(1, 0.5).shouldRefresh(e))
+		assert2.False(t, newXFetch(1, 1e-3).shouldRefresh(e))
+	})
+
+	t.Run("when the draw is low enough, refresh early", func(t *testing.T) {
+		assert2.True(t, newXFetch(1, 1e-5).shouldRefresh(e))
+		assert2.True(t, newXFetch(1, 0).shouldRefresh(e))
+	})
+
+	t.Run("when beta is higher, refresh earlier", func(t *testing.T) {
+		assert2.True(t, newXFetch(2, 1e-3).shouldRefresh(e))
+	})
+
+	t.Run("when expired, always refresh", func(t *testing.T) {
+		expired := &entry{Delta: 100 * time.Millisecond, Expiry: now.UnixNano()}
+		assert2.True(t, newXFetch(1, 0.999).shouldRefresh(expired))
+	})
+}
+
+func Test_XFetch(t *testing.T) {
+	_, c := redistest.NewClient(t)
+	now := &clock{now: time.Date(2020, time.August, 31, 0, 0, 0, 0, time.UTC)}
+
+	x := NewXFetch(c, &XFetchOption{Time: now})
+
+	// - every load take 100ms and return the number of the call
+	calls := make(chan int, 10)
+	count := 0
+	fn := func() (interface{}, error) {
+		now.Add(100 * time.Millisecond)
+		count++
+		calls <- count
+		return count, nil
+	}
+
+	stored := func() *entry {
+		e := &entry{}
+		assert2.NoError(t, c.Get("key", e))
+		return e
+	}
+
+	t.Run("when key does not exist, load and store it with its delta", func(t *testing.T) {
+		var value int
+		assert2.NoError(t, x.Fetch("key", time.Minute, &value, fn))
+		assert2.Equal(t, 1, value)
+		assert2.Equal(t, 1, <-calls)
+
+		e := stored()
+		assert2.Equal(t, 100*time.Millisecond, e.Delta)
+		assert2.Equal(t, now.Now().Add(time.Minute).UnixNano(), e.Expiry)
+
+		ttl, err := c.TTL("key")
+		assert2.NoError(t, err)
+		assert2.Equal(t, time.Minute, ttl)
+	})
+
+	t.Run("when key is far from expiry, return it without loading", func(t *testing.T) {
+		x.random = func() float64 { return 0.5 }
+
+		var value int
+		assert2.NoError(t, x.Fetch("key", time.Minute, &value, fn))
+		assert2.Equal(t, 1, value)
+		assert2.Len(t, calls, 0)
+	})
+
+	t.Run("when the draw is low enough, return it and recompute early", func(t *testing.T) {
+		// - 59s later the key expire in 900ms, -100ms * ln(1e-5) is 1.15s
+		now.Add(59 * time.Second)
+		x.random = func() float64 { return 1e-5 }
+
+		var value int
+		assert2.NoError(t, x.Fetch("key", time.Minute, &value, fn))
+		assert2.Equal(t, 1, value)
+		assert2.Equal(t, 2, <-calls)
+
+		assert2.Eventually(t, func() bool {
+			var value int
+			return stored().decode(&value) == nil && value == 2
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -25,6 +25,11 @@ type (
 		ReadTimeout  time.Duration
 		WriteTimeout time.Duration
 		MaxConnAge   time.Duration
+
+		MaxRetries      int
+		MinRetryBackoff time.Duration
+		MaxRetryBackoff time.Duration
+		Retry           *cache.RetryOption
 	}
 
 	redisClusterClient struct {
@@ -48,8 +53,18 @@ func New(option *Option) (cache.Cache, error) {
 		MinIdleConns: option.MinIdleConns,
 		MaxConnAge:   option.MaxConnAge,
 		ReadOnly:     option.ReadOnly,
+
+		MaxRetries:      option.MaxRetries,
+		MinRetryBackoff: option.MinRetryBackoff,
+		MaxRetryBackoff: option.MaxRetryBackoff,
 	})
 
+	if option.Retry != nil {
+		retrier := cache.NewRetrier(option.Retry)
+		client.WrapProcess(retrier.WrapProcess)
+		client.WrapProcessPipeline(retrier.WrapProcessPipeline)
+	}
+
 	if _, err := client.Ping().Result(); err != nil {
 		return nil, errors.Wrap(err, "Failed to connect to redis!")
 	}
@@ -501,3 +516,279 @@ func (c *redisClusterClient) IncrBy(key string, value int64) error {
 	return c.r.IncrBy(key, value).Err()
 }
 
+func (c *redisClusterClient) ConfigSet(parameter, value string) error {
+	if err := check(c); err != nil {
+		return err
+	}
+
+	err := c.r.ForEachNode(func(client *redis.Client) error {
+		return client.ConfigSet(parameter, value).Err()
+	})
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to set config %s!", parameter)
+	}
+
+	return nil
+}
+
+func (c *redisClusterClient) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	val, err := c.r.Eval(script, keys, args...).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to eval script!")
+	}
+
+	return val, nil
+}
+
+func (c *redisClusterClient) PFAdd(key string, elements ...interface{}) (bool, error) {
+	if err := check(c); err != nil {
+		return false, err
+	}
+
+	changed, err := c.r.PFAdd(key, elements...).Result()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to pfadd key %s!", key)
+	}
+
+	return changed == 1, nil
+}
+
+func (c *redisClusterClient) PFCount(keys ...string) (int64, error) {
+	if err := check(c); err != nil {
+		return 0, err
+	}
+
+	count, err := c.r.PFCount(keys...).Result()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to pfcount keys %v!", keys)
+	}
+
+	return count, nil
+}
+
+func (c *redisClusterClient) PFMerge(dest string, keys ...string) error {
+	if err := check(c); err != nil {
+		return err
+	}
+
+	if _, err := c.r.PFMerge(dest, keys...).Result(); err != nil {
+		return errors.Wrapf(err, "failed to pfmerge into key %s!", dest)
+	}
+
+	return nil
+}
+
+func (c *redisClusterClient) SetBits(key string, offsets ...int64) ([]bool, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.IntCmd, len(offsets))
+	_, err := c.r.Pipelined(func(p redis.Pipeliner) error {
+		for i, offset := range offsets {
+			cmds[i] = p.SetBit(key, offset, 1)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to setbit key %s!", key)
+	}
+
+	return bits(cmds), nil
+}
+
+func (c *redisClusterClient) GetBits(key string, offsets ...int64) ([]bool, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.IntCmd, len(offsets))
+	_, err := c.r.Pipelined(func(p redis.Pipeliner) error {
+		for i, offset := range offsets {
+			cmds[i] = p.GetBit(key, offset)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to getbit key %s!", key)
+	}
+
+	return bits(cmds), nil
+}
+
+func bits(cmds []*redis.IntCmd) []bool {
+	values := make([]bool, len(cmds))
+	for i, cmd := range cmds {
+		values[i] = cmd.Val() == 1
+	}
+
+	return values
+}
+
+// Scan iterate the keys of every master, callback is not called
+// concurrently.
+func (c *redisClusterClient) Scan(match string, count int64, callback cache.ScanCallback) error {
+	if err := check(c); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	return c.r.ForEachMaster(func(client *redis.Client) error {
+		return scan(client, match, count, func(keys []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return callback(keys)
+		})
+	})
+}
+
+func (c *redisClusterClient) Dump(key string) (string, error) {
+	if err := check(c); err != nil {
+		return "", err
+	}
+
+	val, err := c.r.Dump(key).Result()
+	if err == redis.Nil {
+		return "", errors.Wrapf(err, "key %s does not exits", key)
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to dump key %s!", key)
+	}
+
+	return val, nil
+}
+
+func (c *redisClusterClient) Restore(key string, ttl time.Duration, value string, replace bool) error {
+	if err := check(c); err != nil {
+		return err
+	}
+
+	restore := c.r.Restore
+	if replace {
+		restore = c.r.RestoreReplace
+	}
+
+	if _, err := restore(key, ttl, value).Result(); err != nil {
+		return errors.Wrapf(err, "failed to restore key %s!", key)
+	}
+
+	return nil
+}
+
+func (c *redisClusterClient) GeoAdd(key string, locations ...*redis.GeoLocation) (int64, error) {
+	if err := check(c); err != nil {
+		return 0, err
+	}
+
+	added, err := c.r.GeoAdd(key, locations...).Result()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to geoadd key %s!", key)
+	}
+
+	return added, nil
+}
+
+func (c *redisClusterClient) GeoPos(key string, members ...string) ([]*redis.GeoPos, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	positions, err := c.r.GeoPos(key, members...).Result()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to geopos key %s!", key)
+	}
+
+	return positions, nil
+}
+
+func (c *redisClusterClient) GeoDist(key, member1, member2, unit string) (float64, error) {
+	if err := check(c); err != nil {
+		return 0, err
+	}
+
+	dist, err := c.r.GeoDist(key, member1, member2, unit).Result()
+	if err == redis.Nil {
+		return 0, errors.Wrapf(err, "member %s or %s does not exits", member1, member2)
+	}
+
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to geodist key %s!", key)
+	}
+
+	return dist, nil
+}
+
+func (c *redisClusterClient) GeoRadius(key string, query *cache.GeoQuery) ([]redis.GeoLocation, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	var cmd *redis.GeoLocationCmd
+	if query.Member != "" {
+		cmd = c.r.GeoRadiusByMemberRO(key, query.Member, query.RadiusQuery())
+	} else {
+		cmd = c.r.GeoRadiusRO(key, query.Longitude, query.Latitude, query.RadiusQuery())
+	}
+
+	locations, err := cmd.Result()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to georadius key %s!", key)
+	}
+
+	return locations, nil
+}
+
+func (c *redisClusterClient) GeoSearch(key string, query *cache.GeoQuery) ([]redis.GeoLocation, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	args, err := query.SearchArgs(key)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := redis.NewCmd(args...)
+	if err := c.r.Process(cmd); err != nil {
+		return nil, errors.Wrapf(err, "failed to geosearch key %s!", key)
+	}
+
+	return query.ParseLocations(cmd.Val())
+}
+
+// scan run SCAN until the cursor come back to 0 and pass every non empty
+// batch to callback.
+func scan(client redis.Cmdable, match string, count int64, callback cache.ScanCallback) error {
+	var cursor uint64
+
+	for {
+		keys, next, err := client.Scan(cursor, match, count).Result()
+		if err != nil {
+			return errors.Wrapf(err, "failed to scan redis pattern %s!", match)
+		}
+
+		if len(keys) > 0 {
+			if err := callback(keys); err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
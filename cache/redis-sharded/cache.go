@@ -0,0 +1,698 @@
+package redis_sharded
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	standalone "github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redis"
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Option configure a sharded client, Nodes is keyed by a stable node
+	// name instead of the address so a node can be moved to another host
+	// without remapping its keys.
+	Option struct {
+		Nodes        map[string]*standalone.Option
+		VirtualNodes int
+	}
+
+	// ShardedCache is a cache.Cache that spread keys across independent
+	// redis nodes, nodes can be added or removed at runtime.
+	ShardedCache interface {
+		cache.Cache
+
+		// AddNode connect a node, the keys it now own are read from it, keys
+		// stored on their previous owner are not migrated.
+		AddNode(name string, option *standalone.Option) error
+
+		// RemoveNode disconnect a node, the keys it owned are orphaned, not
+		// migrated, they stay on the node and are missed until rewritten on
+		// their new owner.
+		RemoveNode(name string) error
+
+		Nodes() []string
+	}
+
+	redisShardedClient struct {
+		mu    sync.RWMutex
+		ring  *ring
+		nodes map[string]cache.Cache
+	}
+)
+
+func New(option *Option) (ShardedCache, error) {
+	c := &redisShardedClient{
+		ring:  newRing(option.VirtualNodes),
+		nodes: make(map[string]cache.Cache),
+	}
+
+	for name, nodeOption := range option.Nodes {
+		if err := c.AddNode(name, nodeOption); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *redisShardedClient) AddNode(name string, option *standalone.Option) error {
+	client, err := standalone.New(option)
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to node %s!", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.nodes[name]; ok {
+		_ = client.Close()
+		return errors.Errorf("node %s already exists", name)
+	}
+
+	c.nodes[name] = client
+	c.ring.add(name)
+	return nil
+}
+
+func (c *redisShardedClient) RemoveNode(name string) error {
+	c.mu.Lock()
+	client, ok := c.nodes[name]
+	if ok {
+		delete(c.nodes, name)
+		c.ring.remove(name)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return errors.Errorf("node %s does not exists", name)
+	}
+
+	if err := client.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close node %s", name)
+	}
+
+	return nil
+}
+
+func (c *redisShardedClient) Nodes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.nodes))
+	for name := range c.nodes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (c *redisShardedClient) Ping() error {
+	return c.each(func(name string, node cache.Cache) error {
+		return node.Ping()
+	})
+}
+
+func (c *redisShardedClient) SetWithExpiration(key string, value interface{}, duration time.Duration) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.SetWithExpiration(key, value, duration)
+}
+
+func (c *redisShardedClient) Set(key string, value interface{}) error {
+	return c.SetWithExpiration(key, value, 0)
+}
+
+func (c *redisShardedClient) Get(key string, data interface{}) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.Get(key, data)
+}
+
+func (c *redisShardedClient) Keys(pattern string) ([]string, error) {
+	var (
+		mu   sync.Mutex
+		keys = make([]string, 0)
+	)
+
+	err := c.each(func(name string, node cache.Cache) error {
+		found, err := node.Keys(pattern)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		keys = append(keys, found...)
+		mu.Unlock()
+		return nil
+	})
+
+	return keys, err
+}
+
+func (c *redisShardedClient) Remove(key string) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.Remove(key)
+}
+
+func (c *redisShardedClient) RemoveByPattern(pattern string, countPerLoop int64) error {
+	return c.each(func(name string, node cache.Cache) error {
+		return node.RemoveByPattern(pattern, countPerLoop)
+	})
+}
+
+func (c *redisShardedClient) FlushDatabase() error {
+	return c.each(func(name string, node cache.Cache) error {
+		return node.FlushDatabase()
+	})
+}
+
+func (c *redisShardedClient) FlushAll() error {
+	return c.each(func(name string, node cache.Cache) error {
+		return node.FlushAll()
+	})
+}
+
+func (c *redisShardedClient) Close() error {
+	return c.each(func(name string, node cache.Cache) error {
+		return node.Close()
+	})
+}
+
+func (c *redisShardedClient) SetZSetWithExpiration(key string, duration time.Duration, data ...redis.Z) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.SetZSetWithExpiration(key, duration, data...)
+}
+
+func (c *redisShardedClient) SetZSet(key string, data ...redis.Z) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.SetZSet(key, data...)
+}
+
+func (c *redisShardedClient) GetZSet(key string) ([]redis.Z, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.GetZSet(key)
+}
+
+func (c *redisShardedClient) HMSetWithExpiration(key string, value map[string]interface{}, ttl time.Duration) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.HMSetWithExpiration(key, value, ttl)
+}
+
+func (c *redisShardedClient) HMSet(key string, value map[string]interface{}) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.HMSet(key, value)
+}
+
+func (c *redisShardedClient) HSetWithExpiration(key, field string, value interface{}, ttl time.Duration) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.HSetWithExpiration(key, field, value, ttl)
+}
+
+func (c *redisShardedClient) HSet(key, field string, value interface{}) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.HSet(key, field, value)
+}
+
+func (c *redisShardedClient) HMGet(key string, fields ...string) ([]interface{}, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.HMGet(key, fields...)
+}
+
+func (c *redisShardedClient) HGetAll(key string) (map[string]string, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.HGetAll(key)
+}
+
+func (c *redisShardedClient) HGet(key, field string, response interface{}) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.HGet(key, field, response)
+}
+
+func (c *redisShardedClient) MGet(keys []string) ([]interface{}, error) {
+	var (
+		mu     sync.Mutex
+		values = make([]interface{}, len(keys))
+	)
+
+	err := c.group(keys, func(node cache.Cache, indexes []int) error {
+		nodeKeys := make([]string, len(indexes))
+		for i, index := range indexes {
+			nodeKeys[i] = keys[index]
+		}
+
+		found, err := node.MGet(nodeKeys)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		for i, index := range indexes {
+			if i < len(found) {
+				values[index] = found[i]
+			}
+		}
+		mu.Unlock()
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (c *redisShardedClient) MSetWithExpiration(keys []string, values []interface{}, ttls []time.Duration) error {
+	if len(values) < len(keys) || len(ttls) < len(keys) {
+		return errors.New("values or ttls must have same count with keys")
+	}
+
+	return c.group(keys, func(node cache.Cache, indexes []int) error {
+		nodeKeys := make([]string, len(indexes))
+		nodeValues := make([]interface{}, len(indexes))
+		nodeTTLs := make([]time.Duration, len(indexes))
+
+		for i, index := range indexes {
+			nodeKeys[i] = keys[index]
+			nodeValues[i] = values[index]
+			nodeTTLs[i] = ttls[index]
+		}
+
+		return node.MSetWithExpiration(nodeKeys, nodeValues, nodeTTLs)
+	})
+}
+
+func (c *redisShardedClient) MSet(keys []string, values []interface{}) error {
+	if len(values) < len(keys) {
+		return errors.New("values or ttls must have same count with keys")
+	}
+
+	return c.group(keys, func(node cache.Cache, indexes []int) error {
+		nodeKeys := make([]string, len(indexes))
+		nodeValues := make([]interface{}, len(indexes))
+
+		for i, index := range indexes {
+			nodeKeys[i] = keys[index]
+			nodeValues[i] = values[index]
+		}
+
+		return node.MSet(nodeKeys, nodeValues)
+	})
+}
+
+func (c *redisShardedClient) SetNx(key string, value interface{}, ttl time.Duration) (bool, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return false, err
+	}
+
+	return node.SetNx(key, value, ttl)
+}
+
+func (c *redisShardedClient) Client() cache.Cache {
+	return c
+}
+
+func (c *redisShardedClient) Pipeline() cache.Pipe {
+	return &pipe{c: c, pipes: make(map[string]cache.Pipe)}
+}
+
+// Subscribe subscribe to the node owning the channel name, publisher and
+// subscriber of the same channel always meet on the same node.
+func (c *redisShardedClient) Subscribe(channel string) (cache.PubSub, error) {
+	node, err := c.node(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Subscribe(channel)
+}
+
+func (c *redisShardedClient) HDel(key string, fields ...string) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.HDel(key, fields...)
+}
+
+func (c *redisShardedClient) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return node.ZIncrBy(key, increment, member)
+}
+
+func (c *redisShardedClient) TTL(key string) (time.Duration, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return node.TTL(key)
+}
+
+func (c *redisShardedClient) Incr(key string) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.Incr(key)
+}
+
+func (c *redisShardedClient) IncrBy(key string, value int64) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.IncrBy(key, value)
+}
+
+func (c *redisShardedClient) ConfigSet(parameter, value string) error {
+	return c.each(func(name string, node cache.Cache) error {
+		return node.ConfigSet(parameter, value)
+	})
+}
+
+// Eval run the script on the node owning the keys.
+func (c *redisShardedClient) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("eval on sharded client require at least one key")
+	}
+
+	node, err := c.colocated(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Eval(script, keys, args...)
+}
+
+func (c *redisShardedClient) PFAdd(key string, elements ...interface{}) (bool, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return false, err
+	}
+
+	return node.PFAdd(key, elements...)
+}
+
+// PFCount count the union of keys owned by the same node, keys owned by
+// different nodes are rejected.
+func (c *redisShardedClient) PFCount(keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, errors.New("pfcount require at least one key")
+	}
+
+	node, err := c.colocated(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	return node.PFCount(keys...)
+}
+
+// PFMerge merge keys owned by the same node as dest, keys owned by
+// different nodes are rejected.
+func (c *redisShardedClient) PFMerge(dest string, keys ...string) error {
+	node, err := c.colocated(append([]string{dest}, keys...))
+	if err != nil {
+		return err
+	}
+
+	return node.PFMerge(dest, keys...)
+}
+
+func (c *redisShardedClient) SetBits(key string, offsets ...int64) ([]bool, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.SetBits(key, offsets...)
+}
+
+func (c *redisShardedClient) GetBits(key string, offsets ...int64) ([]bool, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.GetBits(key, offsets...)
+}
+
+// Scan iterate the keys of every node, callback is not called
+// concurrently.
+func (c *redisShardedClient) Scan(match string, count int64, callback cache.ScanCallback) error {
+	var mu sync.Mutex
+
+	return c.each(func(name string, node cache.Cache) error {
+		return node.Scan(match, count, func(keys []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return callback(keys)
+		})
+	})
+}
+
+func (c *redisShardedClient) Dump(key string) (string, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return "", err
+	}
+
+	return node.Dump(key)
+}
+
+func (c *redisShardedClient) Restore(key string, ttl time.Duration, value string, replace bool) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.Restore(key, ttl, value, replace)
+}
+
+func (c *redisShardedClient) GeoAdd(key string, locations ...*redis.GeoLocation) (int64, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return node.GeoAdd(key, locations...)
+}
+
+func (c *redisShardedClient) GeoPos(key string, members ...string) ([]*redis.GeoPos, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.GeoPos(key, members...)
+}
+
+func (c *redisShardedClient) GeoDist(key, member1, member2, unit string) (float64, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return node.GeoDist(key, member1, member2, unit)
+}
+
+func (c *redisShardedClient) GeoRadius(key string, query *cache.GeoQuery) ([]redis.GeoLocation, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.GeoRadius(key, query)
+}
+
+func (c *redisShardedClient) GeoSearch(key string, query *cache.GeoQuery) ([]redis.GeoLocation, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.GeoSearch(key, query)
+}
+
+// - private
+
+func (c *redisShardedClient) node(key string) (cache.Cache, error) {
+	name, node, err := c.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if node == nil {
+		return nil, errors.Errorf("node %s is not connected", name)
+	}
+
+	return node, nil
+}
+
+// colocated return the node owning every key, multi-key commands can not
+// span nodes so keys owned by different nodes are rejected, use a hash tag
+// to place them on the same node.
+func (c *redisShardedClient) colocated(keys []string) (cache.Cache, error) {
+	name, node, err := c.lookup(keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys[1:] {
+		if other, _, _ := c.lookup(key); other != name {
+			return nil, errors.Errorf("keys %s and %s are owned by different nodes", keys[0], key)
+		}
+	}
+
+	if node == nil {
+		return nil, errors.Errorf("node %s is not connected", name)
+	}
+
+	return node, nil
+}
+
+func (c *redisShardedClient) lookup(key string) (string, cache.Cache, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.ring.empty() {
+		return "", nil, errors.New("redis sharded client has no nodes")
+	}
+
+	name := c.ring.get(key)
+	return name, c.nodes[name], nil
+}
+
+// each run callback concurrently on every node and join the errors.
+func (c *redisShardedClient) each(callback func(name string, node cache.Cache) error) error {
+	c.mu.RLock()
+	nodes := make(map[string]cache.Cache, len(c.nodes))
+	for name, node := range c.nodes {
+		nodes[name] = node
+	}
+	c.mu.RUnlock()
+
+	return fanout(nodes, func(name string, node cache.Cache) error {
+		return callback(name, node)
+	})
+}
+
+// group partition keys by their owner node and run callback concurrently
+// with the indexes of the keys owned by each node.
+func (c *redisShardedClient) group(keys []string, callback func(node cache.Cache, indexes []int) error) error {
+	c.mu.RLock()
+	if c.ring.empty() {
+		c.mu.RUnlock()
+		return errors.New("redis sharded client has no nodes")
+	}
+
+	nodes := make(map[string]cache.Cache)
+	indexes := make(map[string][]int)
+	for i, key := range keys {
+		name := c.ring.get(key)
+		nodes[name] = c.nodes[name]
+		indexes[name] = append(indexes[name], i)
+	}
+	c.mu.RUnlock()
+
+	return fanout(nodes, func(name string, node cache.Cache) error {
+		return callback(node, indexes[name])
+	})
+}
+
+func fanout(nodes map[string]cache.Cache, callback func(name string, node cache.Cache) error) error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []string
+	)
+
+	for name, node := range nodes {
+		wg.Add(1)
+		go func(name string, node cache.Cache) {
+			defer wg.Done()
+
+			if err := callback(name, node); err != nil {
+				mu.Lock()
+				failed = append(failed, name+": "+err.Error())
+				mu.Unlock()
+			}
+		}(name, node)
+	}
+
+	wg.Wait()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return errors.New("failed on some nodes " + strings.Join(failed, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package redis_sharded
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	assert2 "github.com/stretchr/testify/assert"
+
+	standalone "github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redis"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redistest"
+)
+
+// newServers start a redistest server per name, they are closed when the
+// test ends.
+func newServers(t *testing.T, names ...string) map[string]*redistest.Server {
+	servers := make(map[string]*redistest.Server, len(names))
+
+	for _, name := range names {
+		s, err := redistest.New()
+		assert2.NoError(t, err)
+		t.Cleanup(func() {
+			_ = s.Close()
+		})
+
+		servers[name] = s
+	}
+
+	return servers
+}
+
+// newSharded return a client sharding over servers.
+func newSharded(t *testing.T, servers map[string]*redistest.Server) *redisShardedClient {
+	nodes := make(map[string]*standalone.Option, len(servers))
+	for name, s := range servers {
+		nodes[name] = &standalone.Option{Address: s.Addr()}
+	}
+
+	c, err := New(&Option{Nodes: nodes})
+	assert2.NoError(t, err)
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+
+	return c.(*redisShardedClient)
+}
+
+// owners return the keys stored by each server.
+func owners(servers map[string]*redistest.Server) map[string][]string {
+	stored := make(map[string][]string, len(servers))
+	for name, s := range servers {
+		keys := s.Keys(0)
+		sort.Strings(keys)
+		stored[name] = keys
+	}
+
+	return stored
+}
+
+func keyRange(prefix string, n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s:%d", prefix, i)
+	}
+
+	return keys
+}
+
+func Test_ShardedCache(t *testing.T) {
+
+	t.Run("when setting many keys, store each on its owner and get them in order", func(t *testing.T) {
+		servers := newServers(t, "a", "b", "c")
+		c := newSharded(t, servers)
+
+		keys := keyRange("user", 30)
+		values := make([]interface{}, len(keys))
+		for i := range keys {
+			values[i] = fmt.Sprintf("value:%d", i)
+		}
+
+		assert2.NoError(t, c.MSet(keys, values))
+
+		stored := owners(servers)
+		for _, key := range keys {
+			assert2.Contains(t, stored[c.ring.get(key)], key)
+		}
+		assert2.NotEmpty(t, stored["a"])
+		assert2.NotEmpty(t, stored["b"])
+		assert2.NotEmpty(t, stored["c"])
+
+		found, err := c.MGet(append([]string{"missing"}, keys...))
+		assert2.NoError(t, err)
+		assert2.Nil(t, found[0])
+		assert2.Equal(t, values, found[1:])
+	})
+
+	t.Run("when listing or removing by pattern, run on every node", func(t *testing.T) {
+		servers := newServers(t, "a", "b", "c")
+		c := newSharded(t, servers)
+
+		keys := keyRange("user", 20)
+		for _, key := range keys {
+			assert2.NoError(t, c.Set(key, "1"))
+		}
+		assert2.NoError(t, c.Set("order:1", "1"))
+
+		found, err := c.Keys("user:*")
+		assert2.NoError(t, err)
+		assert2.ElementsMatch(t, keys, found)
+
+		assert2.NoError(t, c.RemoveByPattern("user:*", 5))
+
+		found, err = c.Keys("*")
+		assert2.NoError(t, err)
+		assert2.Equal(t, []string{"order:1"}, found)
+	})
+
+	t.Run("when pipelining, open one pipeline per owner node", func(t *testing.T) {
+		servers := newServers(t, "a", "b", "c")
+		c := newSharded(t, servers)
+
+		keys := keyRange("user", 30)
+		p := c.Pipeline().(*pipe)
+		for _, key := range keys {
+			assert2.NoError(t, p.Set(key, "1"))
+		}
+
+		used := make(map[string]bool)
+		for _, key := range keys {
+			used[c.ring.get(key)] = true
+		}
+		assert2.Len(t, p.pipes, len(used))
+
+		found, err := c.Keys("*")
+		assert2.NoError(t, err)
+		assert2.Empty(t, found)
+
+		assert2.NoError(t, p.Exec())
+		assert2.Empty(t, p.pipes)
+
+		stored := owners(servers)
+		for _, key := range keys {
+			assert2.Contains(t, stored[c.ring.get(key)], key)
+		}
+	})
+
+	t.Run("when multi-key commands span nodes, reject them", func(t *testing.T) {
+		servers := newServers(t, "a", "b", "c")
+		c := newSharded(t, servers)
+
+		// - find two keys owned by different nodes
+		keys := keyRange("visitors", 30)
+		other := ""
+		for _, key := range keys[1:] {
+			if c.ring.get(key) != c.ring.get(keys[0]) {
+				other = key
+				break
+			}
+		}
+		assert2.NotEmpty(t, other)
+
+		_, err := c.PFCount(keys[0], other)
+		assert2.Error(t, err)
+		assert2.Error(t, c.PFMerge(keys[0], other))
+
+		_, err = c.Eval("return 1", []string{keys[0], other})
+		assert2.Error(t, err)
+
+		_, err = c.Eval("return 1", nil)
+		assert2.Error(t, err)
+
+		// - a hash tag place the keys on the same node
+		_, err = c.PFAdd("{visitors}:today", "a", "b")
+		assert2.NoError(t, err)
+		_, err = c.PFAdd("{visitors}:yesterday", "b", "c")
+		assert2.NoError(t, err)
+
+		count, err := c.PFCount("{visitors}:today", "{visitors}:yesterday")
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(3), count)
+	})
+
+	t.Run("when adding a node, route the keys it own to it", func(t *testing.T) {
+		servers := newServers(t, "a", "b", "c")
+		c := newSharded(t, map[string]*redistest.Server{"a": servers["a"], "b": servers["b"]})
+
+		assert2.NoError(t, c.AddNode("c", &standalone.Option{Address: servers["c"].Addr()}))
+		assert2.Equal(t, []string{"a", "b", "c"}, c.Nodes())
+
+		err := c.AddNode("c", &standalone.Option{Address: servers["c"].Addr()})
+		assert2.Error(t, err)
+
+		keys := keyRange("user", 30)
+		for _, key := range keys {
+			assert2.NoError(t, c.Set(key, "1"))
+		}
+
+		stored := owners(servers)
+		assert2.NotEmpty(t, stored["c"])
+		for _, key := range keys {
+			assert2.Contains(t, stored[c.ring.get(key)], key)
+		}
+	})
+
+	t.Run("when removing a node, orphan the keys it owned", func(t *testing.T) {
+		servers := newServers(t, "a", "b", "c")
+		c := newSharded(t, servers)
+
+		keys := keyRange("user", 30)
+		before := make(map[string]string, len(keys))
+		for _, key := range keys {
+			assert2.NoError(t, c.Set(key, "1"))
+			before[key] = c.ring.get(key)
+		}
+
+		assert2.NoError(t, c.RemoveNode("c"))
+		assert2.Equal(t, []string{"a", "b"}, c.Nodes())
+		assert2.Error(t, c.RemoveNode("c"))
+
+		var value text
+		for _, key := range keys {
+			if before[key] == "c" {
+				// - the key stay on the removed node, it is not migrated
+				assert2.Contains(t, servers["c"].Keys(0), key)
+				assert2.Error(t, c.Get(key, &value))
+				continue
+			}
+
+			assert2.NoError(t, c.Get(key, &value))
+		}
+	})
+}
+
+type text string
+
+func (t *text) UnmarshalBinary(data []byte) error {
+	*t = text(data)
+	return nil
+}
@@ -0,0 +1,76 @@
+package redis_sharded
+
+import (
+	"strings"
+	"time"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	"github.com/pkg/errors"
+)
+
+type (
+	// pipe lazily open one pipeline per node and route every command to
+	// the pipeline of the node owning the key.
+	pipe struct {
+		c     *redisShardedClient
+		pipes map[string]cache.Pipe
+	}
+)
+
+func (p *pipe) Set(key string, value interface{}) error {
+	return p.SetWithExpiration(key, value, 0)
+}
+
+func (p *pipe) SetWithExpiration(key string, value interface{}, expired time.Duration) error {
+	instance, err := p.node(key)
+	if err != nil {
+		return err
+	}
+
+	return instance.SetWithExpiration(key, value, expired)
+}
+
+func (p *pipe) Get(key string, object interface{}) error {
+	instance, err := p.node(key)
+	if err != nil {
+		return err
+	}
+
+	return instance.Get(key, object)
+}
+
+func (p *pipe) Exec() error {
+	var failed []string
+
+	for name, instance := range p.pipes {
+		if err := instance.Exec(); err != nil {
+			failed = append(failed, name+": "+err.Error())
+		}
+	}
+
+	p.pipes = make(map[string]cache.Pipe)
+
+	if len(failed) > 0 {
+		return errors.New("failed to exec sharded pipeline " + strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+func (p *pipe) node(key string) (cache.Pipe, error) {
+	name, node, err := p.c.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if instance, ok := p.pipes[name]; ok {
+		return instance, nil
+	}
+
+	if node == nil {
+		return nil, errors.Errorf("node %s is not connected", name)
+	}
+
+	p.pipes[name] = node.Pipeline()
+	return p.pipes[name], nil
+}
@@ -0,0 +1,97 @@
+package redis_sharded
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DefaultVirtualNodes = 160
+
+type (
+	// ring is a consistent hash ring, every node is placed on the ring
+	// replicas times so keys are spread evenly and adding or removing a
+	// node only remaps the keys owned by that node.
+	ring struct {
+		replicas int
+		hashes   []uint32
+		owners   map[uint32]string
+	}
+)
+
+func newRing(replicas int) *ring {
+	if replicas <= 0 {
+		replicas = DefaultVirtualNodes
+	}
+
+	return &ring{replicas: replicas, owners: make(map[uint32]string)}
+}
+
+func (r *ring) add(name string) {
+	for i := 0; i < r.replicas; i++ {
+		h := crc32.ChecksumIEEE([]byte(name + "#" + strconv.Itoa(i)))
+		if _, ok := r.owners[h]; ok {
+			continue
+		}
+
+		r.owners[h] = name
+		r.hashes = append(r.hashes, h)
+	}
+
+	sort.Slice(r.hashes, func(l, k int) bool {
+		return r.hashes[l] < r.hashes[k]
+	})
+}
+
+func (r *ring) remove(name string) {
+	hashes := r.hashes[:0]
+
+	for _, h := range r.hashes {
+		if r.owners[h] == name {
+			delete(r.owners, h)
+			continue
+		}
+		hashes = append(hashes, h)
+	}
+
+	r.hashes = hashes
+}
+
+func (r *ring) empty() bool {
+	return len(r.hashes) == 0
+}
+
+// get return the node name owning the given key, keys sharing the same
+// hash tag (`{user:1}:profile`, `{user:1}:cart`) are always placed on the
+// same node.
+func (r *ring) get(key string) string {
+	if r.empty() {
+		return ""
+	}
+
+	h := crc32.ChecksumIEEE([]byte(hashTag(key)))
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= h
+	})
+
+	if i == len(r.hashes) {
+		i = 0
+	}
+
+	return r.owners[r.hashes[i]]
+}
+
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+
+	return key[start+1 : start+1+end]
+}
@@ -0,0 +1,57 @@
+package redis_sharded
+
+import (
+	"fmt"
+	"testing"
+
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func Test_Ring(t *testing.T) {
+
+	t.Run("when ring is empty", func(t *testing.T) {
+		r := newRing(0)
+
+		assert2.True(t, r.empty())
+		assert2.Equal(t, "", r.get("key"))
+	})
+
+	t.Run("when keys share a hash tag", func(t *testing.T) {
+		r := newRing(0)
+		for _, name := range []string{"a", "b", "c", "d"} {
+			r.add(name)
+		}
+
+		for i := 0; i < 100; i++ {
+			assert2.Equal(t, r.get("{user:1}:profile"), r.get(fmt.Sprintf("{user:1}:item:%d", i)))
+		}
+	})
+
+	t.Run("when a node is removed only its keys are remapped", func(t *testing.T) {
+		r := newRing(0)
+		for _, name := range []string{"a", "b", "c", "d"} {
+			r.add(name)
+		}
+
+		before := make(map[string]string)
+		for i := 0; i < 10000; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			before[key] = r.get(key)
+		}
+
+		r.remove("c")
+
+		used := make(map[string]int)
+		for key, owner := range before {
+			after := r.get(key)
+			used[after]++
+
+			if owner != "c" {
+				assert2.Equal(t, owner, after)
+			}
+		}
+
+		assert2.NotContains(t, used, "c")
+		assert2.Len(t, used, 3)
+	})
+}
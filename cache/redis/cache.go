@@ -25,6 +25,11 @@ type (
 		ReadTimeout  time.Duration
 		WriteTimeout time.Duration
 		MaxConnAge   time.Duration
+
+		MaxRetries      int
+		MinRetryBackoff time.Duration
+		MaxRetryBackoff time.Duration
+		Retry           *cache.RetryOption
 	}
 
 	redisClient struct {
@@ -48,8 +53,18 @@ func New(option *Option) (cache.Cache, error) {
 		DialTimeout:  option.DialTimeout,
 		MinIdleConns: option.MinIdleConns,
 		MaxConnAge:   option.MaxConnAge,
+
+		MaxRetries:      option.MaxRetries,
+		MinRetryBackoff: option.MinRetryBackoff,
+		MaxRetryBackoff: option.MaxRetryBackoff,
 	})
 
+	if option.Retry != nil {
+		retrier := cache.NewRetrier(option.Retry)
+		client.WrapProcess(retrier.WrapProcess)
+		client.WrapProcessPipeline(retrier.WrapProcessPipeline)
+	}
+
 	if _, err := client.Ping().Result(); err != nil {
 		return nil, errors.Wrap(err, "Failed to connect to redis!")
 	}
@@ -451,6 +466,20 @@ func (c *redisClient) Subscribe(channel string) (cache.PubSub, error) {
 	return c.channels[channel], nil
 }
 
+// NewPubSub subscribe to channels with a pubsub not shared with Subscribe,
+// every channel is received on the same connection.
+func (c *redisClient) NewPubSub(channels ...string) (cache.PubSub, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	if len(channels) == 0 {
+		return nil, errors.New("pubsub require at least one channel")
+	}
+
+	return &pubsub{r: c.r, p: c.r.Subscribe(channels...), cn: channels[0]}, nil
+}
+
 func (c *redisClient) HDel(key string, fields ...string) error {
 	if err := check(c); err != nil {
 		return err
@@ -500,3 +529,266 @@ func (c *redisClient) IncrBy(key string, value int64) error {
 
 	return c.r.IncrBy(key, value).Err()
 }
+
+func (c *redisClient) ConfigSet(parameter, value string) error {
+	if err := check(c); err != nil {
+		return err
+	}
+
+	if _, err := c.r.ConfigSet(parameter, value).Result(); err != nil {
+		return errors.Wrapf(err, "failed to set config %s!", parameter)
+	}
+
+	return nil
+}
+
+func (c *redisClient) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	val, err := c.r.Eval(script, keys, args...).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to eval script!")
+	}
+
+	return val, nil
+}
+
+func (c *redisClient) PFAdd(key string, elements ...interface{}) (bool, error) {
+	if err := check(c); err != nil {
+		return false, err
+	}
+
+	changed, err := c.r.PFAdd(key, elements...).Result()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to pfadd key %s!", key)
+	}
+
+	return changed == 1, nil
+}
+
+func (c *redisClient) PFCount(keys ...string) (int64, error) {
+	if err := check(c); err != nil {
+		return 0, err
+	}
+
+	count, err := c.r.PFCount(keys...).Result()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to pfcount keys %v!", keys)
+	}
+
+	return count, nil
+}
+
+func (c *redisClient) PFMerge(dest string, keys ...string) error {
+	if err := check(c); err != nil {
+		return err
+	}
+
+	if _, err := c.r.PFMerge(dest, keys...).Result(); err != nil {
+		return errors.Wrapf(err, "failed to pfmerge into key %s!", dest)
+	}
+
+	return nil
+}
+
+func (c *redisClient) SetBits(key string, offsets ...int64) ([]bool, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.IntCmd, len(offsets))
+	_, err := c.r.Pipelined(func(p redis.Pipeliner) error {
+		for i, offset := range offsets {
+			cmds[i] = p.SetBit(key, offset, 1)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to setbit key %s!", key)
+	}
+
+	return bits(cmds), nil
+}
+
+func (c *redisClient) GetBits(key string, offsets ...int64) ([]bool, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.IntCmd, len(offsets))
+	_, err := c.r.Pipelined(func(p redis.Pipeliner) error {
+		for i, offset := range offsets {
+			cmds[i] = p.GetBit(key, offset)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to getbit key %s!", key)
+	}
+
+	return bits(cmds), nil
+}
+
+func bits(cmds []*redis.IntCmd) []bool {
+	values := make([]bool, len(cmds))
+	for i, cmd := range cmds {
+		values[i] = cmd.Val() == 1
+	}
+
+	return values
+}
+
+func (c *redisClient) Scan(match string, count int64, callback cache.ScanCallback) error {
+	if err := check(c); err != nil {
+		return err
+	}
+
+	return scan(c.r, match, count, callback)
+}
+
+func (c *redisClient) Dump(key string) (string, error) {
+	if err := check(c); err != nil {
+		return "", err
+	}
+
+	val, err := c.r.Dump(key).Result()
+	if err == redis.Nil {
+		return "", errors.Wrapf(err, "key %s does not exits", key)
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to dump key %s!", key)
+	}
+
+	return val, nil
+}
+
+func (c *redisClient) Restore(key string, ttl time.Duration, value string, replace bool) error {
+	if err := check(c); err != nil {
+		return err
+	}
+
+	restore := c.r.Restore
+	if replace {
+		restore = c.r.RestoreReplace
+	}
+
+	if _, err := restore(key, ttl, value).Result(); err != nil {
+		return errors.Wrapf(err, "failed to restore key %s!", key)
+	}
+
+	return nil
+}
+
+func (c *redisClient) GeoAdd(key string, locations ...*redis.GeoLocation) (int64, error) {
+	if err := check(c); err != nil {
+		return 0, err
+	}
+
+	added, err := c.r.GeoAdd(key, locations...).Result()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to geoadd key %s!", key)
+	}
+
+	return added, nil
+}
+
+func (c *redisClient) GeoPos(key string, members ...string) ([]*redis.GeoPos, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	positions, err := c.r.GeoPos(key, members...).Result()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to geopos key %s!", key)
+	}
+
+	return positions, nil
+}
+
+func (c *redisClient) GeoDist(key, member1, member2, unit string) (float64, error) {
+	if err := check(c); err != nil {
+		return 0, err
+	}
+
+	dist, err := c.r.GeoDist(key, member1, member2, unit).Result()
+	if err == redis.Nil {
+		return 0, errors.Wrapf(err, "member %s or %s does not exits", member1, member2)
+	}
+
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to geodist key %s!", key)
+	}
+
+	return dist, nil
+}
+
+func (c *redisClient) GeoRadius(key string, query *cache.GeoQuery) ([]redis.GeoLocation, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	var cmd *redis.GeoLocationCmd
+	if query.Member != "" {
+		cmd = c.r.GeoRadiusByMemberRO(key, query.Member, query.RadiusQuery())
+	} else {
+		cmd = c.r.GeoRadiusRO(key, query.Longitude, query.Latitude, query.RadiusQuery())
+	}
+
+	locations, err := cmd.Result()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to georadius key %s!", key)
+	}
+
+	return locations, nil
+}
+
+func (c *redisClient) GeoSearch(key string, query *cache.GeoQuery) ([]redis.GeoLocation, error) {
+	if err := check(c); err != nil {
+		return nil, err
+	}
+
+	args, err := query.SearchArgs(key)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := redis.NewCmd(args...)
+	if err := c.r.Process(cmd); err != nil {
+		return nil, errors.Wrapf(err, "failed to geosearch key %s!", key)
+	}
+
+	return query.ParseLocations(cmd.Val())
+}
+
+// scan run SCAN until the cursor come back to 0 and pass every non empty
+// batch to callback.
+func scan(client redis.Cmdable, match string, count int64, callback cache.ScanCallback) error {
+	var cursor uint64
+
+	for {
+		keys, next, err := client.Scan(cursor, match, count).Result()
+		if err != nil {
+			return errors.Wrapf(err, "failed to scan redis pattern %s!", match)
+		}
+
+		if len(keys) > 0 {
+			if err := callback(keys); err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
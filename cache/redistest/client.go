@@ -0,0 +1,34 @@
+package redistest
+
+import (
+	"testing"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redis"
+)
+
+// NewClient start a Server and return it with a redis client connected to
+// it, both are closed when the test ends.
+//
+//	s, c := redistest.NewClient(t)
+func NewClient(t testing.TB) (*Server, cache.Cache) {
+	t.Helper()
+
+	s, err := New()
+	if err != nil {
+		t.Fatalf("failed to start redistest server: %v", err)
+	}
+
+	c, err := redis.New(&redis.Option{Address: s.Addr()})
+	if err != nil {
+		_ = s.Close()
+		t.Fatalf("failed to connect to redistest server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = s.Close()
+	})
+
+	return s, c
+}
@@ -0,0 +1,1562 @@
+package redistest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	errWrongType = failure("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInt    = failure("ERR value is not an integer or out of range")
+	errNotFloat  = failure("ERR value is not a valid float")
+	errSyntax    = failure("ERR syntax error")
+)
+
+// command mirror the COMMAND reply of redis, the cluster clients use the
+// key positions to route commands and the flag to route reads to replicas.
+type command struct {
+	// arity is the exact number of arguments including the command name,
+	// a negative arity is a minimum.
+	arity int
+	flag  string
+
+	firstKey, lastKey, step int
+
+	fn func(c *conn, args []string) interface{}
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		// connection
+		"ping":     {-1, "", 0, 0, 0, ping},
+		"echo":     {2, "", 0, 0, 0, echo},
+		"select":   {2, "", 0, 0, 0, selectDB},
+		"auth":     {2, "", 0, 0, 0, auth},
+		"client":   {-2, "", 0, 0, 0, ok},
+		"readonly": {1, "", 0, 0, 0, ok},
+		"command":  {-1, "", 0, 0, 0, commandInfo},
+		"cluster":  {-2, "", 0, 0, 0, cluster},
+		"config":   {-3, "write", 0, 0, 0, config},
+
+		// keys
+		"del":      {-2, "write", 1, -1, 1, del},
+		"unlink":   {-2, "write", 1, -1, 1, del},
+		"exists":   {-2, "readonly", 1, -1, 1, exists},
+		"expire":   {3, "write", 1, 1, 1, expire},
+		"pexpire":  {3, "write", 1, 1, 1, expire},
+		"ttl":      {2, "readonly", 1, 1, 1, ttl},
+		"pttl":     {2, "readonly", 1, 1, 1, ttl},
+		"persist":  {2, "write", 1, 1, 1, persist},
+		"keys":     {2, "readonly", 0, 0, 0, keys},
+		"scan":     {-2, "readonly", 0, 0, 0, scan},
+		"type":     {2, "readonly", 1, 1, 1, keyType},
+		"flushdb":  {-1, "write", 0, 0, 0, flushDB},
+		"flushall": {-1, "write", 0, 0, 0, flushAll},
+		"dbsize":   {1, "readonly", 0, 0, 0, dbSize},
+		"dump":     {2, "readonly", 1, 1, 1, dump},
+		"restore":  {-4, "write", 1, 1, 1, restore},
+
+		// strings
+		"set":    {-3, "write", 1, 1, 1, set},
+		"setnx":  {3, "write", 1, 1, 1, setNX},
+		"setex":  {4, "write", 1, 1, 1, setEX},
+		"get":    {2, "readonly", 1, 1, 1, get},
+		"getset": {3, "write", 1, 1, 1, getSet},
+		"mget":   {-2, "readonly", 1, -1, 1, mget},
+		"mset":   {-3, "write", 1, -1, 2, mset},
+		"incr":   {2, "write", 1, 1, 1, incr},
+		"incrby": {3, "write", 1, 1, 1, incr},
+		"decr":   {2, "write", 1, 1, 1, incr},
+		"decrby": {3, "write", 1, 1, 1, incr},
+		"append": {3, "write", 1, 1, 1, appendString},
+		"setbit": {4, "write", 1, 1, 1, setBit},
+		"getbit": {3, "readonly", 1, 1, 1, getBit},
+
+		// hyperloglog
+		"pfadd":   {-2, "write", 1, 1, 1, pfadd},
+		"pfcount": {-2, "readonly", 1, -1, 1, pfcount},
+		"pfmerge": {-2, "write", 1, -1, 1, pfmerge},
+
+		// hashes
+		"hset":    {-4, "write", 1, 1, 1, hset},
+		"hmset":   {-4, "write", 1, 1, 1, hset},
+		"hget":    {3, "readonly", 1, 1, 1, hget},
+		"hmget":   {-3, "readonly", 1, 1, 1, hmget},
+		"hgetall": {2, "readonly", 1, 1, 1, hgetall},
+		"hdel":    {-3, "write", 1, 1, 1, hdel},
+		"hlen":    {2, "readonly", 1, 1, 1, hlen},
+		"hexists": {3, "readonly", 1, 1, 1, hexists},
+		"hincrby": {4, "write", 1, 1, 1, hincrby},
+		"hkeys":   {2, "readonly", 1, 1, 1, hkeys},
+
+		// sorted sets
+		"zadd":          {-4, "write", 1, 1, 1, zadd},
+		"zrange":        {-4, "readonly", 1, 1, 1, zrange},
+		"zrangebyscore": {-4, "readonly", 1, 1, 1, zrangeByScore},
+		"zrem":          {-3, "write", 1, 1, 1, zrem},
+		"zcard":         {2, "readonly", 1, 1, 1, zcard},
+		"zscore":        {3, "readonly", 1, 1, 1, zscore},
+		"zincrby":       {4, "write", 1, 1, 1, zincrby},
+
+		// geo
+		"geoadd":               {-5, "write", 1, 1, 1, geoadd},
+		"geopos":               {-2, "readonly", 1, 1, 1, geopos},
+		"geodist":              {-4, "readonly", 1, 1, 1, geodist},
+		"georadius":            {-6, "write", 1, 1, 1, georadius},
+		"georadius_ro":         {-6, "readonly", 1, 1, 1, georadius},
+		"georadiusbymember":    {-5, "write", 1, 1, 1, georadius},
+		"georadiusbymember_ro": {-5, "readonly", 1, 1, 1, georadius},
+		"geosearch":            {-7, "readonly", 1, 1, 1, geosearch},
+
+		// lists
+		"lpush":  {-3, "write", 1, 1, 1, push},
+		"rpush":  {-3, "write", 1, 1, 1, push},
+		"lrange": {4, "readonly", 1, 1, 1, lrange},
+		"llen":   {2, "readonly", 1, 1, 1, llen},
+		"lpop":   {2, "write", 1, 1, 1, pop},
+		"rpop":   {2, "write", 1, 1, 1, pop},
+
+		// pub/sub
+		"subscribe":    {-2, "", 0, 0, 0, subscribe},
+		"psubscribe":   {-2, "", 0, 0, 0, subscribe},
+		"unsubscribe":  {-1, "", 0, 0, 0, unsubscribe},
+		"punsubscribe": {-1, "", 0, 0, 0, unsubscribe},
+		"publish":      {3, "", 0, 0, 0, publish},
+
+		// scripting, the keys follow numkeys, see evalScript
+		"eval":    {-3, "noscript", 0, 0, 0, eval},
+		"evalsha": {-3, "noscript", 0, 0, 0, evalSHA},
+		"script":  {-2, "noscript", 0, 0, 0, script},
+	}
+}
+
+// - helpers
+
+func (c *conn) database() *db {
+	return c.server.db(c.db)
+}
+
+// lookup return the live item of key, ok is false when it holds another
+// kind of value.
+func (c *conn) lookup(key, kind string) (*item, bool) {
+	it := c.database().get(key, c.server.now())
+	if it == nil {
+		return nil, true
+	}
+
+	return it, it.kind == kind
+}
+
+// fetch return the item of key and create it when missing.
+func (c *conn) fetch(key, kind string) (*item, bool) {
+	it, ok := c.lookup(key, kind)
+	if !ok {
+		return nil, false
+	}
+
+	if it == nil {
+		it = &item{
+			kind: kind,
+			hash: make(map[string]string),
+			zset: make(map[string]float64),
+		}
+		c.database().set(key, it)
+	}
+
+	return it, true
+}
+
+// cleanup remove key when its container is empty.
+func (c *conn) cleanup(key string, it *item) {
+	if it.empty() {
+		c.database().delete(key)
+	}
+}
+
+func (c *conn) notify(class byte, event, key string) {
+	c.server.notifyEvent(c.db, class, event, key)
+}
+
+// bounds normalize redis inclusive start and stop indexes, negative ones
+// count from the end.
+func bounds(start, stop, n int) (int, int, bool) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+
+	return start, stop, start <= stop && start < n
+}
+
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func parseScore(s string) (float64, bool) {
+	switch strings.ToLower(s) {
+	case "inf", "+inf":
+		return math.Inf(1), true
+	case "-inf":
+		return math.Inf(-1), true
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil && !math.IsNaN(f)
+}
+
+// - connection
+
+func ok(c *conn, args []string) interface{} {
+	return status("OK")
+}
+
+func ping(c *conn, args []string) interface{} {
+	message := ""
+	if len(args) > 0 {
+		message = args[0]
+	}
+
+	if len(c.channels)+len(c.patterns) > 0 {
+		return []interface{}{"pong", message}
+	}
+
+	if len(args) > 0 {
+		return message
+	}
+
+	return status("PONG")
+}
+
+func echo(c *conn, args []string) interface{} {
+	return args[0]
+}
+
+func selectDB(c *conn, args []string) interface{} {
+	index, err := strconv.Atoi(args[0])
+	if err != nil || index < 0 || index > 15 {
+		return failure("ERR DB index is out of range")
+	}
+
+	c.db = index
+	return status("OK")
+}
+
+func auth(c *conn, args []string) interface{} {
+	if c.server.password == "" {
+		return failure("ERR Client sent AUTH, but no password is set")
+	}
+
+	if args[0] != c.server.password {
+		return failure("ERR invalid password")
+	}
+
+	c.authenticated = true
+	return status("OK")
+}
+
+func commandInfo(c *conn, args []string) interface{} {
+	infos := make([]interface{}, 0, len(commands))
+
+	for _, name := range sortedNames() {
+		command := commands[name]
+
+		flags := []string{}
+		if command.flag != "" {
+			flags = append(flags, command.flag)
+		}
+
+		infos = append(infos, []interface{}{
+			name, int64(command.arity), flags,
+			int64(command.firstKey), int64(command.lastKey), int64(command.step),
+		})
+	}
+
+	return infos
+}
+
+// cluster answer CLUSTER SLOTS with a single node owning every slot so the
+// cluster clients can be pointed at the server too.
+func cluster(c *conn, args []string) interface{} {
+	if strings.ToLower(args[0]) != "slots" {
+		return failure(fmt.Sprintf("ERR unknown subcommand '%s'", args[0]))
+	}
+
+	host, port, err := net.SplitHostPort(c.server.Addr())
+	if err != nil {
+		return failure("ERR " + err.Error())
+	}
+
+	p, _ := strconv.ParseInt(port, 10, 64)
+
+	return []interface{}{
+		[]interface{}{int64(0), int64(16383), []interface{}{host, p, "redistest"}},
+	}
+}
+
+// config only keep notify-keyspace-events, other parameters are accepted
+// and ignored.
+func config(c *conn, args []string) interface{} {
+	switch strings.ToLower(args[0]) {
+	case "get":
+		if match(strings.ToLower(args[1]), "notify-keyspace-events") {
+			return []string{"notify-keyspace-events", c.server.notify}
+		}
+		return []string{}
+	case "set":
+		if len(args) != 3 {
+			return failure("ERR wrong number of arguments for 'config set' command")
+		}
+
+		if strings.ToLower(args[1]) == "notify-keyspace-events" {
+			c.server.notify = args[2]
+		}
+		return status("OK")
+	}
+
+	return failure(fmt.Sprintf("ERR unknown subcommand '%s'", args[0]))
+}
+
+// - keys
+
+func del(c *conn, args []string) interface{} {
+	var count int64
+
+	for _, key := range args {
+		if c.database().get(key, c.server.now()) != nil && c.database().delete(key) {
+			c.notify('g', "del", key)
+			count++
+		}
+	}
+
+	return count
+}
+
+func exists(c *conn, args []string) interface{} {
+	var count int64
+
+	for _, key := range args {
+		if c.database().get(key, c.server.now()) != nil {
+			count++
+		}
+	}
+
+	return count
+}
+
+func expire(c *conn, args []string) interface{} {
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+
+	unit := time.Second
+	if c.name == "pexpire" {
+		unit = time.Millisecond
+	}
+
+	it := c.database().get(args[0], c.server.now())
+	if it == nil {
+		return int64(0)
+	}
+
+	if n <= 0 {
+		c.database().delete(args[0])
+		c.notify('g', "del", args[0])
+		return int64(1)
+	}
+
+	it.expireAt = c.server.now().Add(time.Duration(n) * unit)
+	c.notify('g', "expire", args[0])
+
+	return int64(1)
+}
+
+func ttl(c *conn, args []string) interface{} {
+	it := c.database().get(args[0], c.server.now())
+	if it == nil {
+		return int64(-2)
+	}
+
+	if it.expireAt.IsZero() {
+		return int64(-1)
+	}
+
+	remaining := it.expireAt.Sub(c.server.now())
+	if c.name == "pttl" {
+		return int64(remaining / time.Millisecond)
+	}
+
+	return int64((remaining + time.Second/2) / time.Second)
+}
+
+func persist(c *conn, args []string) interface{} {
+	it := c.database().get(args[0], c.server.now())
+	if it == nil || it.expireAt.IsZero() {
+		return int64(0)
+	}
+
+	it.expireAt = time.Time{}
+	return int64(1)
+}
+
+func keys(c *conn, args []string) interface{} {
+	matched := make([]string, 0)
+
+	for _, key := range c.database().keys(c.server.now()) {
+		if match(args[0], key) {
+			matched = append(matched, key)
+		}
+	}
+
+	return matched
+}
+
+// scan use the position in the sorted key list as cursor.
+func scan(c *conn, args []string) interface{} {
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		return failure("ERR invalid cursor")
+	}
+
+	pattern, count, kind := "*", 10, ""
+
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+
+		switch strings.ToLower(args[i]) {
+		case "match":
+			pattern = args[i+1]
+		case "count":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count <= 0 {
+				return errSyntax
+			}
+		case "type":
+			kind = strings.ToLower(args[i+1])
+		default:
+			return errSyntax
+		}
+	}
+
+	all := c.database().keys(c.server.now())
+	end := cursor + count
+	next := end
+	if end >= len(all) {
+		end, next = len(all), 0
+	}
+
+	matched := make([]string, 0)
+	for i := cursor; i < end; i++ {
+		it := c.database().get(all[i], c.server.now())
+		if match(pattern, all[i]) && (kind == "" || it.kind == kind) {
+			matched = append(matched, all[i])
+		}
+	}
+
+	return []interface{}{strconv.Itoa(next), matched}
+}
+
+func keyType(c *conn, args []string) interface{} {
+	it := c.database().get(args[0], c.server.now())
+	if it == nil {
+		return status("none")
+	}
+
+	return status(it.kind)
+}
+
+func flushDB(c *conn, args []string) interface{} {
+	c.server.dbs[c.db] = newDB()
+	return status("OK")
+}
+
+func flushAll(c *conn, args []string) interface{} {
+	c.server.dbs = make(map[int]*db)
+	return status("OK")
+}
+
+func dbSize(c *conn, args []string) interface{} {
+	return int64(len(c.database().keys(c.server.now())))
+}
+
+// dump serialize the item as json, the payload is only understood by
+// this server.
+func dump(c *conn, args []string) interface{} {
+	it := c.database().get(args[0], c.server.now())
+	if it == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(snapshot{
+		Kind: it.kind, Str: it.str, Hash: it.hash, ZSet: it.zset, List: it.list, HLL: it.hll,
+	})
+	if err != nil {
+		return failure("ERR " + err.Error())
+	}
+
+	return dumpVersion + string(payload)
+}
+
+func restore(c *conn, args []string) interface{} {
+	ttl, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || ttl < 0 {
+		return failure("ERR Invalid TTL value, must be >= 0")
+	}
+
+	replace := false
+	for _, arg := range args[3:] {
+		if strings.ToLower(arg) != "replace" {
+			return errSyntax
+		}
+		replace = true
+	}
+
+	if !replace && c.database().get(args[0], c.server.now()) != nil {
+		return failure("BUSYKEY Target key name already exists.")
+	}
+
+	var restored snapshot
+	if !strings.HasPrefix(args[2], dumpVersion) || json.Unmarshal([]byte(strings.TrimPrefix(args[2], dumpVersion)), &restored) != nil {
+		return failure("ERR DUMP payload version or checksum are wrong")
+	}
+
+	it := &item{
+		kind: restored.Kind,
+		str:  restored.Str,
+		hash: restored.Hash,
+		zset: restored.ZSet,
+		list: restored.List,
+		hll:  restored.HLL,
+	}
+	if it.hash == nil {
+		it.hash = make(map[string]string)
+	}
+	if it.zset == nil {
+		it.zset = make(map[string]float64)
+	}
+	if ttl > 0 {
+		it.expireAt = c.server.now().Add(time.Duration(ttl) * time.Millisecond)
+	}
+
+	c.database().set(args[0], it)
+	c.notify('g', "restore", args[0])
+
+	return status("OK")
+}
+
+// - strings
+
+// set support EX, PX, NX, XX and KEEPTTL.
+func set(c *conn, args []string) interface{} {
+	key, value := args[0], args[1]
+
+	var (
+		ttl             time.Duration
+		nx, xx, keepTTL bool
+	)
+
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "keepttl":
+			keepTTL = true
+		case "ex", "px":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return errNotInt
+			}
+			if n <= 0 {
+				return failure("ERR invalid expire time in set")
+			}
+
+			unit := time.Second
+			if strings.ToLower(args[i]) == "px" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	if nx && xx {
+		return errSyntax
+	}
+
+	current := c.database().get(key, c.server.now())
+	if nx && current != nil || xx && current == nil {
+		return nil
+	}
+
+	it := &item{kind: typeString, str: value}
+	if ttl > 0 {
+		it.expireAt = c.server.now().Add(ttl)
+	} else if keepTTL && current != nil {
+		it.expireAt = current.expireAt
+	}
+
+	c.database().set(key, it)
+	c.notify('$', "set", key)
+
+	return status("OK")
+}
+
+func setNX(c *conn, args []string) interface{} {
+	if c.database().get(args[0], c.server.now()) != nil {
+		return int64(0)
+	}
+
+	c.database().set(args[0], &item{kind: typeString, str: args[1]})
+	c.notify('$', "set", args[0])
+
+	return int64(1)
+}
+
+func setEX(c *conn, args []string) interface{} {
+	return set(c, []string{args[0], args[2], "ex", args[1]})
+}
+
+func get(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeString)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return nil
+	}
+
+	return it.str
+}
+
+func getSet(c *conn, args []string) interface{} {
+	previous := get(c, args[:1])
+	if _, ok := previous.(failure); ok {
+		return previous
+	}
+
+	c.database().set(args[0], &item{kind: typeString, str: args[1]})
+	c.notify('$', "set", args[0])
+
+	return previous
+}
+
+func mget(c *conn, args []string) interface{} {
+	values := make([]interface{}, 0, len(args))
+
+	for _, key := range args {
+		it, ok := c.lookup(key, typeString)
+		if !ok || it == nil {
+			values = append(values, nil)
+			continue
+		}
+
+		values = append(values, it.str)
+	}
+
+	return values
+}
+
+func mset(c *conn, args []string) interface{} {
+	if len(args)%2 != 0 {
+		return failure("ERR wrong number of arguments for 'mset' command")
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		c.database().set(args[i], &item{kind: typeString, str: args[i+1]})
+		c.notify('$', "set", args[i])
+	}
+
+	return status("OK")
+}
+
+// incr implement INCR, INCRBY, DECR and DECRBY.
+func incr(c *conn, args []string) interface{} {
+	by := int64(1)
+	if len(args) > 1 {
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errNotInt
+		}
+		by = n
+	}
+
+	if strings.HasPrefix(c.name, "decr") {
+		by = -by
+	}
+
+	it, ok := c.lookup(args[0], typeString)
+	if !ok {
+		return errWrongType
+	}
+
+	var current int64
+	if it != nil {
+		n, err := strconv.ParseInt(it.str, 10, 64)
+		if err != nil {
+			return errNotInt
+		}
+		current = n
+	} else {
+		it = &item{kind: typeString}
+		c.database().set(args[0], it)
+	}
+
+	current += by
+	it.str = strconv.FormatInt(current, 10)
+	c.notify('$', "incrby", args[0])
+
+	return current
+}
+
+func appendString(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeString)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		it = &item{kind: typeString}
+		c.database().set(args[0], it)
+	}
+
+	it.str += args[1]
+	c.notify('$', "append", args[0])
+
+	return int64(len(it.str))
+}
+
+func setBit(c *conn, args []string) interface{} {
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || offset < 0 || offset >= 1<<32 {
+		return failure("ERR bit offset is not an integer or out of range")
+	}
+
+	if args[2] != "0" && args[2] != "1" {
+		return failure("ERR bit is not an integer or out of range")
+	}
+
+	it, ok := c.lookup(args[0], typeString)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		it = &item{kind: typeString}
+		c.database().set(args[0], it)
+	}
+
+	index, mask := offset/8, byte(1)<<(7-uint(offset%8))
+	if int64(len(it.str)) <= index {
+		it.str += string(make([]byte, index-int64(len(it.str))+1))
+	}
+
+	b := []byte(it.str)
+	previous := int64(0)
+	if b[index]&mask != 0 {
+		previous = 1
+	}
+
+	if args[2] == "1" {
+		b[index] |= mask
+	} else {
+		b[index] &^= mask
+	}
+
+	it.str = string(b)
+	c.notify('$', "setbit", args[0])
+
+	return previous
+}
+
+func getBit(c *conn, args []string) interface{} {
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || offset < 0 {
+		return failure("ERR bit offset is not an integer or out of range")
+	}
+
+	it, ok := c.lookup(args[0], typeString)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil || int64(len(it.str)) <= offset/8 {
+		return int64(0)
+	}
+
+	if it.str[offset/8]&(byte(1)<<(7-uint(offset%8))) != 0 {
+		return int64(1)
+	}
+
+	return int64(0)
+}
+
+// - hyperloglog
+
+const errNotHLL = failure("WRONGTYPE Key is not a valid HyperLogLog string value.")
+
+// hyperLogLog return the HyperLogLog of key, ok is false when key hold
+// another value.
+func (c *conn) hyperLogLog(key string) (*item, bool) {
+	it, ok := c.lookup(key, typeString)
+	if !ok || it != nil && it.hll == nil {
+		return nil, false
+	}
+
+	return it, true
+}
+
+func pfadd(c *conn, args []string) interface{} {
+	it, ok := c.hyperLogLog(args[0])
+	if !ok {
+		return errNotHLL
+	}
+
+	changed := int64(0)
+	if it == nil {
+		it = &item{kind: typeString, hll: make(map[string]bool)}
+		c.database().set(args[0], it)
+		changed = 1
+	}
+
+	for _, element := range args[1:] {
+		if !it.hll[element] {
+			it.hll[element] = true
+			changed = 1
+		}
+	}
+
+	if changed == 1 {
+		c.notify('$', "pfadd", args[0])
+	}
+
+	return changed
+}
+
+func pfcount(c *conn, args []string) interface{} {
+	union := make(map[string]bool)
+
+	for _, key := range args {
+		it, ok := c.hyperLogLog(key)
+		if !ok {
+			return errNotHLL
+		}
+
+		if it == nil {
+			continue
+		}
+
+		for element := range it.hll {
+			union[element] = true
+		}
+	}
+
+	return int64(len(union))
+}
+
+func pfmerge(c *conn, args []string) interface{} {
+	dest, ok := c.hyperLogLog(args[0])
+	if !ok {
+		return errNotHLL
+	}
+
+	if dest == nil {
+		dest = &item{kind: typeString, hll: make(map[string]bool)}
+	}
+
+	for _, key := range args[1:] {
+		it, ok := c.hyperLogLog(key)
+		if !ok {
+			return errNotHLL
+		}
+
+		if it == nil {
+			continue
+		}
+
+		for element := range it.hll {
+			dest.hll[element] = true
+		}
+	}
+
+	c.database().set(args[0], dest)
+	c.notify('$', "pfadd", args[0])
+
+	return status("OK")
+}
+
+// - hashes
+
+// hset implement HSET and HMSET with any number of field value pairs.
+func hset(c *conn, args []string) interface{} {
+	if len(args)%2 != 1 {
+		return failure(fmt.Sprintf("ERR wrong number of arguments for '%s' command", c.name))
+	}
+
+	it, ok := c.fetch(args[0], typeHash)
+	if !ok {
+		return errWrongType
+	}
+
+	var added int64
+	for i := 1; i < len(args); i += 2 {
+		if _, exists := it.hash[args[i]]; !exists {
+			added++
+		}
+		it.hash[args[i]] = args[i+1]
+	}
+
+	c.notify('h', "hset", args[0])
+
+	if c.name == "hmset" {
+		return status("OK")
+	}
+
+	return added
+}
+
+func hget(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeHash)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return nil
+	}
+
+	value, exists := it.hash[args[1]]
+	if !exists {
+		return nil
+	}
+
+	return value
+}
+
+func hmget(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeHash)
+	if !ok {
+		return errWrongType
+	}
+
+	values := make([]interface{}, 0, len(args)-1)
+	for _, field := range args[1:] {
+		value, exists := "", false
+		if it != nil {
+			value, exists = it.hash[field]
+		}
+
+		if !exists {
+			values = append(values, nil)
+			continue
+		}
+
+		values = append(values, value)
+	}
+
+	return values
+}
+
+func hgetall(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeHash)
+	if !ok {
+		return errWrongType
+	}
+
+	values := make([]string, 0)
+	if it == nil {
+		return values
+	}
+
+	for _, field := range sortedFields(it.hash) {
+		values = append(values, field, it.hash[field])
+	}
+
+	return values
+}
+
+func hdel(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeHash)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return int64(0)
+	}
+
+	var removed int64
+	for _, field := range args[1:] {
+		if _, exists := it.hash[field]; exists {
+			delete(it.hash, field)
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		c.notify('h', "hdel", args[0])
+	}
+	c.cleanup(args[0], it)
+
+	return removed
+}
+
+func hlen(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeHash)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return int64(0)
+	}
+
+	return int64(len(it.hash))
+}
+
+func hexists(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeHash)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return int64(0)
+	}
+
+	if _, exists := it.hash[args[1]]; !exists {
+		return int64(0)
+	}
+
+	return int64(1)
+}
+
+func hincrby(c *conn, args []string) interface{} {
+	by, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+
+	it, ok := c.fetch(args[0], typeHash)
+	if !ok {
+		return errWrongType
+	}
+
+	var current int64
+	if value, exists := it.hash[args[1]]; exists {
+		if current, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return failure("ERR hash value is not an integer")
+		}
+	}
+
+	current += by
+	it.hash[args[1]] = strconv.FormatInt(current, 10)
+	c.notify('h', "hincrby", args[0])
+
+	return current
+}
+
+func hkeys(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeHash)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return []string{}
+	}
+
+	return sortedFields(it.hash)
+}
+
+// - sorted sets
+
+// zadd support the NX, XX and CH flags.
+func zadd(c *conn, args []string) interface{} {
+	key := args[0]
+	args = args[1:]
+
+	var nx, xx, ch bool
+
+flags:
+	for len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "ch":
+			ch = true
+		default:
+			break flags
+		}
+		args = args[1:]
+	}
+
+	if nx && xx {
+		return failure("ERR XX and NX options at the same time are not compatible")
+	}
+
+	if len(args) == 0 || len(args)%2 != 0 {
+		return errSyntax
+	}
+
+	scores := make([]float64, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		score, valid := parseScore(args[i])
+		if !valid {
+			return errNotFloat
+		}
+		scores = append(scores, score)
+	}
+
+	it, ok := c.fetch(key, typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	var added, changed int64
+	for i := 0; i < len(args); i += 2 {
+		member, score := args[i+1], scores[i/2]
+
+		current, exists := it.zset[member]
+		if nx && exists || xx && !exists {
+			continue
+		}
+
+		if !exists {
+			added++
+		} else if current != score {
+			changed++
+		}
+
+		it.zset[member] = score
+	}
+
+	c.cleanup(key, it)
+
+	if added+changed > 0 {
+		c.notify('z', "zadd", key)
+	}
+
+	if ch {
+		return added + changed
+	}
+
+	return added
+}
+
+func zrange(c *conn, args []string) interface{} {
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errNotInt
+	}
+
+	stop, err := strconv.Atoi(args[2])
+	if err != nil {
+		return errNotInt
+	}
+
+	withScores := false
+	for _, arg := range args[3:] {
+		if strings.ToLower(arg) != "withscores" {
+			return errSyntax
+		}
+		withScores = true
+	}
+
+	it, ok := c.lookup(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return []string{}
+	}
+
+	members := it.sorted()
+
+	start, stop, valid := bounds(start, stop, len(members))
+	if !valid {
+		return []string{}
+	}
+
+	return formatMembers(members[start:stop+1], withScores)
+}
+
+// zrangeByScore support exclusive `(` bounds, -inf, +inf, WITHSCORES and
+// LIMIT offset count.
+func zrangeByScore(c *conn, args []string) interface{} {
+	min, minExclusive, valid := parseBound(args[1])
+	if !valid {
+		return failure("ERR min or max is not a float")
+	}
+
+	max, maxExclusive, valid := parseBound(args[2])
+	if !valid {
+		return failure("ERR min or max is not a float")
+	}
+
+	withScores, offset, count := false, 0, -1
+
+	for i := 3; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "withscores":
+			withScores = true
+		case "limit":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+
+			var err error
+			if offset, err = strconv.Atoi(args[i+1]); err != nil {
+				return errNotInt
+			}
+			if count, err = strconv.Atoi(args[i+2]); err != nil {
+				return errNotInt
+			}
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+
+	it, ok := c.lookup(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil || offset < 0 {
+		return []string{}
+	}
+
+	selected := make([]member, 0)
+	for _, m := range it.sorted() {
+		if m.score < min || minExclusive && m.score == min {
+			continue
+		}
+
+		if m.score > max || maxExclusive && m.score == max {
+			continue
+		}
+
+		selected = append(selected, m)
+	}
+
+	if offset >= len(selected) {
+		return []string{}
+	}
+
+	selected = selected[offset:]
+	if count >= 0 && count < len(selected) {
+		selected = selected[:count]
+	}
+
+	return formatMembers(selected, withScores)
+}
+
+func zrem(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return int64(0)
+	}
+
+	var removed int64
+	for _, name := range args[1:] {
+		if _, exists := it.zset[name]; exists {
+			delete(it.zset, name)
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		c.notify('z', "zrem", args[0])
+	}
+	c.cleanup(args[0], it)
+
+	return removed
+}
+
+func zcard(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return int64(0)
+	}
+
+	return int64(len(it.zset))
+}
+
+func zscore(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return nil
+	}
+
+	score, exists := it.zset[args[1]]
+	if !exists {
+		return nil
+	}
+
+	return formatScore(score)
+}
+
+func zincrby(c *conn, args []string) interface{} {
+	by, valid := parseScore(args[1])
+	if !valid {
+		return errNotFloat
+	}
+
+	it, ok := c.fetch(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	score := it.zset[args[2]] + by
+	if math.IsNaN(score) {
+		c.cleanup(args[0], it)
+		return failure("ERR resulting score is not a number (NaN)")
+	}
+
+	it.zset[args[2]] = score
+	c.notify('z', "zincr", args[0])
+
+	return formatScore(score)
+}
+
+func parseBound(s string) (float64, bool, bool) {
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+
+	score, valid := parseScore(s)
+	return score, exclusive, valid
+}
+
+func formatMembers(members []member, withScores bool) []string {
+	values := make([]string, 0, len(members)*2)
+
+	for _, m := range members {
+		values = append(values, m.name)
+		if withScores {
+			values = append(values, formatScore(m.score))
+		}
+	}
+
+	return values
+}
+
+// - lists
+
+// push implement LPUSH and RPUSH.
+func push(c *conn, args []string) interface{} {
+	it, ok := c.fetch(args[0], typeList)
+	if !ok {
+		return errWrongType
+	}
+
+	left := c.name == "lpush"
+
+	for _, value := range args[1:] {
+		if left {
+			it.list = append([]string{value}, it.list...)
+			continue
+		}
+		it.list = append(it.list, value)
+	}
+
+	c.notify('l', c.name, args[0])
+
+	return int64(len(it.list))
+}
+
+func lrange(c *conn, args []string) interface{} {
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errNotInt
+	}
+
+	stop, err := strconv.Atoi(args[2])
+	if err != nil {
+		return errNotInt
+	}
+
+	it, ok := c.lookup(args[0], typeList)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return []string{}
+	}
+
+	start, stop, valid := bounds(start, stop, len(it.list))
+	if !valid {
+		return []string{}
+	}
+
+	return append([]string{}, it.list[start:stop+1]...)
+}
+
+func llen(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeList)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return int64(0)
+	}
+
+	return int64(len(it.list))
+}
+
+// pop implement LPOP and RPOP.
+func pop(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeList)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return nil
+	}
+
+	var value string
+	if c.name == "lpop" {
+		value, it.list = it.list[0], it.list[1:]
+	} else {
+		value, it.list = it.list[len(it.list)-1], it.list[:len(it.list)-1]
+	}
+
+	c.notify('l', c.name, args[0])
+	c.cleanup(args[0], it)
+
+	return value
+}
+
+// - pub/sub
+
+// subscribe implement SUBSCRIBE and PSUBSCRIBE, every confirmation is
+// written before the reply of the last channel.
+func subscribe(c *conn, args []string) interface{} {
+	kind, set := "subscribe", c.channels
+	if c.name == "psubscribe" {
+		kind, set = "psubscribe", c.patterns
+	}
+
+	var reply interface{}
+	for i, channel := range args {
+		set[channel] = true
+
+		reply = []interface{}{kind, channel, int64(len(c.channels) + len(c.patterns))}
+		if i < len(args)-1 {
+			c.write(reply)
+		}
+	}
+
+	return reply
+}
+
+// unsubscribe implement UNSUBSCRIBE and PUNSUBSCRIBE, without argument
+// every channel (or pattern) is unsubscribed.
+func unsubscribe(c *conn, args []string) interface{} {
+	kind, set := "unsubscribe", c.channels
+	if c.name == "punsubscribe" {
+		kind, set = "punsubscribe", c.patterns
+	}
+
+	if len(args) == 0 {
+		args = sortedKeys(set)
+	}
+
+	if len(args) == 0 {
+		return []interface{}{kind, nil, int64(len(c.channels) + len(c.patterns))}
+	}
+
+	var reply interface{}
+	for i, channel := range args {
+		delete(set, channel)
+
+		reply = []interface{}{kind, channel, int64(len(c.channels) + len(c.patterns))}
+		if i < len(args)-1 {
+			c.write(reply)
+		}
+	}
+
+	return reply
+}
+
+func publish(c *conn, args []string) interface{} {
+	return c.server.publish(args[0], args[1])
+}
+
+func sortedFields(hash map[string]string) []string {
+	fields := make([]string, 0, len(hash))
+	for field := range hash {
+		fields = append(fields, field)
+	}
+
+	return sortStrings(fields)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	return sortStrings(keys)
+}
+
+func sortedNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	return sortStrings(names)
+}
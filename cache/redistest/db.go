@@ -0,0 +1,143 @@
+package redistest
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	dumpVersion = "redistest/1:"
+
+	typeString = "string"
+	typeHash   = "hash"
+	typeZSet   = "zset"
+	typeList   = "list"
+)
+
+type (
+	db struct {
+		items map[string]*item
+	}
+
+	item struct {
+		kind     string
+		str      string
+		hash     map[string]string
+		zset     map[string]float64
+		list     []string
+		expireAt time.Time
+
+		// hll keep the exact members of a HyperLogLog, counts are exact
+		hll map[string]bool
+	}
+
+	// snapshot is the DUMP payload of an item.
+	snapshot struct {
+		Kind string             `json:"kind"`
+		Str  string             `json:"str,omitempty"`
+		Hash map[string]string  `json:"hash,omitempty"`
+		ZSet map[string]float64 `json:"zset,omitempty"`
+		List []string           `json:"list,omitempty"`
+		HLL  map[string]bool    `json:"hll,omitempty"`
+	}
+
+	member struct {
+		name  string
+		score float64
+	}
+)
+
+func newDB() *db {
+	return &db{items: make(map[string]*item)}
+}
+
+// get return the live item of key, expired items are left to the server
+// sweep which publish their expired notification.
+func (d *db) get(key string, now time.Time) *item {
+	it, ok := d.items[key]
+	if !ok || it.expired(now) {
+		return nil
+	}
+
+	return it
+}
+
+func (d *db) set(key string, it *item) {
+	d.items[key] = it
+}
+
+func (d *db) delete(key string) bool {
+	if _, ok := d.items[key]; !ok {
+		return false
+	}
+
+	delete(d.items, key)
+	return true
+}
+
+func (d *db) keys(now time.Time) []string {
+	keys := make([]string, 0, len(d.items))
+
+	for key, it := range d.items {
+		if !it.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *db) expired(now time.Time) []string {
+	keys := make([]string, 0)
+
+	for key, it := range d.items {
+		if it.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func (it *item) expired(now time.Time) bool {
+	return !it.expireAt.IsZero() && !now.Before(it.expireAt)
+}
+
+// empty return true when a container item has no more elements, redis
+// remove those keys.
+func (it *item) empty() bool {
+	switch it.kind {
+	case typeHash:
+		return len(it.hash) == 0
+	case typeZSet:
+		return len(it.zset) == 0
+	case typeList:
+		return len(it.list) == 0
+	}
+
+	return false
+}
+
+// sorted return zset members ordered by score then by name.
+func (it *item) sorted() []member {
+	members := make([]member, 0, len(it.zset))
+	for name, score := range it.zset {
+		members = append(members, member{name: name, score: score})
+	}
+
+	sort.Slice(members, func(l, r int) bool {
+		if members[l].score != members[r].score {
+			return members[l].score < members[r].score
+		}
+		return members[l].name < members[r].name
+	})
+
+	return members
+}
+
+func sortStrings(values []string) []string {
+	sort.Strings(values)
+	return values
+}
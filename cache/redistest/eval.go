@@ -0,0 +1,238 @@
+package redistest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// eval run the script in a fresh gopher-lua state holding the server lock,
+// like redis scripts are atomic. redis.call, redis.pcall, redis.error_reply
+// and redis.status_reply are provided, the replies are converted the way
+// redis does.
+func eval(c *conn, args []string) interface{} {
+	return c.evalScript(args[0], args[1:])
+}
+
+func evalSHA(c *conn, args []string) interface{} {
+	script, ok := c.server.scripts[strings.ToLower(args[0])]
+	if !ok {
+		return failure("NOSCRIPT No matching script. Please use EVAL.")
+	}
+
+	return c.evalScript(script, args[1:])
+}
+
+func script(c *conn, args []string) interface{} {
+	switch strings.ToLower(args[0]) {
+	case "load":
+		if len(args) != 2 {
+			return failure("ERR wrong number of arguments for 'script|load' command")
+		}
+
+		return c.server.loadScript(args[1])
+	case "exists":
+		exists := make([]interface{}, 0, len(args)-1)
+		for _, sha := range args[1:] {
+			_, ok := c.server.scripts[strings.ToLower(sha)]
+			exists = append(exists, boolInt(ok))
+		}
+
+		return exists
+	case "flush":
+		c.server.scripts = make(map[string]string)
+		return status("OK")
+	}
+
+	return failure(fmt.Sprintf("ERR unknown subcommand '%s'", args[0]))
+}
+
+// - helpers
+
+func (s *Server) loadScript(script string) string {
+	sum := sha1.Sum([]byte(script))
+	sha := hex.EncodeToString(sum[:])
+	s.scripts[sha] = script
+
+	return sha
+}
+
+// evalScript run script with args, the number of keys followed by the keys
+// and the arguments.
+func (c *conn) evalScript(script string, args []string) interface{} {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil {
+		return errNotInt
+	}
+
+	if numKeys < 0 || numKeys > len(args)-1 {
+		return failure("ERR Number of keys can't be greater than number of args")
+	}
+
+	c.server.loadScript(script)
+
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("KEYS", stringTable(L, args[1:1+numKeys]))
+	L.SetGlobal("ARGV", stringTable(L, args[1+numKeys:]))
+
+	redis := L.NewTable()
+	L.SetFuncs(redis, map[string]lua.LGFunction{
+		"call":  c.luaCall(true),
+		"pcall": c.luaCall(false),
+		"error_reply": func(L *lua.LState) int {
+			L.Push(replyTable(L, "err", L.CheckString(1)))
+			return 1
+		},
+		"status_reply": func(L *lua.LState) int {
+			L.Push(replyTable(L, "ok", L.CheckString(1)))
+			return 1
+		},
+	})
+	L.SetGlobal("redis", redis)
+
+	if err := L.DoString(script); err != nil {
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			if table, ok := apiErr.Object.(*lua.LTable); ok {
+				if msg, ok := table.RawGetString("err").(lua.LString); ok {
+					return failure(string(msg))
+				}
+			}
+		}
+
+		return failure("ERR Error running script: " + err.Error())
+	}
+
+	return fromLua(L.Get(-1))
+}
+
+// luaCall return redis.call when raise is true, its errors abort the script,
+// else redis.pcall which return them as a table.
+func (c *conn) luaCall(raise bool) lua.LGFunction {
+	return func(L *lua.LState) int {
+		args := make([]string, 0, L.GetTop())
+		for i := 1; i <= L.GetTop(); i++ {
+			switch v := L.Get(i).(type) {
+			case lua.LString:
+				args = append(args, string(v))
+			case lua.LNumber:
+				args = append(args, v.String())
+			default:
+				L.Error(replyTable(L, "err", "ERR Lua redis() command arguments must be strings or integers"), 0)
+				return 0
+			}
+		}
+
+		if len(args) == 0 {
+			L.Error(replyTable(L, "err", "ERR Please specify at least one argument for redis.call()"), 0)
+			return 0
+		}
+
+		reply := c.run(args)
+		if msg, ok := reply.(failure); ok && raise {
+			L.Error(replyTable(L, "err", string(msg)), 0)
+			return 0
+		}
+
+		L.Push(toLua(L, reply))
+		return 1
+	}
+}
+
+// toLua convert a reply to lua, null to false, status and errors to a table
+// with an ok or err field.
+func toLua(L *lua.LState, reply interface{}) lua.LValue {
+	switch v := reply.(type) {
+	case nil, nullArray:
+		return lua.LFalse
+	case status:
+		return replyTable(L, "ok", string(v))
+	case failure:
+		return replyTable(L, "err", string(v))
+	case int:
+		return lua.LNumber(v)
+	case int64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []string:
+		return stringTable(L, v)
+	case []interface{}:
+		table := L.NewTable()
+		for _, item := range v {
+			table.Append(toLua(L, item))
+		}
+
+		return table
+	}
+
+	return replyTable(L, "err", fmt.Sprintf("ERR unsupported reply %T", reply))
+}
+
+// fromLua convert a lua value to a reply, numbers are truncated to integers,
+// true to 1 and false to null, tables are read up to their first nil.
+func fromLua(value lua.LValue) interface{} {
+	switch v := value.(type) {
+	case lua.LString:
+		return string(v)
+	case lua.LNumber:
+		return int64(v)
+	case lua.LBool:
+		if v {
+			return int64(1)
+		}
+
+		return nil
+	case *lua.LTable:
+		if msg, ok := v.RawGetString("err").(lua.LString); ok {
+			return failure(string(msg))
+		}
+
+		if msg, ok := v.RawGetString("ok").(lua.LString); ok {
+			return status(string(msg))
+		}
+
+		replies := []interface{}{}
+		for i := 1; ; i++ {
+			item := v.RawGetInt(i)
+			if item == lua.LNil {
+				break
+			}
+
+			replies = append(replies, fromLua(item))
+		}
+
+		return replies
+	}
+
+	return nil
+}
+
+func stringTable(L *lua.LState, values []string) *lua.LTable {
+	table := L.NewTable()
+	for _, value := range values {
+		table.Append(lua.LString(value))
+	}
+
+	return table
+}
+
+func replyTable(L *lua.LState, field, msg string) *lua.LTable {
+	table := L.NewTable()
+	table.RawSetString(field, lua.LString(msg))
+
+	return table
+}
+
+func boolInt(ok bool) int64 {
+	if ok {
+		return 1
+	}
+
+	return 0
+}
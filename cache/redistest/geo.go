@@ -0,0 +1,427 @@
+package redistest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	geoStep   = 26
+	geoLatMin = -85.05112878
+	geoLatMax = 85.05112878
+	geoLonMin = -180.0
+	geoLonMax = 180.0
+
+	// earthRadius is the radius used by redis distances, in meters.
+	earthRadius = 6372797.560856
+
+	errGeoUnit = failure("ERR unsupported unit provided. please use M, KM, FT, MI")
+)
+
+var geoUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"ft": 0.3048,
+	"mi": 1609.34,
+}
+
+type (
+	geoPoint struct {
+		lon, lat float64
+	}
+
+	// geoSearch is a parsed GEORADIUS, GEORADIUSBYMEMBER or GEOSEARCH.
+	geoSearch struct {
+		center        geoPoint
+		radius        float64
+		width, height float64
+		unit          float64
+
+		count     int
+		any       bool
+		sort      string
+		withCoord bool
+		withDist  bool
+		withHash  bool
+	}
+
+	geoMatch struct {
+		name  string
+		hash  int64
+		point geoPoint
+		dist  float64
+	}
+)
+
+// geoEncode interleave 26 bits of longitude and latitude into the 52 bits
+// score redis store geo members with, longitude bits first.
+func geoEncode(lon, lat float64) int64 {
+	lonBits := uint64((lon - geoLonMin) / (geoLonMax - geoLonMin) * (1 << geoStep))
+	latBits := uint64((lat - geoLatMin) / (geoLatMax - geoLatMin) * (1 << geoStep))
+
+	var hash uint64
+	for i := geoStep - 1; i >= 0; i-- {
+		hash = hash<<1 | lonBits>>uint(i)&1
+		hash = hash<<1 | latBits>>uint(i)&1
+	}
+
+	return int64(hash)
+}
+
+// geoDecode return the center of the cell of hash.
+func geoDecode(hash int64) geoPoint {
+	var lonBits, latBits uint64
+	for i := geoStep - 1; i >= 0; i-- {
+		lonBits = lonBits<<1 | uint64(hash)>>uint(2*i+1)&1
+		latBits = latBits<<1 | uint64(hash)>>uint(2*i)&1
+	}
+
+	cell := func(bits uint64, min, max float64) float64 {
+		size := (max - min) / (1 << geoStep)
+		return min + (float64(bits)+0.5)*size
+	}
+
+	return geoPoint{lon: cell(lonBits, geoLonMin, geoLonMax), lat: cell(latBits, geoLatMin, geoLatMax)}
+}
+
+// geoDistance is the haversine distance in meters.
+func geoDistance(a, b geoPoint) float64 {
+	lat1, lat2 := a.lat*math.Pi/180, b.lat*math.Pi/180
+	u := math.Sin((lat2 - lat1) / 2)
+	v := math.Sin((b.lon - a.lon) * math.Pi / 180 / 2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1)*math.Cos(lat2)*v*v))
+}
+
+func formatCoord(f float64) string {
+	return strconv.FormatFloat(f, 'f', 17, 64)
+}
+
+func formatDist(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+func geoadd(c *conn, args []string) interface{} {
+	if len(args)%3 != 1 {
+		return failure("ERR syntax error. Try GEOADD key [x1] [y1] [name1] [x2] [y2] [name2] ... ")
+	}
+
+	zargs := []string{args[0]}
+	for i := 1; i < len(args); i += 3 {
+		lon, err1 := strconv.ParseFloat(args[i], 64)
+		lat, err2 := strconv.ParseFloat(args[i+1], 64)
+		if err1 != nil || err2 != nil {
+			return errNotFloat
+		}
+
+		if lon < geoLonMin || lon > geoLonMax || lat < geoLatMin || lat > geoLatMax {
+			return failure("ERR invalid longitude,latitude pair " + args[i] + "," + args[i+1])
+		}
+
+		zargs = append(zargs, strconv.FormatInt(geoEncode(lon, lat), 10), args[i+2])
+	}
+
+	return zadd(c, zargs)
+}
+
+func geopos(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	positions := make([]interface{}, 0, len(args)-1)
+	for _, name := range args[1:] {
+		score, exists := 0.0, false
+		if it != nil {
+			score, exists = it.zset[name]
+		}
+
+		if !exists {
+			positions = append(positions, nullArray{})
+			continue
+		}
+
+		point := geoDecode(int64(score))
+		positions = append(positions, []interface{}{formatCoord(point.lon), formatCoord(point.lat)})
+	}
+
+	return positions
+}
+
+func geodist(c *conn, args []string) interface{} {
+	unit := 1.0
+	if len(args) > 3 {
+		var valid bool
+		if unit, valid = geoUnits[strings.ToLower(args[3])]; !valid {
+			return errGeoUnit
+		}
+	}
+
+	it, ok := c.lookup(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	if it == nil {
+		return nil
+	}
+
+	a, aok := it.zset[args[1]]
+	b, bok := it.zset[args[2]]
+	if !aok || !bok {
+		return nil
+	}
+
+	return formatDist(geoDistance(geoDecode(int64(a)), geoDecode(int64(b))) / unit)
+}
+
+// georadius implement GEORADIUS and GEORADIUSBYMEMBER and their read only
+// variants, STORE is not supported.
+func georadius(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	byMember := strings.HasPrefix(c.name, "georadiusbymember")
+
+	search := &geoSearch{}
+	rest := args[1:]
+
+	if byMember {
+		point, reply := geoMember(it, rest[0])
+		if reply != nil {
+			return reply
+		}
+		search.center, rest = point, rest[1:]
+	} else {
+		if len(rest) < 2 {
+			return errSyntax
+		}
+
+		lon, err1 := strconv.ParseFloat(rest[0], 64)
+		lat, err2 := strconv.ParseFloat(rest[1], 64)
+		if err1 != nil || err2 != nil {
+			return errNotFloat
+		}
+		search.center, rest = geoPoint{lon: lon, lat: lat}, rest[2:]
+	}
+
+	if len(rest) < 2 {
+		return errSyntax
+	}
+
+	radius, err := strconv.ParseFloat(rest[0], 64)
+	if err != nil || radius < 0 {
+		return failure("ERR radius cannot be negative")
+	}
+
+	unit, valid := geoUnits[strings.ToLower(rest[1])]
+	if !valid {
+		return errGeoUnit
+	}
+	search.radius, search.unit = radius, unit
+
+	if reply := search.options(rest[2:]); reply != nil {
+		return reply
+	}
+
+	return search.run(it)
+}
+
+// geosearch implement GEOSEARCH.
+func geosearch(c *conn, args []string) interface{} {
+	it, ok := c.lookup(args[0], typeZSet)
+	if !ok {
+		return errWrongType
+	}
+
+	search := &geoSearch{}
+	rest := args[1:]
+
+	var centered, shaped bool
+	options := make([]string, 0)
+
+	for len(rest) > 0 {
+		switch strings.ToLower(rest[0]) {
+		case "frommember":
+			if len(rest) < 2 {
+				return errSyntax
+			}
+
+			point, reply := geoMember(it, rest[1])
+			if reply != nil {
+				return reply
+			}
+			search.center, centered, rest = point, true, rest[2:]
+		case "fromlonlat":
+			if len(rest) < 3 {
+				return errSyntax
+			}
+
+			lon, err1 := strconv.ParseFloat(rest[1], 64)
+			lat, err2 := strconv.ParseFloat(rest[2], 64)
+			if err1 != nil || err2 != nil {
+				return errNotFloat
+			}
+			search.center, centered, rest = geoPoint{lon: lon, lat: lat}, true, rest[3:]
+		case "byradius":
+			if len(rest) < 3 {
+				return errSyntax
+			}
+
+			radius, err := strconv.ParseFloat(rest[1], 64)
+			unit, valid := geoUnits[strings.ToLower(rest[2])]
+			if err != nil || !valid {
+				return errGeoUnit
+			}
+			search.radius, search.unit, shaped, rest = radius, unit, true, rest[3:]
+		case "bybox":
+			if len(rest) < 4 {
+				return errSyntax
+			}
+
+			width, err1 := strconv.ParseFloat(rest[1], 64)
+			height, err2 := strconv.ParseFloat(rest[2], 64)
+			unit, valid := geoUnits[strings.ToLower(rest[3])]
+			if err1 != nil || err2 != nil || !valid {
+				return errGeoUnit
+			}
+			search.width, search.height, search.unit, shaped, rest = width, height, unit, true, rest[4:]
+		default:
+			options = append(options, rest[0])
+			rest = rest[1:]
+		}
+	}
+
+	if !centered || !shaped {
+		return failure("ERR exactly one of FROMMEMBER or FROMLONLAT and one of BYRADIUS or BYBOX can be specified for GEOSEARCH")
+	}
+
+	if reply := search.options(options); reply != nil {
+		return reply
+	}
+
+	return search.run(it)
+}
+
+func geoMember(it *item, name string) (geoPoint, interface{}) {
+	if it == nil {
+		return geoPoint{}, failure("ERR could not decode requested zset member")
+	}
+
+	score, exists := it.zset[name]
+	if !exists {
+		return geoPoint{}, failure("ERR could not decode requested zset member")
+	}
+
+	return geoDecode(int64(score)), nil
+}
+
+func (s *geoSearch) options(args []string) interface{} {
+	for i := 0; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "withcoord":
+			s.withCoord = true
+		case "withdist":
+			s.withDist = true
+		case "withhash":
+			s.withHash = true
+		case "asc", "desc":
+			s.sort = strings.ToLower(args[i])
+		case "any":
+			s.any = true
+		case "count":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil || count <= 0 {
+				return failure("ERR COUNT must be > 0")
+			}
+			s.count = count
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	if s.any && s.count == 0 {
+		return failure("ERR the ANY argument requires COUNT argument")
+	}
+
+	return nil
+}
+
+func (s *geoSearch) contains(point geoPoint, dist float64) bool {
+	if s.radius > 0 || s.width == 0 {
+		return dist <= s.radius*s.unit
+	}
+
+	// distances along the axes of the box, measured from its center
+	dx := geoDistance(geoPoint{lon: point.lon, lat: s.center.lat}, s.center)
+	dy := geoDistance(geoPoint{lon: s.center.lon, lat: point.lat}, s.center)
+
+	return dx <= s.width*s.unit/2 && dy <= s.height*s.unit/2
+}
+
+func (s *geoSearch) run(it *item) interface{} {
+	matches := make([]geoMatch, 0)
+
+	if it != nil {
+		for _, m := range it.sorted() {
+			point := geoDecode(int64(m.score))
+			dist := geoDistance(s.center, point)
+
+			if s.contains(point, dist) {
+				matches = append(matches, geoMatch{name: m.name, hash: int64(m.score), point: point, dist: dist})
+			}
+
+			if s.any && len(matches) == s.count {
+				break
+			}
+		}
+	}
+
+	sortBy := s.sort
+	if sortBy == "" && s.count > 0 && !s.any {
+		sortBy = "asc"
+	}
+
+	switch sortBy {
+	case "asc":
+		sort.SliceStable(matches, func(l, r int) bool { return matches[l].dist < matches[r].dist })
+	case "desc":
+		sort.SliceStable(matches, func(l, r int) bool { return matches[l].dist > matches[r].dist })
+	}
+
+	if s.count > 0 && len(matches) > s.count {
+		matches = matches[:s.count]
+	}
+
+	reply := make([]interface{}, 0, len(matches))
+	for _, m := range matches {
+		if !s.withCoord && !s.withDist && !s.withHash {
+			reply = append(reply, m.name)
+			continue
+		}
+
+		fields := []interface{}{m.name}
+		if s.withDist {
+			fields = append(fields, formatDist(m.dist/s.unit))
+		}
+		if s.withHash {
+			fields = append(fields, m.hash)
+		}
+		if s.withCoord {
+			fields = append(fields, []interface{}{formatCoord(m.point.lon), formatCoord(m.point.lat)})
+		}
+		reply = append(reply, fields)
+	}
+
+	return reply
+}
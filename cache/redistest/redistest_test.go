@@ -0,0 +1,257 @@
+package redistest_test
+
+import (
+	"testing"
+	"time"
+
+	gr "github.com/go-redis/redis"
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redis"
+	redis_cluster "github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redis-cluster"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redistest"
+)
+
+type text string
+
+func (t *text) UnmarshalBinary(data []byte) error {
+	*t = text(data)
+	return nil
+}
+
+func Test_Server(t *testing.T) {
+
+	t.Run("when setting and getting strings", func(t *testing.T) {
+		_, c := redistest.NewClient(t)
+
+		assert2.NoError(t, c.Set("name", "andree"))
+
+		var value text
+		assert2.NoError(t, c.Get("name", &value))
+		assert2.Equal(t, text("andree"), value)
+
+		err := c.Get("missing", &value)
+		assert2.Error(t, err)
+
+		ok, err := c.SetNx("name", "other", 0)
+		assert2.NoError(t, err)
+		assert2.False(t, ok)
+
+		assert2.NoError(t, c.IncrBy("counter", 5))
+		assert2.NoError(t, c.Incr("counter"))
+		assert2.NoError(t, c.Get("counter", &value))
+		assert2.Equal(t, text("6"), value)
+	})
+
+	t.Run("when key expire", func(t *testing.T) {
+		s, c := redistest.NewClient(t)
+
+		assert2.NoError(t, c.SetWithExpiration("session", "1", time.Minute))
+
+		ttl, err := c.TTL("session")
+		assert2.NoError(t, err)
+		assert2.Equal(t, time.Minute, ttl)
+
+		s.FastForward(time.Minute)
+
+		var value text
+		assert2.Error(t, c.Get("session", &value))
+		assert2.Empty(t, s.Keys(0))
+	})
+
+	t.Run("when using hashes", func(t *testing.T) {
+		_, c := redistest.NewClient(t)
+
+		assert2.NoError(t, c.HMSet("user:1", map[string]interface{}{"name": "andree", "age": 20}))
+		assert2.NoError(t, c.HSet("user:1", "city", "medan"))
+		assert2.NoError(t, c.HDel("user:1", "age"))
+
+		all, err := c.HGetAll("user:1")
+		assert2.NoError(t, err)
+		assert2.Equal(t, map[string]string{"name": "andree", "city": "medan"}, all)
+
+		values, err := c.HMGet("user:1", "name", "age")
+		assert2.NoError(t, err)
+		assert2.Equal(t, []interface{}{"andree", nil}, values)
+	})
+
+	t.Run("when using sorted sets", func(t *testing.T) {
+		_, c := redistest.NewClient(t)
+
+		assert2.NoError(t, c.SetZSet("rank", gr.Z{Score: 2, Member: "b"}, gr.Z{Score: 1, Member: "a"}))
+
+		score, err := c.ZIncrBy("rank", 1.5, "a")
+		assert2.NoError(t, err)
+		assert2.Equal(t, 2.5, score)
+
+		members, err := c.GetZSet("rank")
+		assert2.NoError(t, err)
+		assert2.Equal(t, []gr.Z{{Score: 2, Member: "b"}, {Score: 2.5, Member: "a"}}, members)
+	})
+
+	t.Run("when getting and setting many keys", func(t *testing.T) {
+		s, c := redistest.NewClient(t)
+
+		assert2.NoError(t, c.MSet([]string{"a", "b"}, []interface{}{"1", "2"}))
+
+		values, err := c.MGet([]string{"a", "missing", "b"})
+		assert2.NoError(t, err)
+		assert2.Equal(t, []interface{}{"1", nil, "2"}, values)
+
+		assert2.NoError(t, c.RemoveByPattern("*", 10))
+		assert2.Empty(t, s.Keys(0))
+	})
+
+	t.Run("when counting unique elements", func(t *testing.T) {
+		_, c := redistest.NewClient(t)
+
+		changed, err := c.PFAdd("visitors:mon", "a", "b")
+		assert2.NoError(t, err)
+		assert2.True(t, changed)
+
+		changed, err = c.PFAdd("visitors:mon", "a")
+		assert2.NoError(t, err)
+		assert2.False(t, changed)
+
+		_, err = c.PFAdd("visitors:tue", "b", "c")
+		assert2.NoError(t, err)
+
+		assert2.NoError(t, c.PFMerge("visitors:week", "visitors:mon", "visitors:tue"))
+
+		count, err := c.PFCount("visitors:week")
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(3), count)
+	})
+
+	t.Run("when searching locations", func(t *testing.T) {
+		_, c := redistest.NewClient(t)
+
+		added, err := c.GeoAdd("stores",
+			&gr.GeoLocation{Name: "palermo", Longitude: 13.361389, Latitude: 38.115556},
+			&gr.GeoLocation{Name: "catania", Longitude: 15.087269, Latitude: 37.502669},
+		)
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(2), added)
+
+		dist, err := c.GeoDist("stores", "palermo", "catania", "km")
+		assert2.NoError(t, err)
+		assert2.InDelta(t, 166.2742, dist, 0.001)
+
+		positions, err := c.GeoPos("stores", "palermo", "missing")
+		assert2.NoError(t, err)
+		assert2.InDelta(t, 13.361389, positions[0].Longitude, 0.00001)
+		assert2.Nil(t, positions[1])
+
+		query := &cache.GeoQuery{Longitude: 15, Latitude: 37, Radius: 200, Unit: "km", WithDist: true, Sort: "ASC"}
+
+		radius, err := c.GeoRadius("stores", query)
+		assert2.NoError(t, err)
+
+		search, err := c.GeoSearch("stores", query)
+		assert2.NoError(t, err)
+
+		assert2.Equal(t, radius, search)
+		assert2.Equal(t, "catania", search[0].Name)
+		assert2.InDelta(t, 56.4413, search[0].Dist, 0.001)
+		assert2.Equal(t, "palermo", search[1].Name)
+
+		box, err := c.GeoSearch("stores", &cache.GeoQuery{Member: "palermo", Width: 10, Height: 10, Unit: "km"})
+		assert2.NoError(t, err)
+		assert2.Equal(t, []gr.GeoLocation{{Name: "palermo"}}, box)
+	})
+
+	t.Run("when using pipeline", func(t *testing.T) {
+		_, c := redistest.NewClient(t)
+
+		p := c.Pipeline()
+		assert2.NoError(t, p.Set("a", "1"))
+		assert2.NoError(t, p.SetWithExpiration("b", "2", time.Minute))
+		assert2.NoError(t, p.Exec())
+
+		keys, err := c.Keys("*")
+		assert2.NoError(t, err)
+		assert2.ElementsMatch(t, []string{"a", "b"}, keys)
+	})
+
+	t.Run("when running scripts", func(t *testing.T) {
+		_, c := redistest.NewClient(t)
+
+		script := `
+			local current = redis.call("INCRBY", KEYS[1], ARGV[1])
+			redis.call("HSET", KEYS[2], "last", ARGV[1])
+			return {current, redis.call("HGET", KEYS[2], "last"), redis.call("GET", "missing")}`
+
+		value, err := c.Eval(script, []string{"counter", "stats"}, 5)
+		assert2.NoError(t, err)
+		assert2.Equal(t, []interface{}{int64(5), "5", nil}, value)
+
+		value, err = c.Eval(`return redis.status_reply("DONE")`, nil)
+		assert2.NoError(t, err)
+		assert2.Equal(t, "DONE", value)
+
+		value, err = c.Eval(`return false`, nil)
+		assert2.NoError(t, err)
+		assert2.Nil(t, value)
+
+		_, err = c.Eval(`return redis.call("HGET", KEYS[1], "x")`, []string{"counter"})
+		assert2.Error(t, err)
+		assert2.Contains(t, err.Error(), "WRONGTYPE")
+
+		value, err = c.Eval(`
+			local reply = redis.pcall("HGET", KEYS[1], "x")
+			if reply.err then return "caught" end
+			return "missed"`, []string{"counter"})
+		assert2.NoError(t, err)
+		assert2.Equal(t, "caught", value)
+	})
+
+	t.Run("when publishing to subscribers", func(t *testing.T) {
+		_, c := redistest.NewClient(t)
+
+		p, err := c.Subscribe("events")
+		assert2.NoError(t, err)
+		assert2.NoError(t, p.Receive())
+
+		assert2.NoError(t, p.Publish("hello"))
+
+		select {
+		case message := <-p.Channel():
+			assert2.Equal(t, "events", message.Channel)
+			assert2.Equal(t, "hello", message.Payload)
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	})
+
+	t.Run("when password is required", func(t *testing.T) {
+		s, err := redistest.New()
+		assert2.NoError(t, err)
+		defer s.Close()
+
+		s.RequirePass("secret")
+
+		_, err = redis.New(&redis.Option{Address: s.Addr()})
+		assert2.Error(t, err)
+
+		c, err := redis.New(&redis.Option{Address: s.Addr(), Password: "secret"})
+		assert2.NoError(t, err)
+		assert2.NoError(t, c.Close())
+	})
+
+	t.Run("when used by the cluster client", func(t *testing.T) {
+		s, err := redistest.New()
+		assert2.NoError(t, err)
+		defer s.Close()
+
+		c, err := redis_cluster.New(&redis_cluster.Option{Address: []string{s.Addr()}})
+		assert2.NoError(t, err)
+		defer c.Close()
+
+		assert2.NoError(t, c.Set("name", "andree"))
+
+		var value text
+		assert2.NoError(t, c.Get("name", &value))
+		assert2.Equal(t, text("andree"), value)
+	})
+}
@@ -0,0 +1,486 @@
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const sweepInterval = 50 * time.Millisecond
+
+type (
+	// Server is an in-process redis speaking RESP2 over TCP, it implement
+	// the command subset used by cache.Cache, cache.Pipe and cache.PubSub
+	// so the real redis clients can be tested without an external redis.
+	// EVAL, EVALSHA and SCRIPT run the scripts with gopher-lua, redis.call
+	// and redis.pcall reach the commands above, the lua libraries of redis
+	// like cjson are not provided.
+	Server struct {
+		listener net.Listener
+
+		mu       sync.Mutex
+		password string
+		dbs      map[int]*db
+		scripts  map[string]string
+		conns    map[*conn]struct{}
+		offset   time.Duration
+		notify   string
+		closed   bool
+		stopped  chan struct{}
+		wg       sync.WaitGroup
+	}
+
+	conn struct {
+		net.Conn
+		server *Server
+
+		reader *bufio.Reader
+		wmu    sync.Mutex
+		writer *bufio.Writer
+
+		name          string
+		db            int
+		authenticated bool
+		multi         [][]string
+		inMulti       bool
+		channels      map[string]bool
+		patterns      map[string]bool
+	}
+
+	// reply types written as RESP simple string and error, any other
+	// value is written by its go type, string as bulk string and nil as
+	// null bulk string.
+	status    string
+	failure   string
+	nullArray struct{}
+)
+
+// New start a server listening on a random local port.
+func New() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to listen")
+	}
+
+	s := &Server{
+		listener: listener,
+		dbs:      make(map[int]*db),
+		scripts:  make(map[string]string),
+		conns:    make(map[*conn]struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	s.wg.Add(2)
+	go s.accept()
+	go s.sweep()
+
+	return s, nil
+}
+
+// Addr return the host:port the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stop the server and close every connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.closed = true
+	close(s.stopped)
+
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	err := s.listener.Close()
+	s.wg.Wait()
+
+	return err
+}
+
+// FastForward move the server clock forward, keys whose ttl elapse are
+// expired right away.
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offset += d
+	s.expire()
+}
+
+// RequirePass make new connections authenticate with password, as the
+// requirepass configuration of redis.
+func (s *Server) RequirePass(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.password = password
+}
+
+// Flush remove every key of every database.
+func (s *Server) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dbs = make(map[int]*db)
+}
+
+// Keys return the sorted keys of a database.
+func (s *Server) Keys(index int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db(index).keys(s.now())
+}
+
+// Subscribers return the number of connections subscribed to at least one
+// channel or pattern.
+func (s *Server) Subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscribers := 0
+	for c := range s.conns {
+		if len(c.channels)+len(c.patterns) > 0 {
+			subscribers++
+		}
+	}
+
+	return subscribers
+}
+
+// - private
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+func (s *Server) db(index int) *db {
+	d, ok := s.dbs[index]
+	if !ok {
+		d = newDB()
+		s.dbs[index] = d
+	}
+
+	return d
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &conn{
+			Conn:     nc,
+			server:   s,
+			reader:   bufio.NewReader(nc),
+			writer:   bufio.NewWriter(nc),
+			channels: make(map[string]bool),
+			patterns: make(map[string]bool),
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = nc.Close()
+			return
+		}
+		c.authenticated = s.password == ""
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go c.serve()
+	}
+}
+
+func (s *Server) sweep() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopped:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.expire()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// expire remove every expired key and publish their notifications.
+func (s *Server) expire() {
+	now := s.now()
+
+	for index, d := range s.dbs {
+		for _, key := range d.expired(now) {
+			d.delete(key)
+			s.notifyEvent(index, 'x', "expired", key)
+		}
+	}
+}
+
+// notifyEvent publish keyspace and keyevent notifications enabled by
+// notify-keyspace-events, class is the event class flag (g, $, h, z, x...).
+func (s *Server) notifyEvent(index int, class byte, event, key string) {
+	flags := s.notify
+	if flags == "" {
+		return
+	}
+
+	if !strings.ContainsRune(flags, rune(class)) && !(strings.ContainsRune(flags, 'A') && class != 'm' && class != 'n') {
+		return
+	}
+
+	if strings.ContainsRune(flags, 'K') {
+		s.publish(fmt.Sprintf("__keyspace@%d__:%s", index, key), event)
+	}
+
+	if strings.ContainsRune(flags, 'E') {
+		s.publish(fmt.Sprintf("__keyevent@%d__:%s", index, event), key)
+	}
+}
+
+// publish deliver message to subscribers and return the receiver count.
+func (s *Server) publish(channel, message string) int64 {
+	var receivers int64
+
+	for c := range s.conns {
+		if c.channels[channel] {
+			c.write([]interface{}{"message", channel, message})
+			receivers++
+		}
+
+		patterns := make([]string, 0, len(c.patterns))
+		for pattern := range c.patterns {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+
+		for _, pattern := range patterns {
+			if match(pattern, channel) {
+				c.write([]interface{}{"pmessage", pattern, channel, message})
+				receivers++
+			}
+		}
+	}
+
+	return receivers
+}
+
+func (c *conn) serve() {
+	defer c.server.wg.Done()
+	defer func() {
+		c.server.mu.Lock()
+		delete(c.server.conns, c)
+		c.server.mu.Unlock()
+		_ = c.Close()
+	}()
+
+	for {
+		args, err := c.read()
+		if err != nil {
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		if strings.ToLower(args[0]) == "quit" {
+			c.write(status("OK"))
+			return
+		}
+
+		c.server.mu.Lock()
+		reply := c.dispatch(args)
+		c.server.mu.Unlock()
+
+		c.write(reply)
+	}
+}
+
+// dispatch run a command, the server lock must be held.
+func (c *conn) dispatch(args []string) interface{} {
+	name := strings.ToLower(args[0])
+
+	if !c.authenticated && name != "auth" {
+		return failure("NOAUTH Authentication required.")
+	}
+
+	if c.inMulti {
+		switch name {
+		case "exec":
+			return c.exec()
+		case "discard":
+			c.inMulti, c.multi = false, nil
+			return status("OK")
+		case "multi":
+			return failure("ERR MULTI calls can not be nested")
+		}
+
+		if _, ok := commands[name]; !ok {
+			return failure(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		}
+
+		c.multi = append(c.multi, args)
+		return status("QUEUED")
+	}
+
+	if len(c.channels)+len(c.patterns) > 0 {
+		switch name {
+		case "subscribe", "unsubscribe", "psubscribe", "punsubscribe", "ping":
+		default:
+			return failure(fmt.Sprintf("ERR Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context", name))
+		}
+	}
+
+	switch name {
+	case "multi":
+		c.inMulti = true
+		return status("OK")
+	case "exec", "discard":
+		return failure(fmt.Sprintf("ERR %s without MULTI", strings.ToUpper(name)))
+	}
+
+	return c.run(args)
+}
+
+func (c *conn) run(args []string) interface{} {
+	command, ok := commands[strings.ToLower(args[0])]
+	if !ok {
+		return failure(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+
+	if command.arity > 0 && len(args) != command.arity || command.arity < 0 && len(args) < -command.arity {
+		return failure(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0])))
+	}
+
+	c.name = strings.ToLower(args[0])
+	return command.fn(c, args[1:])
+}
+
+func (c *conn) exec() interface{} {
+	queued := c.multi
+	c.inMulti, c.multi = false, nil
+
+	replies := make([]interface{}, 0, len(queued))
+	for _, args := range queued {
+		replies = append(replies, c.run(args))
+	}
+
+	return replies
+}
+
+// read parse a RESP array of bulk strings or an inline command.
+func (c *conn) read() ([]string, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid multibulk length")
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errors.New("expected bulk string")
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid bulk length")
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+
+		args = append(args, string(buf[:size]))
+	}
+
+	return args, nil
+}
+
+func (c *conn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *conn) write(reply interface{}) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	encode(c.writer, reply)
+	_ = c.writer.Flush()
+}
+
+func encode(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		_, _ = w.WriteString("$-1\r\n")
+	case nullArray:
+		_, _ = w.WriteString("*-1\r\n")
+	case status:
+		_, _ = w.WriteString("+" + string(v) + "\r\n")
+	case failure:
+		_, _ = w.WriteString("-" + string(v) + "\r\n")
+	case int:
+		_, _ = w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+	case int64:
+		_, _ = w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case string:
+		_, _ = w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	case []string:
+		_, _ = w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, item := range v {
+			encode(w, item)
+		}
+	case []interface{}:
+		_, _ = w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, item := range v {
+			encode(w, item)
+		}
+	default:
+		encode(w, failure(fmt.Sprintf("ERR unsupported reply %T", reply)))
+	}
+}
@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const maxRetryBudgetBalance = 100
+
+var (
+	// nonIdempotentCommands are commands that change their outcome when
+	// executed twice, they are only retried when the error guarantees the
+	// command never reached redis.
+	nonIdempotentCommands = map[string]bool{
+		"append":       true,
+		"blmove":       true,
+		"blpop":        true,
+		"brpop":        true,
+		"brpoplpush":   true,
+		"bzpopmax":     true,
+		"bzpopmin":     true,
+		"decr":         true,
+		"decrby":       true,
+		"eval":         true,
+		"evalsha":      true,
+		"exec":         true,
+		"getset":       true,
+		"hincrby":      true,
+		"hincrbyfloat": true,
+		"hsetnx":       true,
+		"incr":         true,
+		"incrby":       true,
+		"incrbyfloat":  true,
+		"linsert":      true,
+		"lmove":        true,
+		"lpop":         true,
+		"lpush":        true,
+		"lpushx":       true,
+		"msetnx":       true,
+		"publish":      true,
+		"rpop":         true,
+		"rpoplpush":    true,
+		"rpush":        true,
+		"rpushx":       true,
+		"setnx":        true,
+		"spop":         true,
+		"xadd":         true,
+		"zincrby":      true,
+		"zpopmax":      true,
+		"zpopmin":      true,
+	}
+
+	// retryableReplies are redis error replies returned before the command
+	// is executed, it is safe to retry them for every command.
+	retryableReplies = []string{
+		"LOADING ",
+		"READONLY ",
+		"CLUSTERDOWN ",
+		"TRYAGAIN ",
+		"MASTERDOWN ",
+		"ERR max number of clients reached",
+	}
+)
+
+type (
+	// RetryPolicy is an exponential backoff policy, MaxAttempts count the
+	// first attempt so 1 or less disable retry.
+	RetryPolicy struct {
+		MaxAttempts int
+		MinBackoff  time.Duration
+		MaxBackoff  time.Duration
+	}
+
+	// RetryOption is the application level retry applied on top of go-redis
+	// MaxRetries. Commands override the policy by lowercase command name.
+	//
+	// BudgetRatio limit retries to a fraction of the executed commands, with
+	// BudgetMinPerSecond retries always allowed, so a failing redis is not
+	// hammered by a retry storm. A zero BudgetRatio disable the budget.
+	RetryOption struct {
+		Idempotent         RetryPolicy
+		NonIdempotent      RetryPolicy
+		Commands           map[string]RetryPolicy
+		BudgetRatio        float64
+		BudgetMinPerSecond int
+	}
+
+	Retrier struct {
+		option *RetryOption
+		budget *retryBudget
+	}
+
+	retryBudget struct {
+		mu           sync.Mutex
+		ratio        float64
+		minPerSecond int
+		balance      float64
+		reserve      int
+		second       int64
+	}
+)
+
+func DefaultRetryOption() *RetryOption {
+	return &RetryOption{
+		Idempotent:         RetryPolicy{MaxAttempts: 3, MinBackoff: 8 * time.Millisecond, MaxBackoff: 512 * time.Millisecond},
+		NonIdempotent:      RetryPolicy{MaxAttempts: 2, MinBackoff: 8 * time.Millisecond, MaxBackoff: 512 * time.Millisecond},
+		BudgetRatio:        0.1,
+		BudgetMinPerSecond: 10,
+	}
+}
+
+func NewRetrier(option *RetryOption) *Retrier {
+	r := &Retrier{option: option}
+
+	if option.BudgetRatio > 0 {
+		r.budget = &retryBudget{ratio: option.BudgetRatio, minPerSecond: option.BudgetMinPerSecond}
+	}
+
+	return r
+}
+
+// WrapProcess is meant to be given to go-redis client WrapProcess.
+func (r *Retrier) WrapProcess(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+	return func(cmd redis.Cmder) error {
+		idempotent := IsIdempotent(cmd)
+
+		return r.do(r.policy(cmd.Name(), idempotent), idempotent, func() error {
+			return old(cmd)
+		})
+	}
+}
+
+// WrapProcessPipeline is meant to be given to go-redis client
+// WrapProcessPipeline, a pipeline is idempotent only when all of its
+// commands are idempotent.
+func (r *Retrier) WrapProcessPipeline(old func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+	return func(cmds []redis.Cmder) error {
+		idempotent := true
+		for _, cmd := range cmds {
+			if !IsIdempotent(cmd) {
+				idempotent = false
+				break
+			}
+		}
+
+		return r.do(r.policy("", idempotent), idempotent, func() error {
+			return old(cmds)
+		})
+	}
+}
+
+// IsIdempotent return true if the command can be executed more than once
+// with the same outcome.
+func IsIdempotent(cmd redis.Cmder) bool {
+	name := strings.ToLower(cmd.Name())
+
+	if nonIdempotentCommands[name] {
+		return false
+	}
+
+	// - SET key value NX|XX reply depends on the previous attempt
+	if name == "set" {
+		args := cmd.Args()
+		for i := 1; i < len(args); i++ {
+			if s, ok := args[i].(string); ok && (strings.EqualFold(s, "nx") || strings.EqualFold(s, "xx")) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// IsRetryable return true if the error is transient, for non idempotent
+// commands only errors raised before the command was written are retryable.
+func IsRetryable(err error, idempotent bool) bool {
+	if err == nil || err == redis.Nil {
+		return false
+	}
+
+	if err.Error() == "redis: connection pool timeout" {
+		return true
+	}
+
+	if netErr, ok := err.(net.Error); ok {
+		if opErr, ok := netErr.(*net.OpError); ok && opErr.Op == "dial" {
+			return true
+		}
+		return idempotent
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return idempotent
+	}
+
+	s := err.Error()
+	for _, prefix := range retryableReplies {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Backoff return the exponential backoff with equal jitter for the given
+// retry, which start from 1.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	if p.MinBackoff <= 0 {
+		return 0
+	}
+
+	d := float64(p.MinBackoff) * math.Pow(2, float64(retry-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	half := d / 2
+	return time.Duration(half + rand.Float64()*half)
+}
+
+func (r *Retrier) policy(name string, idempotent bool) RetryPolicy {
+	if policy, ok := r.option.Commands[strings.ToLower(name)]; ok {
+		return policy
+	}
+
+	if idempotent {
+		return r.option.Idempotent
+	}
+
+	return r.option.NonIdempotent
+}
+
+func (r *Retrier) do(policy RetryPolicy, idempotent bool, process func() error) error {
+	err := process()
+
+	if r.budget != nil {
+		r.budget.deposit()
+	}
+
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		if !IsRetryable(err, idempotent) {
+			return err
+		}
+
+		if r.budget != nil && !r.budget.withdraw() {
+			return err
+		}
+
+		time.Sleep(policy.Backoff(attempt))
+		err = process()
+	}
+
+	return err
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.balance = math.Min(b.balance+b.ratio, maxRetryBudgetBalance)
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now := time.Now().Unix(); now != b.second {
+		b.second = now
+		b.reserve = b.minPerSecond
+	}
+
+	if b.reserve > 0 {
+		b.reserve--
+		return true
+	}
+
+	if b.balance >= 1 {
+		b.balance--
+		return true
+	}
+
+	return false
+}
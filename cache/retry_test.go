@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func Test_Retrier(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	t.Run("when idempotent command fails with network error", func(t *testing.T) {
+		retrier := NewRetrier(&RetryOption{Idempotent: policy})
+		attempts := 0
+
+		process := retrier.WrapProcess(func(cmd redis.Cmder) error {
+			attempts++
+			if attempts < 3 {
+				return io.EOF
+			}
+			return nil
+		})
+
+		assert2.NoError(t, process(redis.NewStringCmd("get", "key")))
+		assert2.Equal(t, 3, attempts)
+	})
+
+	t.Run("when non idempotent command fails after being sent", func(t *testing.T) {
+		retrier := NewRetrier(&RetryOption{Idempotent: policy, NonIdempotent: policy})
+		attempts := 0
+
+		process := retrier.WrapProcess(func(cmd redis.Cmder) error {
+			attempts++
+			return io.EOF
+		})
+
+		assert2.Equal(t, io.EOF, process(redis.NewIntCmd("incr", "key")))
+		assert2.Equal(t, 1, attempts)
+	})
+
+	t.Run("when a push fails after being sent", func(t *testing.T) {
+		retrier := NewRetrier(&RetryOption{Idempotent: policy, NonIdempotent: policy})
+		attempts := 0
+
+		process := retrier.WrapProcess(func(cmd redis.Cmder) error {
+			attempts++
+			return io.EOF
+		})
+
+		assert2.Equal(t, io.EOF, process(redis.NewStringCmd("xadd", "stream", "*", "field", "value")))
+		assert2.Equal(t, 1, attempts)
+	})
+
+	t.Run("when non idempotent command fails to dial", func(t *testing.T) {
+		retrier := NewRetrier(&RetryOption{NonIdempotent: policy})
+		attempts := 0
+
+		process := retrier.WrapProcess(func(cmd redis.Cmder) error {
+			attempts++
+			return &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+		})
+
+		assert2.Error(t, process(redis.NewIntCmd("incr", "key")))
+		assert2.Equal(t, 3, attempts)
+	})
+
+	t.Run("when budget is exhausted", func(t *testing.T) {
+		retrier := NewRetrier(&RetryOption{Idempotent: policy, BudgetRatio: 0.1})
+		attempts := 0
+
+		process := retrier.WrapProcess(func(cmd redis.Cmder) error {
+			attempts++
+			return io.EOF
+		})
+
+		assert2.Equal(t, io.EOF, process(redis.NewStringCmd("get", "key")))
+		assert2.Equal(t, 1, attempts)
+	})
+}
+
+func Test_IsIdempotent(t *testing.T) {
+	assert2.True(t, IsIdempotent(redis.NewStatusCmd("set", "key", "value", "ex", 10)))
+	assert2.False(t, IsIdempotent(redis.NewStatusCmd("set", "key", "value", "ex", 10, "nx")))
+	assert2.False(t, IsIdempotent(redis.NewFloatCmd("zincrby", "key", 1, "member")))
+	assert2.False(t, IsIdempotent(redis.NewIntCmd("INCR", "key")))
+
+	for _, name := range []string{"hsetnx", "msetnx", "lpushx", "rpushx", "xadd", "blpop", "brpop"} {
+		assert2.False(t, IsIdempotent(redis.NewCmd(name, "key")), name)
+	}
+}
+
+func Test_RetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}
+
+	for retry := 1; retry <= 5; retry++ {
+		backoff := policy.Backoff(retry)
+
+		assert2.True(t, backoff >= 5*time.Millisecond)
+		assert2.True(t, backoff <= 40*time.Millisecond)
+	}
+}
@@ -0,0 +1,232 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+)
+
+const (
+	Version = 1
+
+	DefaultCount = 1000
+
+	// maxLine bound a record line, DUMP payloads are base64 encoded in it.
+	maxLine = 512 * 1024 * 1024
+)
+
+type (
+	// header is the first line of a snapshot file.
+	header struct {
+		Version   int       `json:"version"`
+		Match     string    `json:"match"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// record is a key serialized with DUMP. ExpireAt is the unix time in
+	// milliseconds the key expire at, zero when the key has no expiry, so
+	// the elapsed time between export and import is accounted for.
+	record struct {
+		Key      string `json:"key"`
+		ExpireAt int64  `json:"expire_at,omitempty"`
+		Value    []byte `json:"value"`
+	}
+
+	ExportOption struct {
+		// Match is the SCAN pattern of the exported namespace, every key
+		// when empty.
+		Match string
+		Count int64
+	}
+
+	ImportOption struct {
+		// Replace overwrite existing keys, otherwise they are left untouched.
+		Replace bool
+	}
+)
+
+// Export write the keys matching option.Match as json lines, every key is
+// read with DUMP and TTL so any type is exported with its expiry. The DUMP
+// format is specific to the redis version, import into the same or a newer
+// version. It return the number of exported keys.
+func Export(c cache.Cache, w io.Writer, option *ExportOption) (int, error) {
+	match := option.Match
+	if match == "" {
+		match = "*"
+	}
+
+	count := option.Count
+	if count <= 0 {
+		count = DefaultCount
+	}
+
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+
+	if err := encoder.Encode(header{Version: Version, Match: match, CreatedAt: time.Now()}); err != nil {
+		return 0, errors.Wrap(err, "failed to write snapshot header")
+	}
+
+	exported := 0
+	err := c.Scan(match, count, func(keys []string) error {
+		for _, key := range keys {
+			r, err := read(c, key)
+			if err != nil {
+				return err
+			}
+
+			// expired or removed since the scan
+			if r == nil {
+				continue
+			}
+
+			if err := encoder.Encode(r); err != nil {
+				return errors.Wrapf(err, "failed to write key %s", key)
+			}
+			exported++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return exported, err
+	}
+
+	if err := writer.Flush(); err != nil {
+		return exported, errors.Wrap(err, "failed to flush snapshot")
+	}
+
+	return exported, nil
+}
+
+// Import restore the keys written by Export, keys whose expiry passed are
+// skipped. It return the number of restored keys.
+func Import(c cache.Cache, r io.Reader, option *ImportOption) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return 0, errors.Wrap(err, "failed to read snapshot header")
+		}
+		return 0, errors.New("snapshot is empty")
+	}
+
+	h := header{}
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+		return 0, errors.Wrap(err, "failed to read snapshot header")
+	}
+
+	if h.Version != Version {
+		return 0, errors.Errorf("unsupported snapshot version %d", h.Version)
+	}
+
+	imported := 0
+	for scanner.Scan() {
+		rec := record{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return imported, errors.Wrapf(err, "failed to read record %d", imported+1)
+		}
+
+		var ttl time.Duration
+		if rec.ExpireAt > 0 {
+			ttl = time.Until(time.Unix(0, rec.ExpireAt*int64(time.Millisecond)))
+			if ttl < time.Millisecond {
+				continue
+			}
+		}
+
+		if err := c.Restore(rec.Key, ttl, string(rec.Value), option.Replace); err != nil {
+			if !option.Replace && isBusyKey(err) {
+				continue
+			}
+			return imported, err
+		}
+		imported++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return imported, errors.Wrap(err, "failed to read snapshot")
+	}
+
+	return imported, nil
+}
+
+// ExportFile export to the file at path, the file is created or truncated.
+func ExportFile(c cache.Cache, path string, option *ExportOption) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to create snapshot %s", path)
+	}
+
+	exported, err := Export(c, f, option)
+	if err != nil {
+		_ = f.Close()
+		return exported, err
+	}
+
+	if err := f.Close(); err != nil {
+		return exported, errors.Wrapf(err, "failed to close snapshot %s", path)
+	}
+
+	return exported, nil
+}
+
+// ImportFile import the file at path.
+func ImportFile(c cache.Cache, path string, option *ImportOption) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to open snapshot %s", path)
+	}
+	defer f.Close()
+
+	return Import(c, f, option)
+}
+
+// - private
+
+// read return nil record without error when the key does not exist.
+func read(c cache.Cache, key string) (*record, error) {
+	ttl, err := c.TTL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// TTL reply -2 when the key does not exist and -1 when it has no expiry
+	if ttl == -2*time.Second {
+		return nil, nil
+	}
+
+	value, err := c.Dump(key)
+	if err != nil {
+		if isNil(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	r := &record{Key: key, Value: []byte(value)}
+	if ttl > 0 {
+		r.ExpireAt = time.Now().Add(ttl).UnixNano() / int64(time.Millisecond)
+	}
+
+	return r, nil
+}
+
+func isNil(err error) bool {
+	return errors.Cause(err) == redis.Nil
+}
+
+// isBusyKey report the RESTORE error of an existing key.
+func isBusyKey(err error) bool {
+	return strings.HasPrefix(errors.Cause(err).Error(), "BUSYKEY")
+}
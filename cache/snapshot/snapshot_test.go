@@ -0,0 +1,83 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	gr "github.com/go-redis/redis"
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redistest"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/snapshot"
+)
+
+func newCache(t *testing.T) cache.Cache {
+	_, c := redistest.NewClient(t)
+
+	return c
+}
+
+func Test_Snapshot(t *testing.T) {
+
+	t.Run("when namespace is exported and imported", func(t *testing.T) {
+		source := newCache(t)
+
+		assert2.NoError(t, source.SetWithExpiration("user:1", "andree", time.Hour))
+		assert2.NoError(t, source.HMSet("user:2", map[string]interface{}{"name": "jait"}))
+		assert2.NoError(t, source.SetZSet("user:rank", gr.Z{Score: 1, Member: "1"}))
+		assert2.NoError(t, source.Set("order:1", "ignored"))
+
+		buf := &bytes.Buffer{}
+		exported, err := snapshot.Export(source, buf, &snapshot.ExportOption{Match: "user:*"})
+		assert2.NoError(t, err)
+		assert2.Equal(t, 3, exported)
+
+		target := newCache(t)
+		imported, err := snapshot.Import(target, buf, &snapshot.ImportOption{})
+		assert2.NoError(t, err)
+		assert2.Equal(t, 3, imported)
+
+		ttl, err := target.TTL("user:1")
+		assert2.NoError(t, err)
+		assert2.InDelta(t, time.Hour.Seconds(), ttl.Seconds(), 1)
+
+		hash, err := target.HGetAll("user:2")
+		assert2.NoError(t, err)
+		assert2.Equal(t, map[string]string{"name": "jait"}, hash)
+
+		keys, err := target.Keys("order:*")
+		assert2.NoError(t, err)
+		assert2.Empty(t, keys)
+	})
+
+	t.Run("when key already exists", func(t *testing.T) {
+		source := newCache(t)
+		assert2.NoError(t, source.Set("user:1", "snapshot"))
+
+		buf := &bytes.Buffer{}
+		_, err := snapshot.Export(source, buf, &snapshot.ExportOption{})
+		assert2.NoError(t, err)
+		data := buf.Bytes()
+
+		target := newCache(t)
+		assert2.NoError(t, target.Set("user:1", "current"))
+
+		imported, err := snapshot.Import(target, bytes.NewReader(data), &snapshot.ImportOption{})
+		assert2.NoError(t, err)
+		assert2.Equal(t, 0, imported)
+
+		values, err := target.MGet([]string{"user:1"})
+		assert2.NoError(t, err)
+		assert2.Equal(t, []interface{}{"current"}, values)
+
+		imported, err = snapshot.Import(target, bytes.NewReader(data), &snapshot.ImportOption{Replace: true})
+		assert2.NoError(t, err)
+		assert2.Equal(t, 1, imported)
+
+		values, err = target.MGet([]string{"user:1"})
+		assert2.NoError(t, err)
+		assert2.Equal(t, []interface{}{"snapshot"}, values)
+	})
+}
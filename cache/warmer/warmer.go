@@ -0,0 +1,133 @@
+package warmer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+)
+
+const DefaultConcurrency = 4
+
+type (
+	// Task load one cache entry, typically by calling a loader Fetch so the
+	// entry has the same shape as the ones loaded on demand.
+	Task struct {
+		Name string
+		Load func(ctx context.Context) error
+	}
+
+	// Option bound the number of tasks loading at the same time so warming
+	// does not stampede the source of truth. Timeout limit each task when
+	// set, and ContinueOnError keep running the remaining tasks after a
+	// failure.
+	Option struct {
+		Concurrency     int
+		Timeout         time.Duration
+		ContinueOnError bool
+		Logger          logs.Logger
+	}
+
+	Result struct {
+		Loaded   int
+		Failed   int
+		Duration time.Duration
+	}
+)
+
+// Warm run the tasks with bounded concurrency and return when every task
+// finished, ctx cancel the tasks not started yet.
+func Warm(ctx context.Context, tasks []Task, option *Option) (Result, error) {
+	concurrency := option.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		start  = time.Now()
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result Result
+		failed []string
+		slots  = make(chan struct{}, concurrency)
+	)
+
+	for _, task := range tasks {
+		select {
+		case <-ctx.Done():
+		case slots <- struct{}{}:
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(task Task) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			err := run(ctx, task, option.Timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err == nil {
+				result.Loaded++
+				return
+			}
+
+			result.Failed++
+			failed = append(failed, fmt.Sprintf("%s: %s", task.Name, err))
+
+			if option.Logger != nil {
+				option.Logger.Errorf("failed to warm %s: %s", task.Name, err)
+			}
+
+			if !option.ContinueOnError {
+				cancel()
+			}
+		}(task)
+	}
+
+	wg.Wait()
+	result.Duration = time.Since(start)
+
+	if len(failed) > 0 {
+		return result, errors.New("failed to warm some entries " + strings.Join(failed, ", "))
+	}
+
+	if skipped := len(tasks) - result.Loaded; skipped > 0 {
+		return result, errors.Wrapf(ctx.Err(), "warming stopped with %d entries left", skipped)
+	}
+
+	return result, nil
+}
+
+// - private
+
+func run(ctx context.Context, task Task, timeout time.Duration) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("panic: %v", r))
+		}
+	}()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return task.Load(ctx)
+}
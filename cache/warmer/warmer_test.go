@@ -0,0 +1,73 @@
+package warmer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func Test_Warm(t *testing.T) {
+
+	t.Run("when tasks exceed the concurrency", func(t *testing.T) {
+		var running, peak int32
+
+		tasks := make([]Task, 20)
+		for i := range tasks {
+			tasks[i] = Task{Name: fmt.Sprintf("task-%d", i), Load: func(ctx context.Context) error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			}}
+		}
+
+		result, err := Warm(context.Background(), tasks, &Option{Concurrency: 3})
+		assert2.NoError(t, err)
+		assert2.Equal(t, 20, result.Loaded)
+		assert2.LessOrEqual(t, peak, int32(3))
+	})
+
+	t.Run("when a task fails", func(t *testing.T) {
+		var started int32
+
+		tasks := make([]Task, 10)
+		for i := range tasks {
+			i := i
+			tasks[i] = Task{Name: fmt.Sprintf("task-%d", i), Load: func(ctx context.Context) error {
+				atomic.AddInt32(&started, 1)
+				if i == 0 {
+					return errors.New("db down")
+				}
+				return nil
+			}}
+		}
+
+		result, err := Warm(context.Background(), tasks, &Option{Concurrency: 1})
+		assert2.Error(t, err)
+		assert2.Equal(t, 1, result.Failed)
+		assert2.Less(t, atomic.LoadInt32(&started), int32(10))
+	})
+
+	t.Run("when errors are tolerated", func(t *testing.T) {
+		tasks := []Task{
+			{Name: "panic", Load: func(ctx context.Context) error { panic("boom") }},
+			{Name: "ok", Load: func(ctx context.Context) error { return nil }},
+		}
+
+		result, err := Warm(context.Background(), tasks, &Option{Concurrency: 1, ContinueOnError: true})
+		assert2.EqualError(t, err, "failed to warm some entries panic: panic: boom")
+		assert2.Equal(t, Result{Loaded: 1, Failed: 1, Duration: result.Duration}, result)
+	})
+}
@@ -0,0 +1,298 @@
+package idempotency
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+)
+
+const (
+	DefaultPrefix  = "idempotency"
+	DefaultTTL     = 24 * time.Hour
+	DefaultLockTTL = time.Minute
+
+	InProgress Status = "in_progress"
+	Completed  Status = "completed"
+
+	// claimAttempts bound the retries when the key expire between the
+	// failed SetNx and the read of the current record.
+	claimAttempts = 3
+
+	// completeScript replace the lock by the response only while the key
+	// still hold the lock of the lease, byte for byte, its token make it
+	// unique. KEYS: key - ARGV: lock, response record, ttl in milliseconds
+	completeScript = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`
+
+	// releaseScript delete the key only while it still hold the lock of the
+	// lease. KEYS: key - ARGV: lock
+	releaseScript = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+return 1
+`
+)
+
+var (
+	// ErrInProgress is returned while another request with the same key is
+	// being handled.
+	ErrInProgress = errors.New("request with the same idempotency key is in progress")
+
+	// ErrFingerprintMismatch is returned when the key is reused with a
+	// different request payload.
+	ErrFingerprintMismatch = errors.New("idempotency key was used with a different request")
+
+	// ErrLostLease is returned when completing a request whose lock expired,
+	// another request may be handling the key meanwhile.
+	ErrLostLease = errors.New("idempotency key is no longer held by this request")
+)
+
+type (
+	Status string
+
+	// Response is the outcome replayed to retries of a completed request.
+	Response struct {
+		StatusCode int               `json:"status_code"`
+		Header     map[string]string `json:"header,omitempty"`
+		Body       []byte            `json:"body,omitempty"`
+	}
+
+	// Lease is held by the request that claimed the key, it must be
+	// completed or released.
+	Lease struct {
+		Key         string
+		Fingerprint string
+
+		// lock is the record claiming the key, as stored.
+		lock string
+	}
+
+	// Option configure a Store. Completed responses are kept for TTL, and a
+	// request in progress hold the key for LockTTL so a crashed handler does
+	// not block the key until TTL.
+	Option struct {
+		Prefix  string
+		TTL     time.Duration
+		LockTTL time.Duration
+	}
+
+	Store interface {
+		// Begin claim key for a request. It return a Lease when the caller
+		// must handle the request, or the stored Response when the request
+		// was already completed.
+		Begin(key, fingerprint string) (*Lease, *Response, error)
+		// Complete store the response for replays and release the lock.
+		Complete(lease *Lease, response *Response) error
+		// Release remove the lock without storing a response so the request
+		// can be retried, typically after a failure that had no side effect.
+		Release(lease *Lease) error
+		// Do handle the request once with fn, replays get the stored response.
+		// A failed fn release the key and return its error.
+		Do(key, fingerprint string, fn func() (*Response, error)) (*Response, error)
+	}
+
+	record struct {
+		Status      Status    `json:"status"`
+		Fingerprint string    `json:"fingerprint"`
+		Token       string    `json:"token,omitempty"`
+		Response    *Response `json:"response,omitempty"`
+	}
+
+	store struct {
+		cache  cache.Cache
+		option Option
+	}
+)
+
+func New(c cache.Cache, option *Option) (Store, error) {
+	if c == nil {
+		return nil, errors.New("cache is required!")
+	}
+
+	o := *option
+	if o.Prefix == "" {
+		o.Prefix = DefaultPrefix
+	}
+	if o.TTL <= 0 {
+		o.TTL = DefaultTTL
+	}
+	if o.LockTTL <= 0 {
+		o.LockTTL = DefaultLockTTL
+	}
+
+	return &store{cache: c, option: o}, nil
+}
+
+// Fingerprint hash the parts identifying a request, such as the method,
+// path and body.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+
+	for _, part := range parts {
+		_, _ = h.Write(part)
+		_, _ = h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (r *record) MarshalBinary() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func (r *record) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, r)
+}
+
+func (s *store) Begin(key, fingerprint string) (*Lease, *Response, error) {
+	if key == "" {
+		return nil, nil, errors.New("idempotency key is required!")
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lock := &record{Status: InProgress, Fingerprint: fingerprint, Token: token}
+
+	body, err := lock.MarshalBinary()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to marshal idempotency lock")
+	}
+
+	for i := 0; i < claimAttempts; i++ {
+		claimed, err := s.cache.SetNx(s.key(key), body, s.option.LockTTL)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to claim idempotency key %s", key)
+		}
+
+		if claimed {
+			return &Lease{Key: key, Fingerprint: fingerprint, lock: string(body)}, nil, nil
+		}
+
+		current, err := s.read(key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if current.Fingerprint != fingerprint {
+			return nil, nil, ErrFingerprintMismatch
+		}
+
+		if current.Status != Completed {
+			return nil, nil, ErrInProgress
+		}
+
+		return nil, current.Response, nil
+	}
+
+	return nil, nil, errors.Wrapf(ErrInProgress, "failed to claim idempotency key %s", key)
+}
+
+func (s *store) Complete(lease *Lease, response *Response) error {
+	done, err := (&record{Status: Completed, Fingerprint: lease.Fingerprint, Response: response}).MarshalBinary()
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal response of idempotency key %s", lease.Key)
+	}
+
+	ttl := s.option.TTL.Milliseconds()
+
+	res, err := s.cache.Eval(completeScript, []string{s.key(lease.Key)}, lease.lock, done, ttl)
+	if err != nil {
+		return errors.Wrapf(err, "failed to store response of idempotency key %s", lease.Key)
+	}
+
+	return owned(res)
+}
+
+func (s *store) Release(lease *Lease) error {
+	res, err := s.cache.Eval(releaseScript, []string{s.key(lease.Key)}, lease.lock)
+	if err != nil {
+		return errors.Wrapf(err, "failed to release idempotency key %s", lease.Key)
+	}
+
+	return owned(res)
+}
+
+func (s *store) Do(key, fingerprint string, fn func() (*Response, error)) (*Response, error) {
+	lease, response, err := s.Begin(key, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	if lease == nil {
+		return response, nil
+	}
+
+	response, err = fn()
+	if err != nil {
+		if releaseErr := s.Release(lease); releaseErr != nil {
+			return nil, errors.Wrapf(err, "failed to release key after error: %s", releaseErr)
+		}
+		return nil, err
+	}
+
+	if err := s.Complete(lease, response); err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+// - private
+
+func (s *store) key(key string) string {
+	return s.option.Prefix + ":" + key
+}
+
+// read return nil record without error when the key does not exist.
+func (s *store) read(key string) (*record, error) {
+	r := &record{}
+
+	if err := s.cache.Get(s.key(key), r); err != nil {
+		if errors.Cause(err) == redis.Nil {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get idempotency key %s", key)
+	}
+
+	return r, nil
+}
+
+// owned return ErrLostLease when a script found another record than the
+// lock of the lease.
+func owned(res interface{}) error {
+	if n, _ := res.(int64); n == 0 {
+		return ErrLostLease
+	}
+
+	return nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate lease token")
+	}
+
+	return hex.EncodeToString(b), nil
+}
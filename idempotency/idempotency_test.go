@@ -0,0 +1,109 @@
+package idempotency_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redistest"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/idempotency"
+)
+
+func newStore(t *testing.T) (*redistest.Server, idempotency.Store) {
+	s, c := redistest.NewClient(t)
+
+	store, err := idempotency.New(c, &idempotency.Option{LockTTL: time.Second})
+	assert2.NoError(t, err)
+
+	return s, store
+}
+
+func Test_Store(t *testing.T) {
+	fingerprint := idempotency.Fingerprint([]byte("POST"), []byte("/orders"), []byte(`{"amount":10}`))
+
+	t.Run("when request is replayed", func(t *testing.T) {
+		_, store := newStore(t)
+
+		calls := 0
+		handle := func() (*idempotency.Response, error) {
+			calls++
+			return &idempotency.Response{StatusCode: 201, Body: []byte(`{"id":1}`)}, nil
+		}
+
+		first, err := store.Do("order-1", fingerprint, handle)
+		assert2.NoError(t, err)
+
+		second, err := store.Do("order-1", fingerprint, handle)
+		assert2.NoError(t, err)
+
+		assert2.Equal(t, 1, calls)
+		assert2.Equal(t, first, second)
+	})
+
+	t.Run("when payload does not match", func(t *testing.T) {
+		_, store := newStore(t)
+
+		_, err := store.Do("order-1", fingerprint, func() (*idempotency.Response, error) {
+			return &idempotency.Response{StatusCode: 201}, nil
+		})
+		assert2.NoError(t, err)
+
+		_, _, err = store.Begin("order-1", idempotency.Fingerprint([]byte("other")))
+		assert2.Equal(t, idempotency.ErrFingerprintMismatch, err)
+	})
+
+	t.Run("when request is in progress", func(t *testing.T) {
+		_, store := newStore(t)
+
+		lease, _, err := store.Begin("order-1", fingerprint)
+		assert2.NoError(t, err)
+		assert2.NotNil(t, lease)
+
+		_, _, err = store.Begin("order-1", fingerprint)
+		assert2.Equal(t, idempotency.ErrInProgress, err)
+	})
+
+	t.Run("when handler fails", func(t *testing.T) {
+		_, store := newStore(t)
+
+		_, err := store.Do("order-1", fingerprint, func() (*idempotency.Response, error) {
+			return nil, errors.New("payment gateway down")
+		})
+		assert2.EqualError(t, err, "payment gateway down")
+
+		lease, response, err := store.Begin("order-1", fingerprint)
+		assert2.NoError(t, err)
+		assert2.NotNil(t, lease)
+		assert2.Nil(t, response)
+	})
+
+	t.Run("when lock expire before completion", func(t *testing.T) {
+		s, store := newStore(t)
+
+		lease, _, err := store.Begin("order-1", fingerprint)
+		assert2.NoError(t, err)
+
+		s.FastForward(time.Second)
+
+		other, _, err := store.Begin("order-1", fingerprint)
+		assert2.NoError(t, err)
+		assert2.NotNil(t, other)
+
+		err = store.Complete(lease, &idempotency.Response{StatusCode: 201})
+		assert2.Equal(t, idempotency.ErrLostLease, err)
+
+		assert2.Equal(t, idempotency.ErrLostLease, store.Release(lease))
+
+		_, _, err = store.Begin("order-1", fingerprint)
+		assert2.Equal(t, idempotency.ErrInProgress, err)
+
+		assert2.NoError(t, store.Complete(other, &idempotency.Response{StatusCode: 201}))
+
+		_, response, err := store.Begin("order-1", fingerprint)
+		assert2.NoError(t, err)
+		assert2.Equal(t, 201, response.StatusCode)
+		assert2.Equal(t, idempotency.ErrLostLease, store.Release(other))
+	})
+}
@@ -0,0 +1,331 @@
+package persistent
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxPostgresParams and MaxMysqlParams are the placeholders a single
+	// statement can bind, MaxSqliteParams is the default of sqlite builds
+	// older than 3.32.
+	MaxPostgresParams = 65535
+	MaxMysqlParams    = 65535
+	MaxSqliteParams   = 999
+
+	// upsertAlias name the existing row of an upsert.
+	upsertAlias = "existing"
+)
+
+type (
+	// bulkColumn is a column of a bulk statement, read from the gorm model
+	// of the rows.
+	bulkColumn struct {
+		name    string
+		primary bool
+		version bool
+	}
+)
+
+// - private
+
+// bulkRows read the columns and the values of rows, every row must be a
+// struct, or a pointer to one, of the same type. Columns follow the gorm
+// naming, `gorm:"column:..."` tags included, ignored and association fields
+// are skipped.
+func (o *Impl) bulkRows(rows []interface{}) ([]bulkColumn, [][]interface{}, error) {
+	var (
+		columns []bulkColumn
+		values  = make([][]interface{}, 0, len(rows))
+		model   reflect.Type
+	)
+
+	for i, row := range rows {
+		rowType := reflect.Indirect(reflect.ValueOf(row)).Type()
+		if rowType.Kind() != reflect.Struct {
+			return nil, nil, errors.Errorf("bulk row %d is a %s, not a struct", i, rowType)
+		}
+
+		if model == nil {
+			model = rowType
+		} else if rowType != model {
+			return nil, nil, errors.Errorf("bulk row %d is a %s, expected %s", i, rowType, model)
+		}
+
+		record := make([]interface{}, 0, len(columns))
+		for _, field := range o.Database.NewScope(row).Fields() {
+			if field.IsIgnored || !field.IsNormal {
+				continue
+			}
+
+			if i == 0 {
+				_, version := field.TagSettingsGet("VERSION")
+				columns = append(columns, bulkColumn{name: field.DBName, primary: field.IsPrimaryKey, version: version})
+			}
+
+			value, err := bulkValue(field.Field.Interface())
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to bind %s of bulk row %d", field.DBName, i)
+			}
+			record = append(record, value)
+		}
+
+		values = append(values, record)
+	}
+
+	if len(columns) == 0 {
+		return nil, nil, errors.Errorf("bulk rows %s have no column", model)
+	}
+
+	return columns, values, nil
+}
+
+// bulkValue return the bound value of a column, maps, slices and structs
+// the driver can not bind, like jsonb documents, are json encoded.
+func bulkValue(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case nil, driver.Valuer, time.Time, []byte:
+		return value, nil
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		document, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(document), nil
+	}
+
+	return value, nil
+}
+
+// bulkChunk return the rows per statement, chunkSize bounded by the
+// placeholders the dialect can bind.
+func (o *Impl) bulkChunk(chunkSize, columns int) int {
+	limit := MaxPostgresParams
+
+	switch o.Database.Dialect().GetName() {
+	case "mysql":
+		limit = MaxMysqlParams
+	case "sqlite3":
+		limit = MaxSqliteParams
+	}
+
+	if rows := limit / columns; chunkSize <= 0 || chunkSize > rows {
+		return rows
+	}
+
+	return chunkSize
+}
+
+// upsertQuery build the insert of the rows bound to a single [][]interface{}
+// placeholder, on conflict of the primary key the other columns are updated.
+// With a version column, existing rows are updated only if their version
+// match the version of the row, which is incremented.
+func (o *Impl) upsertQuery(tableName string, columns []bulkColumn) (string, error) {
+	var (
+		dialect = o.Database.Dialect()
+		names   = make([]string, 0, len(columns))
+		keys    = make([]string, 0)
+		updates = make([]string, 0)
+		version string
+	)
+
+	table, err := o.bulkTable(tableName)
+	if err != nil {
+		return "", err
+	}
+
+	for _, column := range columns {
+		quoted := dialect.Quote(column.name)
+		names = append(names, quoted)
+
+		switch {
+		case column.primary:
+			keys = append(keys, quoted)
+		case column.version && version == "":
+			version = quoted
+		default:
+			updates = append(updates, quoted)
+		}
+	}
+
+	switch dialect.GetName() {
+	case "mysql":
+		insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES ?", table, strings.Join(names, ", "))
+
+		// - mysql resolve the conflict on any unique key, an update without
+		//   change keep existing rows
+		if len(updates) == 0 && version == "" {
+			updates = keys
+		}
+
+		sets := make([]string, 0, len(updates)+1)
+		for _, name := range updates {
+			if version != "" {
+				// - assignments apply in order, the version is compared before
+				//   it is incremented last
+				sets = append(sets, fmt.Sprintf("%s = IF(%s = VALUES(%s), VALUES(%s), %s)", name, version, version, name, name))
+			} else {
+				sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", name, name))
+			}
+		}
+
+		if version != "" {
+			sets = append(sets, fmt.Sprintf("%s = IF(%s = VALUES(%s), %s + 1, %s)", version, version, version, version, version))
+		}
+
+		return insert + " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "), nil
+	case "postgres", "sqlite3":
+		if len(keys) == 0 {
+			return "", errors.Errorf("upsert into %s require a primary key", tableName)
+		}
+
+		// - the alias tell the existing row from excluded in the conditions
+		insert := fmt.Sprintf("INSERT INTO %s AS %s (%s) VALUES ?", table, upsertAlias, strings.Join(names, ", "))
+
+		conflict := fmt.Sprintf("%s ON CONFLICT (%s) DO", insert, strings.Join(keys, ", "))
+		if len(updates) == 0 && version == "" {
+			return conflict + " NOTHING", nil
+		}
+
+		sets := make([]string, 0, len(updates)+1)
+		for _, name := range updates {
+			sets = append(sets, fmt.Sprintf("%s = excluded.%s", name, name))
+		}
+
+		if version == "" {
+			return conflict + " UPDATE SET " + strings.Join(sets, ", "), nil
+		}
+
+		sets = append(sets, fmt.Sprintf("%s = %s.%s + 1", version, upsertAlias, version))
+
+		return fmt.Sprintf("%s UPDATE SET %s WHERE %s.%s = excluded.%s", conflict, strings.Join(sets, ", "), upsertAlias, version, version), nil
+	}
+
+	return "", errors.Errorf("upsert is not supported by %s", dialect.GetName())
+}
+
+// bulkTable return the quoted table of a bulk statement, it is not bound so
+// it must be an identifier.
+func (o *Impl) bulkTable(tableName string) (string, error) {
+	if !identifier.MatchString(tableName) {
+		return "", errors.Errorf("table %q is not allowed", tableName)
+	}
+
+	return quoter(o.Database)(tableName), nil
+}
+
+// bulkStale return true if an upsert of rows with a version column affected
+// fewer rows than expected. Postgres and sqlite count every inserted or
+// updated row, mysql count 0 for a stale row but 1 for an inserted row and 2
+// for an updated one, so only a statement without any affected row is known
+// stale, BulkUpsert run a row per statement there.
+func (o *Impl) bulkStale(columns []bulkColumn, rows int, affected int64) bool {
+	if !hasVersion(columns) {
+		return false
+	}
+
+	if o.Database.Dialect().GetName() == "mysql" {
+		return affected == 0
+	}
+
+	return affected < int64(rows)
+}
+
+// hasVersion return true if columns have a version column.
+func hasVersion(columns []bulkColumn) bool {
+	for _, column := range columns {
+		if column.version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteQuery build the delete of the rows matching keys, the primary key
+// columns when keys is empty, and the key values of every row.
+func (o *Impl) deleteQuery(tableName string, columns []bulkColumn, rows [][]interface{}, keys []string) (string, [][]interface{}, error) {
+	dialect := o.Database.Dialect()
+
+	table, err := o.bulkTable(tableName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	positions := make([]int, 0)
+	names := make([]string, 0)
+
+	if len(keys) == 0 {
+		for i, column := range columns {
+			if column.primary {
+				positions = append(positions, i)
+				names = append(names, dialect.Quote(column.name))
+			}
+		}
+
+		if len(positions) == 0 {
+			return "", nil, errors.Errorf("delete from %s require a primary key or key columns", tableName)
+		}
+	}
+
+	for _, key := range keys {
+		found := false
+		for i, column := range columns {
+			if column.name == key {
+				positions = append(positions, i)
+				names = append(names, dialect.Quote(column.name))
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return "", nil, errors.Errorf("key column %s is not a column of the rows", key)
+		}
+	}
+
+	values := make([][]interface{}, 0, len(rows))
+	for _, row := range rows {
+		key := make([]interface{}, 0, len(positions))
+		for _, position := range positions {
+			key = append(key, row[position])
+		}
+		values = append(values, key)
+	}
+
+	if len(names) == 1 {
+		return fmt.Sprintf("DELETE FROM %s WHERE %s IN (?)", table, names[0]), values, nil
+	}
+
+	// - sqlite compare row values to a list of rows only through VALUES
+	list := "?"
+	if dialect.GetName() == "sqlite3" {
+		list = "VALUES ?"
+	}
+
+	return fmt.Sprintf("DELETE FROM %s WHERE (%s) IN (%s)", table, strings.Join(names, ", "), list), values, nil
+}
+
+// bulkKeys return the placeholder value of keys, gorm expand a []interface{}
+// to ?, ?, ... and a [][]interface{} to (?, ?), (?, ?), ...
+func bulkKeys(keys [][]interface{}) interface{} {
+	if len(keys) == 0 || len(keys[0]) > 1 {
+		return keys
+	}
+
+	values := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, key[0])
+	}
+
+	return values
+}
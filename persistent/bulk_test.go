@@ -0,0 +1,347 @@
+package persistent
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+type (
+	bulkItem struct {
+		ID   int `gorm:"primary_key"`
+		Name string
+	}
+
+	bulkVersioned struct {
+		ID      int `gorm:"primary_key"`
+		Name    string
+		Version int `gorm:"version"`
+	}
+)
+
+// mysqlRows is a connection answering the upserts of rows of columns like
+// mysql, the rows affected by each statement are those of the ids of its
+// rows: 0 for a stale row, 1 for an inserted row and 2 for an updated row.
+type mysqlRows struct {
+	gorm.SQLCommon
+	columns    int
+	affected   map[int]int64
+	statements int
+}
+
+func (m *mysqlRows) Exec(_ string, args ...interface{}) (sql.Result, error) {
+	m.statements++
+
+	var affected int64
+	// - the rows are bound flattened, id first
+	for i := 0; i < len(args); i += m.columns {
+		affected += m.affected[args[i].(int)]
+	}
+
+	return driverResult(affected), nil
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (r driverResult) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+// dialectORM return an ORM of dialect without a database, to build its
+// statements.
+func dialectORM(t *testing.T, dialect string) *Impl {
+	db, err := gorm.Open(dialect, &contextDB{})
+	assert2.NoError(t, err)
+
+	return &Impl{Database: db}
+}
+
+func Test_UpsertQuery(t *testing.T) {
+	golden := map[string]struct{ plain, versioned string }{
+		"mysql": {
+			"INSERT INTO `items` (`id`, `name`) VALUES ? ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)",
+			"INSERT INTO `items` (`id`, `name`, `version`) VALUES ? ON DUPLICATE KEY UPDATE " +
+				"`name` = IF(`version` = VALUES(`version`), VALUES(`name`), `name`), " +
+				"`version` = IF(`version` = VALUES(`version`), `version` + 1, `version`)",
+		},
+		"postgres": {
+			`INSERT INTO "items" AS existing ("id", "name") VALUES ? ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name"`,
+			`INSERT INTO "items" AS existing ("id", "name", "version") VALUES ? ON CONFLICT ("id") DO UPDATE SET ` +
+				`"name" = excluded."name", "version" = existing."version" + 1 WHERE existing."version" = excluded."version"`,
+		},
+		"sqlite3": {
+			`INSERT INTO "items" AS existing ("id", "name") VALUES ? ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name"`,
+			`INSERT INTO "items" AS existing ("id", "name", "version") VALUES ? ON CONFLICT ("id") DO UPDATE SET ` +
+				`"name" = excluded."name", "version" = existing."version" + 1 WHERE existing."version" = excluded."version"`,
+		},
+	}
+
+	for dialect, want := range golden {
+		orm := dialectORM(t, dialect)
+
+		t.Run("when upserting into "+dialect, func(t *testing.T) {
+			columns, _, err := orm.bulkRows([]interface{}{bulkItem{ID: 1}})
+			assert2.NoError(t, err)
+
+			query, err := orm.upsertQuery("items", columns)
+			assert2.NoError(t, err)
+			assert2.Equal(t, want.plain, query)
+		})
+
+		t.Run("when upserting versioned rows into "+dialect, func(t *testing.T) {
+			columns, _, err := orm.bulkRows([]interface{}{bulkVersioned{ID: 1}})
+			assert2.NoError(t, err)
+
+			query, err := orm.upsertQuery("items", columns)
+			assert2.NoError(t, err)
+			assert2.Equal(t, want.versioned, query)
+		})
+	}
+
+	t.Run("when table is qualified, quote each part", func(t *testing.T) {
+		orm := dialectORM(t, "postgres")
+
+		columns, _, err := orm.bulkRows([]interface{}{bulkItem{ID: 1}})
+		assert2.NoError(t, err)
+
+		query, err := orm.upsertQuery("shop.items", columns)
+		assert2.NoError(t, err)
+		assert2.Contains(t, query, `INSERT INTO "shop"."items" AS existing`)
+	})
+
+	t.Run("when table is not an identifier", func(t *testing.T) {
+		orm := dialectORM(t, "postgres")
+
+		columns, _, err := orm.bulkRows([]interface{}{bulkItem{ID: 1}})
+		assert2.NoError(t, err)
+
+		_, err = orm.upsertQuery("items; DROP TABLE items", columns)
+		assert2.Error(t, err)
+	})
+}
+
+func Test_BulkChunk(t *testing.T) {
+	cases := []struct {
+		dialect   string
+		chunkSize int
+		columns   int
+		want      int
+	}{
+		{"sqlite3", 0, 3, MaxSqliteParams / 3},
+		{"sqlite3", 1000, 3, MaxSqliteParams / 3},
+		{"sqlite3", 10, 3, 10},
+		{"postgres", 0, 4, MaxPostgresParams / 4},
+		{"postgres", 100000, 4, MaxPostgresParams / 4},
+		{"mysql", 0, 2, MaxMysqlParams / 2},
+		{"mysql", 500, 2, 500},
+	}
+
+	for _, c := range cases {
+		assert2.Equal(t, c.want, dialectORM(t, c.dialect).bulkChunk(c.chunkSize, c.columns), "%s %d rows of %d columns", c.dialect, c.chunkSize, c.columns)
+	}
+}
+
+func Test_BulkDelete(t *testing.T) {
+	type (
+		line struct {
+			OrderID int `gorm:"primary_key;auto_increment:false"`
+			Number  int `gorm:"primary_key;auto_increment:false"`
+			SKU     string
+		}
+	)
+
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert2.NoError(t, err)
+	db.DB().SetMaxOpenConns(1)
+
+	orm := &Impl{Database: db, DB: db.DB()}
+	defer orm.Close()
+
+	assert2.NoError(t, orm.CreateTable(&bulkItem{}))
+	assert2.NoError(t, orm.CreateTable(&line{}))
+
+	count := func(model interface{}) int64 {
+		total, err := orm.Count(model)
+		assert2.NoError(t, err)
+		return total
+	}
+
+	t.Run("when rows span several statements", func(t *testing.T) {
+		rows := make([]interface{}, 0, 2*MaxSqliteParams+2)
+		for i := 1; i <= cap(rows); i++ {
+			rows = append(rows, bulkItem{ID: i})
+		}
+
+		_, err := orm.BulkUpsert("bulk_items", 0, rows)
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(len(rows)), count(&bulkItem{}))
+
+		assert2.NoError(t, orm.BulkDelete("bulk_items", rows[1:]))
+		assert2.Equal(t, int64(1), count(&bulkItem{}))
+	})
+
+	t.Run("when the key is composite", func(t *testing.T) {
+		rows := make([]interface{}, 0, MaxSqliteParams+1)
+		for i := 0; i < cap(rows); i++ {
+			rows = append(rows, line{OrderID: i % 2, Number: i, SKU: "sku"})
+		}
+
+		_, err := orm.BulkUpsert("lines", 0, rows)
+		assert2.NoError(t, err)
+
+		// - the lines of order 0, the numbers of order 1 are kept
+		deleted := make([]interface{}, 0, len(rows)/2)
+		for i := 0; i < len(rows); i += 2 {
+			deleted = append(deleted, line{OrderID: 0, Number: i})
+			deleted = append(deleted, line{OrderID: 0, Number: i + 1})
+		}
+
+		assert2.NoError(t, orm.BulkDelete("lines", deleted))
+		assert2.Equal(t, int64(len(rows)/2), count(&line{}))
+
+		var kept line
+		assert2.NoError(t, orm.Where("order_id = ? AND number = ?", 1, 1).First(&kept))
+	})
+
+	t.Run("when key columns are given", func(t *testing.T) {
+		assert2.NoError(t, orm.BulkDelete("lines", []interface{}{line{SKU: "sku"}}, "sku"))
+		assert2.Equal(t, int64(0), count(&line{}))
+
+		err := orm.BulkDelete("lines", []interface{}{line{SKU: "sku"}}, "missing")
+		assert2.Error(t, err)
+	})
+
+	t.Run("when in a transaction, leave the commit to it", func(t *testing.T) {
+		_, err := orm.BulkUpsert("bulk_items", 0, []interface{}{bulkItem{ID: 1}, bulkItem{ID: 2}})
+		assert2.NoError(t, err)
+
+		tx := orm.Begin()
+		assert2.NoError(t, tx.BulkDelete("bulk_items", []interface{}{bulkItem{ID: 1}, bulkItem{ID: 2}}))
+		assert2.NoError(t, tx.Rollback())
+
+		assert2.Equal(t, int64(2), count(&bulkItem{}))
+	})
+
+	t.Run("when table is not an identifier", func(t *testing.T) {
+		err := orm.BulkDelete("bulk_items WHERE 1 = 1 --", []interface{}{bulkItem{ID: 1}})
+		assert2.Error(t, err)
+		assert2.Equal(t, int64(2), count(&bulkItem{}))
+	})
+}
+
+func Test_BulkStale(t *testing.T) {
+	versioned := []bulkColumn{{name: "id", primary: true}, {name: "version", version: true}}
+	plain := []bulkColumn{{name: "id", primary: true}}
+
+	cases := []struct {
+		dialect  string
+		columns  []bulkColumn
+		rows     int
+		affected int64
+		want     bool
+	}{
+		{"postgres", plain, 3, 0, false},
+		{"postgres", versioned, 3, 3, false},
+		{"postgres", versioned, 3, 2, true},
+		{"sqlite3", versioned, 3, 2, true},
+		{"mysql", plain, 3, 0, false},
+		{"mysql", versioned, 1, 0, true},
+		{"mysql", versioned, 1, 1, false},
+		{"mysql", versioned, 1, 2, false},
+	}
+
+	for _, c := range cases {
+		got := dialectORM(t, c.dialect).bulkStale(c.columns, c.rows, c.affected)
+		assert2.Equal(t, c.want, got, "%s %d rows affecting %d", c.dialect, c.rows, c.affected)
+	}
+}
+
+func Test_BulkUpsertStale(t *testing.T) {
+	orm := newSqlite(t, &bulkVersioned{})
+
+	name := func(id int) string {
+		var got bulkVersioned
+		assert2.NoError(t, orm.Where("id = ?", id).First(&got))
+		return got.Name
+	}
+
+	_, err := orm.BulkUpsert("bulk_versioneds", 0, []interface{}{
+		bulkVersioned{ID: 1, Name: "first"},
+		bulkVersioned{ID: 2, Name: "second"},
+	})
+	assert2.NoError(t, err)
+
+	stale := []interface{}{
+		bulkVersioned{ID: 1, Name: "fresh"},
+		bulkVersioned{ID: 2, Name: "stale", Version: 5},
+	}
+
+	t.Run("when in a transaction, rollback the chunks written before", func(t *testing.T) {
+		err := WithTransaction(orm, func(tx ORM) error {
+			_, err := tx.BulkUpsert("bulk_versioneds", 1, stale)
+			return err
+		})
+		assert2.True(t, IsStaleObject(err))
+		assert2.Equal(t, "first", name(1))
+		assert2.Equal(t, "second", name(2))
+	})
+
+	t.Run("when not in a transaction, keep the chunks written before", func(t *testing.T) {
+		affected, err := orm.BulkUpsert("bulk_versioneds", 1, stale)
+		assert2.True(t, IsStaleObject(err))
+		assert2.Equal(t, int64(1), affected)
+		assert2.Equal(t, "fresh", name(1))
+		assert2.Equal(t, "second", name(2))
+	})
+}
+
+func Test_BulkUpsertStaleMysql(t *testing.T) {
+	upsert := func(chunkSize int, rows ...interface{}) (*mysqlRows, int64, error) {
+		conn := &mysqlRows{columns: 3, affected: map[int]int64{1: 2, 2: 0, 3: 1}}
+
+		db, err := gorm.Open("mysql", conn)
+		assert2.NoError(t, err)
+
+		affected, err := (&Impl{Database: db}).BulkUpsert("bulk_versioneds", chunkSize, rows)
+		return conn, affected, err
+	}
+
+	t.Run("when a chunk mix fresh and stale rows, report the stale row", func(t *testing.T) {
+		// - a single statement would count 2 + 0 + 1 like 3 inserted rows
+		conn, affected, err := upsert(0,
+			bulkVersioned{ID: 1, Name: "updated", Version: 1},
+			bulkVersioned{ID: 2, Name: "stale", Version: 1},
+			bulkVersioned{ID: 3, Name: "inserted"},
+		)
+		assert2.True(t, IsStaleObject(err))
+		assert2.Equal(t, int64(2), affected)
+		assert2.Equal(t, 2, conn.statements)
+	})
+
+	t.Run("when no row is stale, upsert every row", func(t *testing.T) {
+		conn, affected, err := upsert(10,
+			bulkVersioned{ID: 1, Name: "updated", Version: 1},
+			bulkVersioned{ID: 3, Name: "inserted"},
+		)
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(3), affected)
+		assert2.Equal(t, 2, conn.statements)
+	})
+
+	t.Run("when rows are not versioned, upsert them in chunks", func(t *testing.T) {
+		conn := &mysqlRows{columns: 2}
+
+		db, err := gorm.Open("mysql", conn)
+		assert2.NoError(t, err)
+
+		_, err = (&Impl{Database: db}).BulkUpsert("bulk_items", 0, []interface{}{bulkItem{ID: 1}, bulkItem{ID: 2}})
+		assert2.NoError(t, err)
+		assert2.Equal(t, 1, conn.statements)
+	})
+}
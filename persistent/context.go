@@ -0,0 +1,142 @@
+package persistent
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type (
+	// contextDB run every statement of the connection pool with ctx and
+	// between the hooks, gorm only call the context free methods of its
+	// connection.
+	contextDB struct {
+		ctx context.Context
+		db  *sql.DB
+		hooks
+	}
+
+	// contextTx run every statement of the transaction with ctx and between
+	// the hooks.
+	contextTx struct {
+		ctx context.Context
+		tx  *sql.Tx
+		hooks
+	}
+
+	// hooks observe the statements of a connection of dialect.
+	hooks struct {
+		dialect string
+		list    []QueryHook
+	}
+
+	// execer and querier are the *sql.DB and *sql.Tx methods the hooks wrap.
+	execer interface {
+		ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	}
+
+	querier interface {
+		QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+		QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+	}
+)
+
+func (h hooks) exec(ctx context.Context, conn execer, query string, args []interface{}) (res sql.Result, err error) {
+	hooked(ctx, h.list, h.dialect, query, args, func(ctx context.Context) (int64, error) {
+		if res, err = conn.ExecContext(ctx, query, args...); err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	})
+
+	return res, err
+}
+
+func (h hooks) query(ctx context.Context, conn querier, query string, args []interface{}) (rows *sql.Rows, err error) {
+	hooked(ctx, h.list, h.dialect, query, args, func(ctx context.Context) (int64, error) {
+		rows, err = conn.QueryContext(ctx, query, args...)
+		return -1, err
+	})
+
+	return rows, err
+}
+
+func (h hooks) queryRow(ctx context.Context, conn querier, query string, args []interface{}) (row *sql.Row) {
+	hooked(ctx, h.list, h.dialect, query, args, func(ctx context.Context) (int64, error) {
+		row = conn.QueryRowContext(ctx, query, args...)
+		return -1, row.Err()
+	})
+
+	return row
+}
+
+func (c *contextDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.exec(c.ctx, c.db, query, args)
+}
+
+func (c *contextDB) Prepare(query string) (*sql.Stmt, error) {
+	return c.db.PrepareContext(c.ctx, query)
+}
+
+func (c *contextDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.query(c.ctx, c.db, query, args)
+}
+
+func (c *contextDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.queryRow(c.ctx, c.db, query, args)
+}
+
+func (c *contextDB) Begin() (*sql.Tx, error) {
+	return c.db.BeginTx(c.ctx, nil)
+}
+
+func (c *contextDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.db.BeginTx(ctx, opts)
+}
+
+func (c *contextDB) Close() error {
+	return c.db.Close()
+}
+
+func (c *contextTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.exec(c.ctx, c.tx, query, args)
+}
+
+func (c *contextTx) Prepare(query string) (*sql.Stmt, error) {
+	return c.tx.PrepareContext(c.ctx, query)
+}
+
+func (c *contextTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.query(c.ctx, c.tx, query, args)
+}
+
+func (c *contextTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.queryRow(c.ctx, c.tx, query, args)
+}
+
+func (c *contextTx) Commit() error {
+	return c.tx.Commit()
+}
+
+func (c *contextTx) Rollback() error {
+	return c.tx.Rollback()
+}
+
+// bind return the connection of common running its statements with ctx and
+// between the hooks h.
+func bind(ctx context.Context, common gorm.SQLCommon, h hooks) (gorm.SQLCommon, error) {
+	switch conn := common.(type) {
+	case *sql.DB:
+		return &contextDB{ctx: ctx, db: conn, hooks: h}, nil
+	case *sql.Tx:
+		return &contextTx{ctx: ctx, tx: conn, hooks: h}, nil
+	case *contextDB:
+		return &contextDB{ctx: ctx, db: conn.db, hooks: h}, nil
+	case *contextTx:
+		return &contextTx{ctx: ctx, tx: conn.tx, hooks: h}, nil
+	}
+
+	return nil, errors.Errorf("context is not supported by connection %T", common)
+}
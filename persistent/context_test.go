@@ -0,0 +1,70 @@
+package persistent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+type note struct {
+	ID     int `gorm:"primary_key"`
+	Text   string
+	Source string
+}
+
+func Test_WithContext(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert2.NoError(t, err)
+	db.DB().SetMaxOpenConns(1)
+
+	db.SingularTable(true)
+	db.BlockGlobalUpdate(true)
+	// - a bound connection use the default callbacks, not those of db
+	gorm.DefaultCallback.Create().Before("gorm:create").Register("test:source", func(scope *gorm.Scope) {
+		_ = scope.SetColumn("Source", "callback")
+	})
+	defer gorm.DefaultCallback.Create().Remove("test:source")
+
+	orm := &Impl{Database: db, DB: db.DB()}
+	defer orm.Close()
+
+	assert2.NoError(t, orm.WithContext(context.Background()).CreateTable(&note{}))
+
+	t.Run("when bound, keep the settings of the connection and the default callbacks", func(t *testing.T) {
+		bound := orm.WithContext(context.Background())
+
+		assert2.True(t, bound.HasTable("note"))
+		assert2.NoError(t, bound.Create(&note{ID: 1, Text: "first"}))
+		assert2.NoError(t, bound.Create(&note{ID: 2, Text: "second"}))
+
+		var got note
+		assert2.NoError(t, orm.Where("id = ?", 1).First(&got))
+		assert2.Equal(t, "callback", got.Source)
+
+		err := bound.(*Impl).Database.Model(&note{}).Update("text", "all").Error
+		assert2.Error(t, err)
+		assert2.True(t, bound.(*Impl).Database.HasBlockGlobalUpdate())
+	})
+
+	t.Run("when bound, keep the chained conditions", func(t *testing.T) {
+		var got note
+		assert2.NoError(t, orm.Where("id = ?", 2).WithContext(context.Background()).First(&got))
+		assert2.Equal(t, "second", got.Text)
+	})
+
+	t.Run("when ctx is cancelled, interrupt the running statement", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		var count struct{ N int64 }
+		start := time.Now()
+
+		err := orm.WithContext(ctx).RawSqlWithObject(
+			"WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) AS n FROM c", &count)
+		assert2.Error(t, err)
+		assert2.True(t, time.Since(start) < 5*time.Second)
+	})
+}
@@ -0,0 +1,224 @@
+package persistent
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+)
+
+const (
+	// Redacted replace the args of a QueryEvent which may be sensitive.
+	Redacted = "<redacted>"
+
+	hookStart   = "persistent:hook_start"
+	hookSetting = "persistent:hooks"
+)
+
+var (
+	// fingerprint rules, applied in order, the literals and placeholders are
+	// replaced first so lists of them can be collapsed.
+	fingerprintComment     = regexp.MustCompile(`(?s)/\*.*?\*/|--[^\n]*`)
+	fingerprintString      = regexp.MustCompile(`'(?:[^']|'')*'`)
+	fingerprintPlaceholder = regexp.MustCompile(`\$\d+|\b\d+(?:\.\d+)?\b`)
+	fingerprintList        = regexp.MustCompile(`\?(?:\s*,\s*\?)+`)
+	fingerprintRows        = regexp.MustCompile(`\(\?\)(?:\s*,\s*\(\?\))+`)
+	fingerprintSpace       = regexp.MustCompile(`\s+`)
+	fingerprintOperation   = regexp.MustCompile(`[a-z]+`)
+)
+
+type (
+	// QueryEvent is a statement sent to the database, through gorm, Exec,
+	// RawSql, BulkUpsert or any other call.
+	QueryEvent struct {
+		Dialect string
+		SQL     string
+		// Fingerprint is SQL without its values, statements differing only
+		// by their values, or the length of their lists, share it.
+		Fingerprint string
+		// Operation is the first keyword of SQL, like SELECT or INSERT.
+		Operation string
+		// Args are the values of SQL, strings, bytes and structs are
+		// Redacted.
+		Args     []interface{}
+		Start    time.Time
+		Duration time.Duration
+		// RowsAffected is -1 for the queries, their rows are read later.
+		RowsAffected int64
+		Err          error
+	}
+
+	// QueryHook observe the statements of an ORM, see Impl.Use. BeforeQuery
+	// return the ctx of the statement, to carry a span for example, it is
+	// given back to AfterQuery.
+	QueryHook interface {
+		BeforeQuery(ctx context.Context, event *QueryEvent) context.Context
+		AfterQuery(ctx context.Context, event *QueryEvent)
+	}
+
+	slowQueryLog struct {
+		threshold time.Duration
+		logger    logs.Logger
+	}
+
+	// hookState is the ctx and hooks of a bound connection, kept in its
+	// settings for hookCallbacks.
+	hookState struct {
+		ctx   context.Context
+		hooks hooks
+	}
+)
+
+func init() {
+	hookCallbacks(gorm.DefaultCallback)
+}
+
+// NewSlowQueryLog return a hook logging the statements running threshold or
+// longer, and their error if any.
+func NewSlowQueryLog(threshold time.Duration, logger logs.Logger) QueryHook {
+	return &slowQueryLog{threshold: threshold, logger: logger}
+}
+
+func (s *slowQueryLog) BeforeQuery(ctx context.Context, _ *QueryEvent) context.Context {
+	return ctx
+}
+
+func (s *slowQueryLog) AfterQuery(_ context.Context, event *QueryEvent) {
+	if event.Duration < s.threshold {
+		return
+	}
+
+	if event.Err != nil {
+		s.logger.Warningf("slow query took %s and failed with %s: %s %v", event.Duration, event.Err, event.SQL, event.Args)
+		return
+	}
+
+	s.logger.Warningf("slow query took %s: %s %v", event.Duration, event.SQL, event.Args)
+}
+
+// Fingerprint return sql without its literals, placeholders and comments,
+// lists of values collapsed to a single one and spaces normalized.
+//
+//	SELECT * FROM users WHERE id IN ($1, $2) AND name = 'x'
+//	select * from users where id in (?) and name = ?
+func Fingerprint(sql string) string {
+	s := fingerprintComment.ReplaceAllString(sql, " ")
+	s = fingerprintString.ReplaceAllString(s, "?")
+	s = fingerprintPlaceholder.ReplaceAllString(s, "?")
+	s = fingerprintList.ReplaceAllString(s, "?")
+	s = fingerprintRows.ReplaceAllString(s, "(?)")
+	s = fingerprintSpace.ReplaceAllString(s, " ")
+
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// - private
+
+// redact keep the args which cannot hold personal data or secrets, nulls,
+// booleans, numbers and times.
+func redact(args []interface{}) []interface{} {
+	redacted := make([]interface{}, len(args))
+
+	for i, arg := range args {
+		switch arg.(type) {
+		case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+			float32, float64, time.Time:
+			redacted[i] = arg
+		default:
+			redacted[i] = Redacted
+		}
+	}
+
+	return redacted
+}
+
+// hooked run a statement between the hooks, run return the rows affected.
+func hooked(ctx context.Context, hooks []QueryHook, dialect, sql string, args []interface{}, run func(context.Context) (int64, error)) {
+	if len(hooks) == 0 {
+		run(ctx)
+		return
+	}
+
+	report(ctx, hooks, newQueryEvent(dialect, sql, args, time.Now()), run)
+}
+
+// hookCallbacks report the statements of the gorm create, update and delete
+// run in the transaction gorm begin itself, they bypass the connection. They
+// are registered once on the default callbacks, every connection opened
+// afterwards inherit them, and do nothing unless the connection was bound
+// with hooks.
+func hookCallbacks(callbacks *gorm.Callback) {
+	before := func(scope *gorm.Scope) {
+		if _, ok := scope.Get(hookSetting); ok {
+			scope.InstanceSet(hookStart, time.Now())
+		}
+	}
+
+	after := func(scope *gorm.Scope) {
+		if _, bypassed := scope.SQLDB().(*sql.Tx); !bypassed || scope.SQL == "" {
+			return
+		}
+
+		value, ok := scope.Get(hookSetting)
+		if !ok {
+			return
+		}
+
+		state := value.(*hookState)
+		if len(state.hooks.list) == 0 {
+			return
+		}
+
+		start, ok := scope.InstanceGet(hookStart)
+		if !ok {
+			return
+		}
+
+		event := newQueryEvent(state.hooks.dialect, scope.SQL, scope.SQLVars, start.(time.Time))
+		report(state.ctx, state.hooks.list, event, func(context.Context) (int64, error) {
+			return scope.DB().RowsAffected, scope.DB().Error
+		})
+	}
+
+	callbacks.Create().Before("gorm:create").Register("persistent:before_create", before)
+	callbacks.Create().After("gorm:create").Register("persistent:after_create", after)
+	callbacks.Update().Before("gorm:update").Register("persistent:before_update", before)
+	callbacks.Update().After("gorm:update").Register("persistent:after_update", after)
+	callbacks.Delete().Before("gorm:delete").Register("persistent:before_delete", before)
+	callbacks.Delete().After("gorm:delete").Register("persistent:after_delete", after)
+}
+
+func newQueryEvent(dialect, sql string, args []interface{}, start time.Time) *QueryEvent {
+	event := &QueryEvent{
+		Dialect:     dialect,
+		SQL:         sql,
+		Fingerprint: Fingerprint(sql),
+		Args:        redact(args),
+		Start:       start,
+	}
+	event.Operation = strings.ToUpper(fingerprintOperation.FindString(event.Fingerprint))
+
+	return event
+}
+
+// report call the hooks before and after run, the hooks after are called in
+// reverse order.
+func report(ctx context.Context, hooks []QueryHook, event *QueryEvent, run func(context.Context) (int64, error)) {
+	contexts := make([]context.Context, len(hooks))
+	for i, hook := range hooks {
+		ctx = hook.BeforeQuery(ctx, event)
+		contexts[i] = ctx
+	}
+
+	event.RowsAffected, event.Err = run(ctx)
+	event.Duration = time.Since(event.Start)
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].AfterQuery(contexts[i], event)
+	}
+}
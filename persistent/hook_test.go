@@ -0,0 +1,116 @@
+package persistent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	assert2 "github.com/stretchr/testify/assert"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+type (
+	recorder struct {
+		mu     sync.Mutex
+		events []QueryEvent
+	}
+
+	account struct {
+		ID    int `gorm:"primary_key"`
+		Email string
+	}
+)
+
+func (r *recorder) BeforeQuery(ctx context.Context, _ *QueryEvent) context.Context {
+	return ctx
+}
+
+func (r *recorder) AfterQuery(_ context.Context, event *QueryEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, *event)
+}
+
+func (r *recorder) operations() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	operations := make([]string, 0, len(r.events))
+	for _, event := range r.events {
+		operations = append(operations, event.Operation)
+	}
+	r.events = nil
+
+	return operations
+}
+
+func Test_Fingerprint(t *testing.T) {
+	assert2.Equal(t,
+		"select * from users where id in (?) and name = ?",
+		Fingerprint("SELECT *  FROM users\n WHERE id IN ($1, $2) AND name = 'o''neil' -- lookup"))
+	assert2.Equal(t,
+		"insert into t1 (a, b) values (?)",
+		Fingerprint("INSERT INTO t1 (a, b) VALUES (?,?),(?,?), (1, 2.5)"))
+}
+
+func Test_Use(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert2.NoError(t, err)
+	db.DB().SetMaxOpenConns(1)
+
+	rec := &recorder{}
+	orm := (&Impl{Database: db, DB: db.DB()}).Use(rec)
+	defer orm.Close()
+
+	assert2.NoError(t, orm.CreateTable(&account{}))
+	assert2.Equal(t, []string{"CREATE"}, rec.operations())
+
+	t.Run("when calling gorm, exec, raw sql and bulk upsert, report every statement", func(t *testing.T) {
+		assert2.NoError(t, orm.Create(&account{ID: 1, Email: "a@example.com"}))
+		assert2.Contains(t, rec.operations(), "INSERT")
+
+		assert2.NoError(t, orm.Exec("UPDATE accounts SET email = ? WHERE id = ?", "b@example.com", 1))
+		rec.mu.Lock()
+		event := rec.events[0]
+		rec.mu.Unlock()
+		assert2.Equal(t, "update accounts set email = ? where id = ?", event.Fingerprint)
+		assert2.Equal(t, []interface{}{Redacted, 1}, event.Args)
+		assert2.Equal(t, int64(1), event.RowsAffected)
+		assert2.Equal(t, []string{"UPDATE"}, rec.operations())
+
+		rows, err := orm.RawSql("SELECT id FROM accounts")
+		assert2.NoError(t, err)
+		assert2.NoError(t, rows.Close())
+		assert2.Equal(t, []string{"SELECT"}, rec.operations())
+
+		affected, err := orm.BulkUpsert("accounts", 0, []interface{}{account{ID: 2}, account{ID: 3}})
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(2), affected)
+		assert2.Equal(t, []string{"INSERT"}, rec.operations())
+	})
+
+	t.Run("when in a transaction or bound to a context, keep the hooks", func(t *testing.T) {
+		err := WithTransaction(orm.WithContext(context.Background()), func(tx ORM) error {
+			if err := tx.Create(&account{ID: 4}); err != nil {
+				return err
+			}
+			return tx.Where("id = ?", 1).First(&account{})
+		})
+		assert2.NoError(t, err)
+		assert2.Equal(t, []string{"INSERT", "SELECT"}, rec.operations())
+	})
+
+	t.Run("when failing, report the error", func(t *testing.T) {
+		assert2.Error(t, orm.Exec("UPDATE missing SET a = 1"))
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		assert2.Len(t, rec.events, 1)
+		assert2.Error(t, rec.events[0].Err)
+		assert2.True(t, rec.events[0].Duration < time.Minute)
+	})
+}
@@ -0,0 +1,58 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	assert2 "github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+)
+
+func run(hook persistent.QueryHook, event *persistent.QueryEvent) {
+	ctx := hook.BeforeQuery(context.Background(), event)
+	hook.AfterQuery(ctx, event)
+}
+
+func Test_Prometheus(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	p, err := NewPrometheus(registry, &PrometheusOption{Namespace: "app"})
+	assert2.NoError(t, err)
+
+	run(p, &persistent.QueryEvent{Dialect: "postgres", Operation: "UPDATE", Duration: time.Millisecond, RowsAffected: 3})
+	run(p, &persistent.QueryEvent{Dialect: "postgres", Operation: "SELECT", Duration: time.Millisecond, RowsAffected: -1, Err: errors.New("boom")})
+
+	assert2.Equal(t, 3, testutil.CollectAndCount(p.duration)+testutil.CollectAndCount(p.rows))
+
+	_, err = NewPrometheus(registry, &PrometheusOption{Namespace: "app"})
+	assert2.Error(t, err)
+}
+
+func Test_Tracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := NewTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	run(tracer, &persistent.QueryEvent{
+		Dialect:      "postgres",
+		Fingerprint:  "delete from orders where id = ?",
+		Operation:    "DELETE",
+		Start:        time.Now(),
+		RowsAffected: 1,
+		Err:          errors.New("boom"),
+	})
+
+	spans := recorder.Ended()
+	assert2.Len(t, spans, 1)
+	assert2.Equal(t, "DELETE", spans[0].Name())
+	assert2.Equal(t, codes.Error, spans[0].Status().Code)
+	assert2.Contains(t, spans[0].Attributes(), semconv.DBStatementKey.String("delete from orders where id = ?"))
+}
@@ -0,0 +1,70 @@
+package instrument
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+)
+
+// TracerName is the instrumentation name of the spans.
+const TracerName = "github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+
+var systems = map[string]attribute.KeyValue{
+	"mysql":    semconv.DBSystemMySQL,
+	"postgres": semconv.DBSystemPostgreSQL,
+	"sqlite3":  semconv.DBSystemSqlite,
+}
+
+// Tracer is a hook exporting a client span per statement, child of the span
+// of the ctx the ORM is bound to. The statement of the span is its
+// fingerprint, so no value is exported.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(TracerName)}
+}
+
+func (t *Tracer) BeforeQuery(ctx context.Context, event *persistent.QueryEvent) context.Context {
+	name := event.Operation
+	if name == "" {
+		name = "query"
+	}
+
+	attributes := []attribute.KeyValue{
+		semconv.DBStatementKey.String(event.Fingerprint),
+		semconv.DBOperationKey.String(event.Operation),
+	}
+	if system, ok := systems[event.Dialect]; ok {
+		attributes = append(attributes, system)
+	}
+
+	ctx, _ = t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(event.Start),
+		trace.WithAttributes(attributes...),
+	)
+
+	return ctx
+}
+
+func (t *Tracer) AfterQuery(ctx context.Context, event *persistent.QueryEvent) {
+	span := trace.SpanFromContext(ctx)
+
+	if event.RowsAffected >= 0 {
+		span.SetAttributes(attribute.Int64("db.rows_affected", event.RowsAffected))
+	}
+
+	if event.Err != nil {
+		span.RecordError(event.Err)
+		span.SetStatus(codes.Error, event.Err.Error())
+	}
+
+	span.End(trace.WithTimestamp(event.Start.Add(event.Duration)))
+}
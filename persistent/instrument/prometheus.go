@@ -0,0 +1,82 @@
+package instrument
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+)
+
+type (
+	// PrometheusOption name the metrics and set the buckets of their
+	// histograms, nil buckets use the prometheus defaults.
+	PrometheusOption struct {
+		Namespace       string
+		Subsystem       string
+		DurationBuckets []float64
+		RowsBuckets     []float64
+	}
+
+	// Prometheus is a hook observing the duration, in seconds, and the rows
+	// affected of the statements by dialect, operation and status, ok or
+	// error. Queries are not observed in the rows histogram, their rows
+	// are unknown.
+	Prometheus struct {
+		duration *prometheus.HistogramVec
+		rows     *prometheus.HistogramVec
+	}
+)
+
+// NewPrometheus create the histograms of the hook and register them with
+// registerer.
+func NewPrometheus(registerer prometheus.Registerer, option *PrometheusOption) (*Prometheus, error) {
+	rowsBuckets := option.RowsBuckets
+	if rowsBuckets == nil {
+		rowsBuckets = prometheus.ExponentialBuckets(1, 10, 6)
+	}
+
+	p := &Prometheus{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: option.Namespace,
+			Subsystem: option.Subsystem,
+			Name:      "query_duration_seconds",
+			Help:      "Duration of the SQL statements.",
+			Buckets:   option.DurationBuckets,
+		}, []string{"dialect", "operation", "status"}),
+		rows: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: option.Namespace,
+			Subsystem: option.Subsystem,
+			Name:      "query_rows_affected",
+			Help:      "Rows affected by the SQL statements.",
+			Buckets:   rowsBuckets,
+		}, []string{"dialect", "operation", "status"}),
+	}
+
+	for _, collector := range []prometheus.Collector{p.duration, p.rows} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, errors.Wrap(err, "failed to register query metrics")
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Prometheus) BeforeQuery(ctx context.Context, _ *persistent.QueryEvent) context.Context {
+	return ctx
+}
+
+func (p *Prometheus) AfterQuery(_ context.Context, event *persistent.QueryEvent) {
+	status := "ok"
+	if event.Err != nil {
+		status = "error"
+	}
+
+	labels := []string{event.Dialect, event.Operation, status}
+
+	p.duration.WithLabelValues(labels...).Observe(event.Duration.Seconds())
+	if event.RowsAffected >= 0 {
+		p.rows.WithLabelValues(labels...).Observe(float64(event.RowsAffected))
+	}
+}
@@ -1,6 +1,7 @@
 package masterreplica
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/pkg/errors"
@@ -13,10 +14,12 @@ type DB struct {
 	// Master is master physical database
 	Master persistent.ORM
 
-	// Replica can be a slave physical database, but for more than 1 slave Replica
-	// you can put loadbalancer on top of your Replica sets that handle the load
-	// distribution, which can be round robin or others
+	// Replica can be a slave physical database, or for more than 1 slave a
+	// Pool balancing the reads over the healthy replicas, see NewWithReplicas
 	Replica persistent.ORM
+
+	ctx      context.Context
+	sessions *sessions
 }
 
 // New create a new DB.
@@ -24,6 +27,15 @@ func New(master, replica persistent.ORM) persistent.ORM {
 	return &DB{Master: master, Replica: replica}
 }
 
+// NewWithOption create a new DB routing with option, nil route like New.
+func NewWithOption(master, replica persistent.ORM, option *Option) persistent.ORM {
+	if option == nil {
+		return New(master, replica)
+	}
+
+	return &DB{Master: master, Replica: replica, sessions: newSessions(option.StickyWindow)}
+}
+
 // Ping send a ping to both master and replica to make sure all database
 // connections are alive.
 func (db *DB) Ping() error {
@@ -43,10 +55,10 @@ func (db *DB) Close() error {
 	return errors.Wrap(err, "replica")
 }
 
-// Set set setting by name, which could be used in callbacks, will clone
-// and return a new master db.
+// Set set setting by name, which could be used in callbacks. Clone both
+// master and replica db.
 func (db *DB) Set(name string, value interface{}) persistent.ORM {
-	return db.Master.Set(name, value)
+	return db.clone(db.Master.Set(name, value), db.Replica.Set(name, value))
 }
 
 // Error check error in both master and replica db.
@@ -59,101 +71,206 @@ func (db *DB) Error() error {
 	return errors.Wrap(err, "replica")
 }
 
+// WithContext bind both master and replica db to ctx, ctx also route the
+// reads, see ForceMaster and WithSession.
+func (db *DB) WithContext(ctx context.Context) persistent.ORM {
+	copied := db.clone(db.Master.WithContext(ctx), db.Replica.WithContext(ctx))
+	copied.ctx = ctx
+
+	return copied
+}
+
+// Context return the ctx bound by WithContext.
+func (db *DB) Context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
+	}
+
+	return db.ctx
+}
+
 // Where return a new relation, filter records with given conditions, accepts
 // `map`, `struct` or `string` as conditions. Clone both master and replica db.
 func (db *DB) Where(query interface{}, args ...interface{}) persistent.ORM {
-	return &DB{
-		Master:  db.Master.Where(query, args...),
-		Replica: db.Replica.Where(query, args...),
-	}
+	return db.clone(db.Master.Where(query, args...), db.Replica.Where(query, args...))
 }
 
 // First find first record that match given conditions, order by primary key.
 // Executed using replica db.
 func (db *DB) First(object interface{}) error {
-	return errors.Wrap(db.Replica.First(object), "replica")
+	orm, side := db.reader(db.Context())
+
+	return errors.Wrap(orm.First(object), side)
+}
+
+// FirstWithContext is First bound to ctx. Executed using replica db.
+func (db *DB) FirstWithContext(ctx context.Context, object interface{}) error {
+	orm, side := db.reader(ctx)
+
+	return errors.Wrap(orm.FirstWithContext(ctx, object), side)
 }
 
 // All find all records that match given conditions, order by primary key.
 // Executed using replica db.
 func (db *DB) All(object interface{}) error {
-	return errors.Wrap(db.Replica.All(object), "replica")
+	orm, side := db.reader(db.Context())
+
+	return errors.Wrap(orm.All(object), side)
+}
+
+// AllWithContext is All bound to ctx. Executed using replica db.
+func (db *DB) AllWithContext(ctx context.Context, object interface{}) error {
+	orm, side := db.reader(ctx)
+
+	return errors.Wrap(orm.AllWithContext(ctx, object), side)
+}
+
+// Count count records that match given conditions. Executed using replica
+// db.
+func (db *DB) Count(model interface{}) (int64, error) {
+	orm, side := db.reader(db.Context())
+	total, err := orm.Count(model)
+
+	return total, errors.Wrap(err, side)
 }
 
 // Order specify order when retrieve records from database.
 //     db.Order("name DESC")
-// Return cloned persistent.ORM from replica db.
+// Clone both master and replica db.
 func (db *DB) Order(args interface{}) persistent.ORM {
-	return db.Replica.Order(args)
+	return db.clone(db.Master.Order(args), db.Replica.Order(args))
 }
 
 // Limit specify the number of records to be retrieved.
-// Return cloned persistent.ORM from replica db.
+// Clone both master and replica db.
 func (db *DB) Limit(args interface{}) persistent.ORM {
-	return db.Replica.Limit(args)
+	return db.clone(db.Master.Limit(args), db.Replica.Limit(args))
 }
 
 // Offset specify the number of records to skip before starting to return
-// the records. Return cloned persistent.ORM from replica db.
+// the records. Clone both master and replica db.
 func (db *DB) Offset(args interface{}) persistent.ORM {
-	return db.Replica.Offset(args)
+	return db.clone(db.Master.Offset(args), db.Replica.Offset(args))
 }
 
 // Create insert the value into database.
 func (db *DB) Create(object interface{}) error {
-	return errors.Wrap(db.Master.Create(object), "master")
+	return db.written(db.Context(), db.Master.Create(object))
 }
 
 // Update update the object in database.
 func (db *DB) Update(object interface{}) error {
-	return errors.Wrap(db.Master.Update(object), "master")
+	return db.written(db.Context(), db.Master.Update(object))
 }
 
 // Delete delete object in database.
 func (db *DB) Delete(object interface{}) error {
-	return errors.Wrap(db.Master.Delete(object), "master")
+	return db.written(db.Context(), db.Master.Delete(object))
 }
 
 // BulkDelete bulk delete data from given table.
-func (db *DB) BulkDelete(tableName string, data []interface{}) error {
-	return errors.Wrap(db.Master.BulkDelete(tableName, data), "master")
+func (db *DB) BulkDelete(tableName string, data []interface{}, keys ...string) error {
+	return db.written(db.Context(), db.Master.BulkDelete(tableName, data, keys...))
 }
 
 // SoftDelete soft deleting data.
 func (db *DB) SoftDelete(object interface{}) error {
-	return errors.Wrap(db.Master.SoftDelete(object), "master")
+	return db.written(db.Context(), db.Master.SoftDelete(object))
 }
 
 // Exec execute given query.
 func (db *DB) Exec(sql string, args ...interface{}) error {
-	return errors.Wrap(db.Master.Exec(sql, args...), "master")
+	return db.written(db.Context(), db.Master.Exec(sql, args...))
 }
 
-// RawSqlWithObject execute given raw query.
+// ExecWithContext execute given query with ctx.
+func (db *DB) ExecWithContext(ctx context.Context, sql string, args ...interface{}) error {
+	return db.written(ctx, db.Master.ExecWithContext(ctx, sql, args...))
+}
+
+// RawSqlWithObject execute given raw query. Executed using replica db when
+// the query only read, see IsRead, else using master db.
 func (db *DB) RawSqlWithObject(sql string, object interface{}, args ...interface{}) error {
-	return errors.Wrap(db.Master.RawSqlWithObject(sql, object, args...), "master")
+	if !IsRead(sql) {
+		return db.written(db.Context(), db.Master.RawSqlWithObject(sql, object, args...))
+	}
+
+	orm, side := db.reader(db.Context())
+
+	return errors.Wrap(orm.RawSqlWithObject(sql, object, args...), side)
 }
 
-// RawSql execute given raw query.
+// RawSql execute given raw query. Executed using replica db when the query
+// only read, see IsRead, else using master db.
 func (db *DB) RawSql(sql string, args ...interface{}) (*sql.Rows, error) {
-	rows, err := db.Master.RawSql(sql, args...)
+	if !IsRead(sql) {
+		rows, err := db.Master.RawSql(sql, args...)
+		return rows, db.written(db.Context(), err)
+	}
+
+	orm, side := db.reader(db.Context())
+	rows, err := orm.RawSql(sql, args...)
+
+	return rows, errors.Wrap(err, side)
+}
+
+// RawSqlWithContext execute given raw query with ctx, routed like RawSql.
+func (db *DB) RawSqlWithContext(ctx context.Context, sql string, args ...interface{}) (*sql.Rows, error) {
+	if !IsRead(sql) {
+		rows, err := db.Master.RawSqlWithContext(ctx, sql, args...)
+		return rows, db.written(ctx, err)
+	}
+
+	orm, side := db.reader(ctx)
+	rows, err := orm.RawSqlWithContext(ctx, sql, args...)
 
-	return rows, errors.Wrap(err, "master")
+	return rows, errors.Wrap(err, side)
 }
 
 // BulkUpsert bulk upsert data per chunkSize.
-func (db *DB) BulkUpsert(tableName string, chunkSize int, data []interface{}) error {
-	return errors.Wrap(db.Master.BulkUpsert(tableName, chunkSize, data), "master")
+func (db *DB) BulkUpsert(tableName string, chunkSize int, data []interface{}) (int64, error) {
+	affected, err := db.Master.BulkUpsert(tableName, chunkSize, data)
+
+	return affected, db.written(db.Context(), err)
 }
 
 // Search find data with spesific field to select and criteria.
 func (db *DB) Search(tableName string, selectField []string, criteria []persistent.Criteria, results interface{}) error {
-	return errors.Wrap(db.Replica.Search(tableName, selectField, criteria, results), "replica")
+	orm, side := db.reader(db.Context())
+
+	return errors.Wrap(orm.Search(tableName, selectField, criteria, results), side)
+}
+
+// SearchWithContext is Search bound to ctx. Executed using replica db.
+func (db *DB) SearchWithContext(ctx context.Context, tableName string, selectField []string, criteria []persistent.Criteria, results interface{}) error {
+	orm, side := db.reader(ctx)
+
+	return errors.Wrap(orm.SearchWithContext(ctx, tableName, selectField, criteria, results), side)
+}
+
+// SearchQuery find data matching query and count them. Executed using
+// replica db.
+func (db *DB) SearchQuery(query *persistent.Query, results interface{}) (int64, error) {
+	orm, side := db.reader(db.Context())
+	total, err := orm.SearchQuery(query, results)
+
+	return total, errors.Wrap(err, side)
+}
+
+// SearchQueryWithContext is SearchQuery bound to ctx. Executed using replica
+// db.
+func (db *DB) SearchQueryWithContext(ctx context.Context, query *persistent.Query, results interface{}) (int64, error) {
+	orm, side := db.reader(ctx)
+	total, err := orm.SearchQueryWithContext(ctx, query, results)
+
+	return total, errors.Wrap(err, side)
 }
 
 // HasTable return true if given table's name is exist in db.
 func (db *DB) HasTable(tableName string) bool {
-	return db.Replica.HasTable(tableName)
+	orm, _ := db.reader(db.Context())
+
+	return orm.HasTable(tableName)
 }
 
 // CreateTable create a new table.
@@ -179,20 +296,25 @@ func (db *DB) DropTableWithName(tableName string, data interface{}) error {
 // Table specify the table you would like to run db operations. Return both
 // cloned master and replica db.
 func (db *DB) Table(tableName string) persistent.ORM {
-	return &DB{
-		Master:  db.Master.Table(tableName),
-		Replica: db.Replica.Table(tableName),
-	}
+	return db.clone(db.Master.Table(tableName), db.Replica.Table(tableName))
 }
 
-// Begin begins a transaction in master db.
+// Begin begins a transaction in master db, both reads and writes of the
+// transaction are executed using master db.
 func (db *DB) Begin() persistent.ORM {
-	return db.Master.Begin()
+	return db.BeginTx(nil)
+}
+
+// BeginTx begins a transaction with opts in master db.
+func (db *DB) BeginTx(opts *sql.TxOptions) persistent.ORM {
+	tx := db.Master.BeginTx(opts)
+
+	return db.clone(tx, tx)
 }
 
 // Commit commit a transaction in master db.
 func (db *DB) Commit() error {
-	return errors.Wrap(db.Master.Commit(), "master")
+	return db.written(db.Context(), db.Master.Commit())
 }
 
 // Rollback rollback a transaction in master db.
@@ -200,7 +322,36 @@ func (db *DB) Rollback() error {
 	return errors.Wrap(db.Master.Rollback(), "master")
 }
 
+// InTransaction return true if master db is a transaction.
+func (db *DB) InTransaction() bool {
+	return db.Master.InTransaction()
+}
+
 // UnderlyingDB return master db connection.
 func (db *DB) UnderlyingDB() *sql.DB {
 	return db.Master.UnderlyingDB()
 }
+
+// - private
+
+func (db *DB) clone(master, replica persistent.ORM) *DB {
+	return &DB{Master: master, Replica: replica, ctx: db.ctx, sessions: db.sessions}
+}
+
+// reader return the side reads with ctx are executed on, master db within a
+// transaction, when forced or when the session wrote recently.
+func (db *DB) reader(ctx context.Context) (persistent.ORM, string) {
+	if db.Master.InTransaction() || forced(ctx) || db.sessions.sticky(ctx) {
+		return db.Master, "master"
+	}
+
+	return db.Replica, "replica"
+}
+
+// written start the sticky window of the session of ctx after a write on
+// master db, even a failed one since it may have been applied.
+func (db *DB) written(ctx context.Context, err error) error {
+	db.sessions.wrote(ctx)
+
+	return errors.Wrap(err, "master")
+}
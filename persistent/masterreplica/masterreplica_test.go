@@ -0,0 +1,113 @@
+package masterreplica
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+)
+
+func Test_Routing(t *testing.T) {
+	newDB := func(window time.Duration) persistent.ORM {
+		return NewWithOption(newNode(t, "master"), newNode(t, "replica"), &Option{StickyWindow: window})
+	}
+
+	t.Run("when chaining order, limit and offset, keep both sides", func(t *testing.T) {
+		db := newDB(0)
+		defer db.Close()
+
+		chained := db.Order("name").Limit(1).Offset(0)
+		assert2.IsType(t, &DB{}, chained)
+		assert2.Equal(t, "replica", read(t, chained))
+
+		assert2.NoError(t, chained.Create(&node{Name: "written"}))
+		total, err := db.(*DB).Master.Count(&node{})
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(2), total)
+	})
+
+	t.Run("when raw sql read, read from replica, else write into master", func(t *testing.T) {
+		db := newDB(0)
+		defer db.Close()
+
+		n := node{}
+		assert2.NoError(t, db.RawSqlWithObject("SELECT name FROM nodes", &n))
+		assert2.Equal(t, "replica", n.Name)
+
+		// - sqlite run a statement when its rows are read
+		rows, err := db.RawSql("INSERT INTO nodes (name) VALUES ('raw')")
+		assert2.NoError(t, err)
+		assert2.False(t, rows.Next())
+		assert2.NoError(t, rows.Close())
+		assert2.NoError(t, db.(*DB).Master.Where("name = ?", "raw").First(&node{}))
+	})
+
+	t.Run("when forced, read from master", func(t *testing.T) {
+		db := newDB(0)
+		defer db.Close()
+
+		ctx := ForceMaster(context.Background())
+
+		n := node{}
+		assert2.NoError(t, db.FirstWithContext(ctx, &n))
+		assert2.Equal(t, "master", n.Name)
+		assert2.Equal(t, "master", read(t, db.WithContext(ctx)))
+		assert2.Equal(t, "replica", read(t, db))
+	})
+
+	t.Run("when a session wrote, read its writes from master within the window", func(t *testing.T) {
+		db := newDB(50 * time.Millisecond)
+		defer db.Close()
+
+		alice := WithSession(context.Background(), "alice")
+		bob := WithSession(context.Background(), "bob")
+
+		assert2.NoError(t, db.WithContext(alice).Create(&node{Name: "zed"}))
+
+		assert2.Equal(t, "master", read(t, db.WithContext(alice)))
+		assert2.Equal(t, "replica", read(t, db.WithContext(bob)))
+		assert2.Equal(t, "replica", read(t, db))
+
+		time.Sleep(60 * time.Millisecond)
+		assert2.Equal(t, "replica", read(t, db.WithContext(alice)))
+	})
+
+	t.Run("when in a transaction, read from master", func(t *testing.T) {
+		db := newDB(0)
+		defer db.Close()
+
+		tx := db.Begin()
+		assert2.NoError(t, tx.Error())
+		assert2.True(t, tx.InTransaction())
+		assert2.Equal(t, "master", read(t, tx))
+		assert2.NoError(t, tx.Rollback())
+	})
+
+	t.Run("when bound, give its ctx to the retry of transactions", func(t *testing.T) {
+		db := newDB(0)
+		defer db.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		bound := db.WithContext(ctx)
+		assert2.Equal(t, ctx, bound.Context())
+
+		calls := 0
+		start := time.Now()
+
+		err := persistent.WithRetryTransaction(bound, func(tx persistent.ORM) error {
+			calls++
+			cancel()
+			return &pq.Error{Code: "40001"}
+		}, &persistent.RetryOption{MaxAttempts: 3, MinBackoff: time.Minute})
+		assert2.Equal(t, context.Canceled, errors.Cause(err))
+		assert2.Equal(t, 1, calls)
+		assert2.True(t, time.Since(start) < 5*time.Second)
+	})
+}
@@ -0,0 +1,545 @@
+package masterreplica
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+)
+
+const (
+	RoundRobin   Balancer = "ROUND_ROBIN"
+	Random       Balancer = "RANDOM"
+	LeastLatency Balancer = "LEAST_LATENCY"
+
+	DefaultCheckInterval = 5 * time.Second
+	DefaultCheckTimeout  = time.Second
+)
+
+type (
+	Balancer string
+
+	// LagProbe return how far behind the master a replica is.
+	LagProbe func(ctx context.Context, replica persistent.ORM) (time.Duration, error)
+
+	// PoolOption configure the replica selection and the health checks. A
+	// replica is taken out of rotation after FailureThreshold consecutive
+	// failed checks, a failed Ping or, when Lag is set, a lag above MaxLag,
+	// and put back after a successful check.
+	PoolOption struct {
+		Balancer         Balancer
+		CheckInterval    time.Duration
+		CheckTimeout     time.Duration
+		FailureThreshold int
+		Lag              LagProbe
+		MaxLag           time.Duration
+		Logger           logs.Logger
+	}
+
+	// Pool is a persistent.ORM reading from healthy replicas, it is meant to
+	// be the Replica of a DB. Reads fall back to the master when no replica
+	// is healthy, writes, DDL and transactions always run on the master.
+	Pool struct {
+		master   persistent.ORM
+		replicas []*replica
+		option   PoolOption
+
+		next    uint64
+		closing chan struct{}
+		wg      sync.WaitGroup
+	}
+
+	replica struct {
+		orm persistent.ORM
+
+		mu       sync.RWMutex
+		healthy  bool
+		failures int
+		latency  time.Duration
+	}
+)
+
+// PostgresLag probe the lag of a postgres standby, the age of the last
+// replayed transaction, zero on a primary.
+func PostgresLag(ctx context.Context, replica persistent.ORM) (time.Duration, error) {
+	rows, err := replica.RawSqlWithContext(ctx, "SELECT COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to probe replication lag")
+	}
+	defer rows.Close()
+
+	var seconds float64
+	if rows.Next() {
+		if err := rows.Scan(&seconds); err != nil {
+			return 0, errors.Wrap(err, "failed to probe replication lag")
+		}
+	}
+
+	return time.Duration(seconds * float64(time.Second)), rows.Err()
+}
+
+// MysqlLag probe the Seconds_Behind_Master of a mysql replica, an error when
+// the replication is stopped.
+func MysqlLag(ctx context.Context, replica persistent.ORM) (time.Duration, error) {
+	rows, err := replica.RawSqlWithContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to probe replication lag")
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to probe replication lag")
+	}
+
+	if !rows.Next() {
+		return 0, errors.New("replication is not configured")
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return 0, errors.Wrap(err, "failed to probe replication lag")
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Master" {
+			continue
+		}
+
+		if !values[i].Valid {
+			return 0, errors.New("replication is stopped")
+		}
+
+		seconds, err := strconv.ParseInt(values[i].String, 10, 64)
+		return time.Duration(seconds) * time.Second, errors.Wrap(err, "failed to probe replication lag")
+	}
+
+	return 0, errors.New("replication lag is unknown")
+}
+
+// NewWithReplicas create a new DB reading from a Pool of replicas.
+func NewWithReplicas(master persistent.ORM, replicas []persistent.ORM, option *PoolOption) persistent.ORM {
+	return New(master, NewPool(master, replicas, option))
+}
+
+// NewPool create a pool of replicas and start checking their health every
+// CheckInterval, replicas are healthy until a check fail. A nil option use
+// the defaults.
+func NewPool(master persistent.ORM, replicas []persistent.ORM, option *PoolOption) *Pool {
+	p := &Pool{master: master, closing: make(chan struct{})}
+
+	if option != nil {
+		p.option = *option
+	}
+
+	if p.option.Balancer == "" {
+		p.option.Balancer = RoundRobin
+	}
+	if p.option.CheckInterval <= 0 {
+		p.option.CheckInterval = DefaultCheckInterval
+	}
+	if p.option.CheckTimeout <= 0 {
+		p.option.CheckTimeout = DefaultCheckTimeout
+	}
+	if p.option.FailureThreshold <= 0 {
+		p.option.FailureThreshold = 1
+	}
+
+	for _, orm := range replicas {
+		p.replicas = append(p.replicas, &replica{orm: orm, healthy: true})
+	}
+
+	p.wg.Add(1)
+	go p.run()
+
+	return p
+}
+
+// Healthy return the number of replicas in rotation.
+func (p *Pool) Healthy() int {
+	healthy := 0
+	for _, r := range p.replicas {
+		if r.isHealthy() {
+			healthy++
+		}
+	}
+
+	return healthy
+}
+
+// Check run the health checks of every replica now.
+func (p *Pool) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i, r := range p.replicas {
+		wg.Add(1)
+		go func(i int, r *replica) {
+			defer wg.Done()
+			p.check(ctx, i, r)
+		}(i, r)
+	}
+
+	wg.Wait()
+}
+
+// Health send a ping to every replica and return their error by index, nil
+// for the healthy ones.
+func (p *Pool) Health() map[int]error {
+	health := make(map[int]error, len(p.replicas))
+	for i, r := range p.replicas {
+		health[i] = r.orm.Ping()
+	}
+
+	return health
+}
+
+// Ping return nil when the pool can serve reads, at least one replica or
+// else the master answer a ping. See Health for each replica.
+func (p *Pool) Ping() error {
+	health := p.Health()
+	for i := range p.replicas {
+		if health[i] == nil {
+			return nil
+		}
+	}
+
+	if err := p.master.Ping(); err != nil {
+		return errors.Wrapf(err, "no replica of %d is healthy, master", len(p.replicas))
+	}
+
+	return nil
+}
+
+// Close stop the health checks and close every replica connection.
+func (p *Pool) Close() error {
+	close(p.closing)
+	p.wg.Wait()
+
+	for i, r := range p.replicas {
+		if err := r.orm.Close(); err != nil {
+			return errors.Wrapf(err, "replica %d", i)
+		}
+	}
+
+	return nil
+}
+
+// Set set setting by name on the selected replica.
+func (p *Pool) Set(name string, value interface{}) persistent.ORM {
+	return p.pick().Set(name, value)
+}
+
+// Error is always nil, the pool itself does not chain.
+func (p *Pool) Error() error {
+	return nil
+}
+
+// WithContext bind the selected replica to ctx.
+func (p *Pool) WithContext(ctx context.Context) persistent.ORM {
+	return p.pick().WithContext(ctx)
+}
+
+// Context is always context.Background, the pool itself is never bound,
+// WithContext return the bound replica.
+func (p *Pool) Context() context.Context {
+	return context.Background()
+}
+
+// Where return a new relation on the selected replica.
+func (p *Pool) Where(query interface{}, args ...interface{}) persistent.ORM {
+	return p.pick().Where(query, args...)
+}
+
+// First find first record on the selected replica.
+func (p *Pool) First(object interface{}) error {
+	return p.pick().First(object)
+}
+
+// FirstWithContext is First bound to ctx.
+func (p *Pool) FirstWithContext(ctx context.Context, object interface{}) error {
+	return p.pick().FirstWithContext(ctx, object)
+}
+
+// All find all records on the selected replica.
+func (p *Pool) All(object interface{}) error {
+	return p.pick().All(object)
+}
+
+// AllWithContext is All bound to ctx.
+func (p *Pool) AllWithContext(ctx context.Context, object interface{}) error {
+	return p.pick().AllWithContext(ctx, object)
+}
+
+// Count count records on the selected replica.
+func (p *Pool) Count(model interface{}) (int64, error) {
+	return p.pick().Count(model)
+}
+
+// Order specify order on the selected replica.
+func (p *Pool) Order(args interface{}) persistent.ORM {
+	return p.pick().Order(args)
+}
+
+// Limit specify the number of records on the selected replica.
+func (p *Pool) Limit(args interface{}) persistent.ORM {
+	return p.pick().Limit(args)
+}
+
+// Offset specify the records to skip on the selected replica.
+func (p *Pool) Offset(args interface{}) persistent.ORM {
+	return p.pick().Offset(args)
+}
+
+// Create insert the value into master db.
+func (p *Pool) Create(object interface{}) error {
+	return p.master.Create(object)
+}
+
+// Update update the object in master db.
+func (p *Pool) Update(object interface{}) error {
+	return p.master.Update(object)
+}
+
+// Delete delete object in master db.
+func (p *Pool) Delete(object interface{}) error {
+	return p.master.Delete(object)
+}
+
+// BulkDelete bulk delete data in master db.
+func (p *Pool) BulkDelete(tableName string, data []interface{}, keys ...string) error {
+	return p.master.BulkDelete(tableName, data, keys...)
+}
+
+// SoftDelete soft deleting data in master db.
+func (p *Pool) SoftDelete(object interface{}) error {
+	return p.master.SoftDelete(object)
+}
+
+// Exec execute given query in master db.
+func (p *Pool) Exec(sql string, args ...interface{}) error {
+	return p.master.Exec(sql, args...)
+}
+
+// ExecWithContext execute given query with ctx in master db.
+func (p *Pool) ExecWithContext(ctx context.Context, sql string, args ...interface{}) error {
+	return p.master.ExecWithContext(ctx, sql, args...)
+}
+
+// RawSqlWithObject execute given raw query on the selected replica.
+func (p *Pool) RawSqlWithObject(sql string, object interface{}, args ...interface{}) error {
+	return p.pick().RawSqlWithObject(sql, object, args...)
+}
+
+// RawSql execute given raw query on the selected replica.
+func (p *Pool) RawSql(sql string, args ...interface{}) (*sql.Rows, error) {
+	return p.pick().RawSql(sql, args...)
+}
+
+// RawSqlWithContext execute given raw query with ctx on the selected
+// replica.
+func (p *Pool) RawSqlWithContext(ctx context.Context, sql string, args ...interface{}) (*sql.Rows, error) {
+	return p.pick().RawSqlWithContext(ctx, sql, args...)
+}
+
+// BulkUpsert bulk upsert data in master db.
+func (p *Pool) BulkUpsert(tableName string, chunkSize int, data []interface{}) (int64, error) {
+	return p.master.BulkUpsert(tableName, chunkSize, data)
+}
+
+// Search find data on the selected replica.
+func (p *Pool) Search(tableName string, selectField []string, criteria []persistent.Criteria, results interface{}) error {
+	return p.pick().Search(tableName, selectField, criteria, results)
+}
+
+// SearchWithContext is Search bound to ctx.
+func (p *Pool) SearchWithContext(ctx context.Context, tableName string, selectField []string, criteria []persistent.Criteria, results interface{}) error {
+	return p.pick().SearchWithContext(ctx, tableName, selectField, criteria, results)
+}
+
+// SearchQuery find data matching query on the selected replica.
+func (p *Pool) SearchQuery(query *persistent.Query, results interface{}) (int64, error) {
+	return p.pick().SearchQuery(query, results)
+}
+
+// SearchQueryWithContext is SearchQuery bound to ctx.
+func (p *Pool) SearchQueryWithContext(ctx context.Context, query *persistent.Query, results interface{}) (int64, error) {
+	return p.pick().SearchQueryWithContext(ctx, query, results)
+}
+
+// HasTable return true if given table's name is exist on the selected
+// replica.
+func (p *Pool) HasTable(tableName string) bool {
+	return p.pick().HasTable(tableName)
+}
+
+// CreateTable create a new table in master db.
+func (p *Pool) CreateTable(data interface{}) error {
+	return p.master.CreateTable(data)
+}
+
+// CreateTableWithName create a new table with given name in master db.
+func (p *Pool) CreateTableWithName(tableName string, data interface{}) error {
+	return p.master.CreateTableWithName(tableName, data)
+}
+
+// DropTable drop table if exist in master db.
+func (p *Pool) DropTable(data interface{}) error {
+	return p.master.DropTable(data)
+}
+
+// DropTableWithName drop table with spesific name if exist in master db.
+func (p *Pool) DropTableWithName(tableName string, data interface{}) error {
+	return p.master.DropTableWithName(tableName, data)
+}
+
+// Table specify the table on the selected replica.
+func (p *Pool) Table(tableName string) persistent.ORM {
+	return p.pick().Table(tableName)
+}
+
+// Begin begins a transaction in master db.
+func (p *Pool) Begin() persistent.ORM {
+	return p.master.Begin()
+}
+
+// BeginTx begins a transaction with opts in master db.
+func (p *Pool) BeginTx(opts *sql.TxOptions) persistent.ORM {
+	return p.master.BeginTx(opts)
+}
+
+// Commit commit a transaction in master db.
+func (p *Pool) Commit() error {
+	return p.master.Commit()
+}
+
+// Rollback rollback a transaction in master db.
+func (p *Pool) Rollback() error {
+	return p.master.Rollback()
+}
+
+// InTransaction is always false, transactions are begun on the master.
+func (p *Pool) InTransaction() bool {
+	return false
+}
+
+// UnderlyingDB return the connection of the selected replica.
+func (p *Pool) UnderlyingDB() *sql.DB {
+	return p.pick().UnderlyingDB()
+}
+
+// - private
+
+// pick select a healthy replica with the balancer, or the master when none
+// is healthy.
+func (p *Pool) pick() persistent.ORM {
+	healthy := make([]*replica, 0, len(p.replicas))
+	for _, r := range p.replicas {
+		if r.isHealthy() {
+			healthy = append(healthy, r)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return p.master
+	}
+
+	switch p.option.Balancer {
+	case Random:
+		return healthy[rand.Intn(len(healthy))].orm
+	case LeastLatency:
+		best := healthy[0]
+		for _, r := range healthy[1:] {
+			if r.getLatency() < best.getLatency() {
+				best = r
+			}
+		}
+		return best.orm
+	}
+
+	return healthy[atomic.AddUint64(&p.next, 1)%uint64(len(healthy))].orm
+}
+
+func (p *Pool) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.option.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.closing:
+			return
+		case <-ticker.C:
+			p.Check(context.Background())
+		}
+	}
+}
+
+func (p *Pool) check(ctx context.Context, i int, r *replica) {
+	ctx, cancel := context.WithTimeout(ctx, p.option.CheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := r.orm.WithContext(ctx).Ping()
+	latency := time.Since(start)
+
+	if err == nil && p.option.Lag != nil && p.option.MaxLag > 0 {
+		var lag time.Duration
+		if lag, err = p.option.Lag(ctx, r.orm); err == nil && lag > p.option.MaxLag {
+			err = errors.Errorf("replication lag %s exceed %s", lag, p.option.MaxLag)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil {
+		// - smooth the latency so a single slow ping does not move traffic
+		if r.latency == 0 {
+			r.latency = latency
+		} else {
+			r.latency = (r.latency*4 + latency) / 5
+		}
+
+		if !r.healthy && p.option.Logger != nil {
+			p.option.Logger.Infof("replica %d is back in rotation", i)
+		}
+		r.healthy, r.failures = true, 0
+		return
+	}
+
+	r.failures++
+	if r.healthy && r.failures >= p.option.FailureThreshold {
+		r.healthy = false
+		if p.option.Logger != nil {
+			p.option.Logger.Errorf("replica %d is out of rotation: %s", i, err)
+		}
+	}
+}
+
+func (r *replica) isHealthy() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.healthy
+}
+
+func (r *replica) getLatency() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.latency
+}
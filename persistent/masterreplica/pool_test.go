@@ -0,0 +1,168 @@
+package masterreplica
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent/sqlite"
+)
+
+type node struct {
+	Name string `gorm:"primary_key"`
+}
+
+func newNode(t *testing.T, name string) persistent.ORM {
+	logger, err := logs.DefaultLog()
+	assert2.NoError(t, err)
+
+	orm, err := sqlite.New(":memory:", &persistent.Option{}, logger)
+	assert2.NoError(t, err)
+
+	assert2.NoError(t, orm.CreateTable(&node{}))
+	assert2.NoError(t, orm.Create(&node{Name: name}))
+
+	return orm
+}
+
+func read(t *testing.T, orm persistent.ORM) string {
+	n := node{}
+	assert2.NoError(t, orm.First(&n))
+
+	return n.Name
+}
+
+func Test_Pool(t *testing.T) {
+	lags := sync.Map{}
+	lag := func(ctx context.Context, replica persistent.ORM) (time.Duration, error) {
+		if v, ok := lags.Load(read(t, replica)); ok {
+			return v.(time.Duration), nil
+		}
+		return 0, nil
+	}
+
+	newPool := func(balancer Balancer) (*Pool, persistent.ORM) {
+		master := newNode(t, "master")
+		replicas := []persistent.ORM{newNode(t, "r0"), newNode(t, "r1"), newNode(t, "r2")}
+
+		return NewPool(master, replicas, &PoolOption{
+			Balancer:      balancer,
+			CheckInterval: time.Hour,
+			Lag:           lag,
+			MaxLag:        time.Second,
+		}), master
+	}
+
+	t.Run("when round robin, read every replica in turn", func(t *testing.T) {
+		pool, master := newPool(RoundRobin)
+		defer master.Close()
+		defer pool.Close()
+
+		seen := map[string]int{}
+		for i := 0; i < 6; i++ {
+			seen[read(t, pool)]++
+		}
+
+		assert2.Equal(t, map[string]int{"r0": 2, "r1": 2, "r2": 2}, seen)
+	})
+
+	t.Run("when random, read only replicas", func(t *testing.T) {
+		pool, master := newPool(Random)
+		defer master.Close()
+		defer pool.Close()
+
+		for i := 0; i < 10; i++ {
+			assert2.Contains(t, []string{"r0", "r1", "r2"}, read(t, pool))
+		}
+	})
+
+	t.Run("when a replica lag, take it out of rotation until it catch up", func(t *testing.T) {
+		pool, master := newPool(RoundRobin)
+		defer master.Close()
+		defer pool.Close()
+
+		lags.Store("r1", time.Minute)
+		defer lags.Delete("r1")
+
+		pool.Check(context.Background())
+		assert2.Equal(t, 2, pool.Healthy())
+
+		for i := 0; i < 6; i++ {
+			assert2.NotEqual(t, "r1", read(t, pool))
+		}
+
+		lags.Store("r1", time.Millisecond)
+		pool.Check(context.Background())
+		assert2.Equal(t, 3, pool.Healthy())
+	})
+
+	t.Run("when no replica is healthy, read from master", func(t *testing.T) {
+		pool, master := newPool(LeastLatency)
+		defer master.Close()
+		defer pool.Close()
+
+		for _, name := range []string{"r0", "r1", "r2"} {
+			lags.Store(name, time.Minute)
+			defer lags.Delete(name)
+		}
+
+		pool.Check(context.Background())
+		assert2.Equal(t, 0, pool.Healthy())
+		assert2.Equal(t, "master", read(t, pool))
+	})
+
+	t.Run("when option is nil, use the defaults", func(t *testing.T) {
+		master := newNode(t, "master")
+		pool := NewPool(master, []persistent.ORM{newNode(t, "r0")}, nil)
+		defer master.Close()
+		defer pool.Close()
+
+		assert2.Equal(t, RoundRobin, pool.option.Balancer)
+		assert2.Equal(t, DefaultCheckInterval, pool.option.CheckInterval)
+		assert2.Equal(t, "r0", read(t, pool))
+
+		db := NewWithOption(master, pool, nil)
+		assert2.Equal(t, "r0", read(t, db))
+	})
+
+	t.Run("when a replica is down, ping while any replica or master answer", func(t *testing.T) {
+		pool, master := newPool(RoundRobin)
+		defer master.Close()
+		defer pool.Close()
+
+		assert2.NoError(t, pool.Ping())
+		assert2.Equal(t, map[int]error{0: nil, 1: nil, 2: nil}, pool.Health())
+
+		assert2.NoError(t, pool.replicas[1].orm.Close())
+		assert2.NoError(t, pool.Ping())
+
+		health := pool.Health()
+		assert2.NoError(t, health[0])
+		assert2.Error(t, health[1])
+		assert2.NoError(t, health[2])
+
+		assert2.NoError(t, pool.replicas[0].orm.Close())
+		assert2.NoError(t, pool.replicas[2].orm.Close())
+		assert2.NoError(t, pool.Ping())
+
+		assert2.NoError(t, master.Close())
+		assert2.Error(t, pool.Ping())
+	})
+
+	t.Run("when writing through a db, write into master", func(t *testing.T) {
+		pool, master := newPool(RoundRobin)
+		db := New(master, pool)
+		defer db.Close()
+
+		assert2.NoError(t, db.Create(&node{Name: "written"}))
+
+		total, err := master.Count(&node{})
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(2), total)
+	})
+}
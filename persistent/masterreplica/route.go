@@ -0,0 +1,143 @@
+package masterreplica
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type contextKey int
+
+const (
+	forceMasterKey contextKey = iota
+	sessionKey
+)
+
+var (
+	// noise are the string literals and comments, their words are not SQL.
+	noise = regexp.MustCompile(`(?s)'(?:[^']|'')*'|/\*.*?\*/|--[^\n]*`)
+	word  = regexp.MustCompile(`[A-Z_][A-Z0-9_]*`)
+
+	// readStatements are the statements a replica can run.
+	readStatements = map[string]bool{
+		"SELECT": true, "WITH": true, "VALUES": true, "TABLE": true,
+		"SHOW": true, "EXPLAIN": true, "DESCRIBE": true, "DESC": true,
+	}
+
+	// writeWords turn a read statement into a write, data modifying CTEs,
+	// locking reads, SELECT INTO and sequences.
+	writeWords = map[string]bool{
+		"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true,
+		"INTO": true, "SHARE": true, "LOCK": true, "NEXTVAL": true, "SETVAL": true,
+	}
+)
+
+type (
+	// Option configure the routing of a DB. StickyWindow is how long the
+	// reads of a session, see WithSession, go to the master after it wrote,
+	// so users see their own writes despite the replication lag. Zero
+	// disable it. The writes are only remembered in the memory of the
+	// process, a session whose next request reach another instance, or
+	// follow a restart, read from the replica: route the sessions to the
+	// same instance or use ForceMaster where it matters.
+	Option struct {
+		StickyWindow time.Duration
+	}
+
+	// sessions remember the last write of every session within the window.
+	sessions struct {
+		window time.Duration
+
+		mu     sync.Mutex
+		writes map[string]time.Time
+		swept  time.Time
+	}
+)
+
+// ForceMaster return a ctx whose reads go to the master, for the reads which
+// must not be stale or which have side effects the routing cannot see.
+//
+//	err := db.FirstWithContext(masterreplica.ForceMaster(ctx), &order)
+func ForceMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceMasterKey, true)
+}
+
+// WithSession return a ctx of session, typically a user or a session id, the
+// reads of a session go to the master for the StickyWindow after each of its
+// writes made through the same DB in this process, see Option.
+func WithSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey, session)
+}
+
+// IsRead return true if query only read and can run on a replica. Anything
+// unknown is a write, it is always safe on the master.
+func IsRead(query string) bool {
+	words := word.FindAllString(strings.ToUpper(noise.ReplaceAllString(query, " ")), -1)
+	if len(words) == 0 || !readStatements[words[0]] {
+		return false
+	}
+
+	for _, w := range words[1:] {
+		if writeWords[w] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// - private
+
+func forced(ctx context.Context) bool {
+	force, _ := ctx.Value(forceMasterKey).(bool)
+	return force
+}
+
+func newSessions(window time.Duration) *sessions {
+	if window <= 0 {
+		return nil
+	}
+
+	return &sessions{window: window, writes: make(map[string]time.Time)}
+}
+
+// wrote start the window of the session of ctx.
+func (s *sessions) wrote(ctx context.Context) {
+	session, ok := ctx.Value(sessionKey).(string)
+	if s == nil || !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.writes[session] = now
+
+	// - forget the sessions which have not written within the window
+	if now.Sub(s.swept) > s.window {
+		for key, at := range s.writes {
+			if now.Sub(at) > s.window {
+				delete(s.writes, key)
+			}
+		}
+		s.swept = now
+	}
+}
+
+// sticky return true if the session of ctx wrote within the window.
+func (s *sessions) sticky(ctx context.Context) bool {
+	session, ok := ctx.Value(sessionKey).(string)
+	if s == nil || !ok {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at, ok := s.writes[session]
+
+	return ok && time.Since(at) <= s.window
+}
@@ -0,0 +1,44 @@
+package masterreplica
+
+import (
+	"testing"
+
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func Test_IsRead(t *testing.T) {
+	reads := []string{
+		"SELECT * FROM orders WHERE id = ?",
+		"  select count(*) from orders",
+		"/* report */ SELECT total FROM orders",
+		"-- report\nSELECT total FROM orders",
+		"(SELECT id FROM a) UNION (SELECT id FROM b)",
+		"WITH paid AS (SELECT * FROM orders) SELECT * FROM paid",
+		"SELECT * FROM orders WHERE note = 'to update'",
+		"SELECT updated_at FROM orders",
+		"SHOW TABLES",
+		"EXPLAIN SELECT * FROM orders",
+	}
+	for _, query := range reads {
+		assert2.True(t, IsRead(query), query)
+	}
+
+	writes := []string{
+		"",
+		"INSERT INTO orders (id) VALUES (1)",
+		"update orders SET total = 1",
+		"DELETE FROM orders",
+		"CREATE TABLE orders (id int)",
+		"WITH moved AS (DELETE FROM orders RETURNING *) SELECT * FROM moved",
+		"SELECT * FROM orders WHERE id = 1 FOR UPDATE",
+		"SELECT * FROM orders FOR SHARE",
+		"SELECT * FROM orders LOCK IN SHARE MODE",
+		"SELECT * INTO archive FROM orders",
+		"SELECT nextval('orders_id_seq')",
+		"SELECT * FROM orders WHERE note = '--' FOR UPDATE",
+		"EXPLAIN ANALYZE DELETE FROM orders",
+	}
+	for _, query := range writes {
+		assert2.False(t, IsRead(query), query)
+	}
+}
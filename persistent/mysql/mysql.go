@@ -23,5 +23,10 @@ func New(uri string, option *persistent.Option, logger logs.Logger) (persistent.
 	db.DB().SetMaxOpenConns(option.MaxOpenConnection)
 	db.DB().SetConnMaxLifetime(option.ConnMaxLifetime)
 
-	return &persistent.Impl{Database: db, Logger: logger, DB: db.DB()}, nil
+	orm := &persistent.Impl{Database: db, Logger: logger, DB: db.DB(), LogMode: option.LogMode}
+	if len(option.Hooks) > 0 {
+		return orm.Use(option.Hooks...), nil
+	}
+
+	return orm, nil
 }
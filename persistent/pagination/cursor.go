@@ -0,0 +1,123 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type (
+	// cursor is the position of a keyset page, the sort values of the row
+	// the page start after, or before when Prev. Sort is the sort the
+	// values belong to, a cursor is only valid for the same sort.
+	cursor struct {
+		Sort   string  `json:"s"`
+		Prev   bool    `json:"p,omitempty"`
+		Values []value `json:"v"`
+	}
+
+	// value keep the type of a sort value, json would turn times and large
+	// integers into strings and floats.
+	value struct {
+		Kind  string `json:"k"`
+		Value string `json:"v"`
+	}
+)
+
+// encode sign c, the cursor is base64 url of the json and of its signature.
+func (p *Paginator) encode(c *cursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode cursor")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload)), nil
+}
+
+// decode verify s is a cursor of sort signed by p.
+func (p *Paginator) decode(s, sort string) (*cursor, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, p.sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &cursor{}
+	if err := json.Unmarshal(payload, c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+func (p *Paginator) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+func newValue(v interface{}) (value, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return value{Kind: "time", Value: t.Format(time.RFC3339Nano)}, nil
+	case primitive.ObjectID:
+		return value{Kind: "oid", Value: t.Hex()}, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return value{Kind: "string", Value: rv.String()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value{Kind: "int", Value: strconv.FormatInt(rv.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value{Kind: "uint", Value: strconv.FormatUint(rv.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return value{Kind: "float", Value: strconv.FormatFloat(rv.Float(), 'g', -1, 64)}, nil
+	case reflect.Bool:
+		return value{Kind: "bool", Value: strconv.FormatBool(rv.Bool())}, nil
+	}
+
+	return value{}, errors.Errorf("sort value %T is not supported", v)
+}
+
+func (v value) parse() (interface{}, error) {
+	switch v.Kind {
+	case "time":
+		return time.Parse(time.RFC3339Nano, v.Value)
+	case "oid":
+		return primitive.ObjectIDFromHex(v.Value)
+	case "string":
+		return v.Value, nil
+	case "int":
+		return strconv.ParseInt(v.Value, 10, 64)
+	case "uint":
+		return strconv.ParseUint(v.Value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(v.Value, 64)
+	case "bool":
+		return strconv.ParseBool(v.Value)
+	}
+
+	return nil, errors.Errorf("sort value kind %q is not supported", v.Kind)
+}
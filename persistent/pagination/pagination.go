@@ -0,0 +1,469 @@
+package pagination
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent/mongo"
+)
+
+const (
+	DefaultSize = 20
+	MaxSize     = 100
+)
+
+var (
+	// field is a column, optionally qualified by its table, or the path of
+	// a document field.
+	field = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+	sqlNaming  = naming{name: sqlName}
+	bsonNaming = naming{name: bsonName, nested: true}
+)
+
+type (
+	// Page is a page of Items, a slice of the results type. Total is only
+	// counted by offset pagination, cursors are only set by keyset
+	// pagination, empty when there is no page in that direction.
+	Page struct {
+		Items      interface{} `json:"items"`
+		NextCursor string      `json:"next_cursor,omitempty"`
+		PrevCursor string      `json:"prev_cursor,omitempty"`
+		Total      int64       `json:"total"`
+	}
+
+	// Sort is a sort field. With keyset pagination the last field must be
+	// unique, typically the primary key, so every row has its own position,
+	// and the fields must not be null.
+	Sort struct {
+		Field string
+		Desc  bool
+	}
+
+	// Request is the page to load. Page start from 1 and is only used by
+	// offset pagination, Cursor is the NextCursor or PrevCursor of the page
+	// shown and is only used by keyset pagination, empty for the first page.
+	// Size default to DefaultSize and is capped by MaxSize.
+	Request struct {
+		Page   int
+		Size   int
+		Cursor string
+	}
+
+	// Paginator sign and verify the keyset cursors with secret, so clients
+	// can not forge a position.
+	Paginator struct {
+		secret []byte
+	}
+
+	// naming resolve a sort field to the field of the results holding its
+	// value.
+	naming struct {
+		name func(reflect.StructField) string
+		// nested walk a dotted sort field through the nested structs, else
+		// it is qualified by its table and only its last part is a field.
+		nested bool
+	}
+)
+
+func New(secret []byte) *Paginator {
+	return &Paginator{secret: secret}
+}
+
+// Offset load the request page of orm, filtered with Where beforehand, in
+// sorts order and count the rows matching.
+func Offset(orm persistent.ORM, sorts []Sort, request *Request, results interface{}) (*Page, error) {
+	if err := validate(sorts); err != nil {
+		return nil, err
+	}
+
+	total, err := orm.Count(results)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range sorts {
+		orm = orm.Order(s.sql(false))
+	}
+
+	size := request.size()
+	if err := orm.Offset((request.page() - 1) * size).Limit(size).All(results); err != nil {
+		return nil, err
+	}
+
+	return &Page{Items: reflect.ValueOf(results).Elem().Interface(), Total: total}, nil
+}
+
+// OffsetMongo load the request page of the documents of collection matching
+// filter in sorts order and count the documents matching.
+func OffsetMongo(ctx context.Context, db mongo.Mongo, collection string, filter interface{}, sorts []Sort, request *Request, results interface{}) (*Page, error) {
+	if err := validate(sorts); err != nil {
+		return nil, err
+	}
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	total, err := db.CountWithFilterAndContext(ctx, collection, filter)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to count %s", collection)
+	}
+
+	size := request.size()
+	find := options.Find().
+		SetSort(mongoSort(sorts, false)).
+		SetSkip(int64((request.page() - 1) * size)).
+		SetLimit(int64(size))
+
+	if err := db.FindAllWithContext(ctx, collection, filter, results, find); err != nil {
+		return nil, errors.Wrapf(err, "failed to find %s", collection)
+	}
+
+	return &Page{Items: reflect.ValueOf(results).Elem().Interface(), Total: total}, nil
+}
+
+// Keyset load the rows of orm, filtered with Where beforehand, after or
+// before the request cursor in sorts order. Sort values are read from the
+// fields of results named after the columns, gorm column tags included.
+func (p *Paginator) Keyset(orm persistent.ORM, sorts []Sort, request *Request, results interface{}) (*Page, error) {
+	c, values, err := p.position(sorts, request)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := c != nil && c.Prev
+	if c != nil {
+		where, args := sqlAfter(sorts, values, prev)
+		orm = orm.Where(where, args...)
+	}
+
+	for _, s := range sorts {
+		orm = orm.Order(s.sql(prev))
+	}
+
+	if err := orm.Limit(request.size() + 1).All(results); err != nil {
+		return nil, err
+	}
+
+	return p.page(sorts, request.size(), c, results, sqlNaming)
+}
+
+// KeysetMongo load the documents of collection matching filter after or
+// before the request cursor in sorts order. Sort values are read from the
+// fields of results named after the document fields, bson tags included,
+// a dotted sort field like address.city is read through the nested structs.
+func (p *Paginator) KeysetMongo(ctx context.Context, db mongo.Mongo, collection string, filter interface{}, sorts []Sort, request *Request, results interface{}) (*Page, error) {
+	c, values, err := p.position(sorts, request)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := c != nil && c.Prev
+	if c != nil {
+		after := mongoAfter(sorts, values, prev)
+		if filter == nil {
+			filter = after
+		} else {
+			filter = bson.M{"$and": bson.A{filter, after}}
+		}
+	}
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	find := options.Find().
+		SetSort(mongoSort(sorts, prev)).
+		SetLimit(int64(request.size() + 1))
+
+	if err := db.FindAllWithContext(ctx, collection, filter, results, find); err != nil {
+		return nil, errors.Wrapf(err, "failed to find %s", collection)
+	}
+
+	return p.page(sorts, request.size(), c, results, bsonNaming)
+}
+
+// - private
+
+func (r *Request) size() int {
+	if r.Size <= 0 {
+		return DefaultSize
+	}
+
+	if r.Size > MaxSize {
+		return MaxSize
+	}
+
+	return r.Size
+}
+
+func (r *Request) page() int {
+	if r.Page < 1 {
+		return 1
+	}
+
+	return r.Page
+}
+
+func (s Sort) sql(reverse bool) string {
+	if s.Desc != reverse {
+		return s.Field + " DESC"
+	}
+
+	return s.Field + " ASC"
+}
+
+// operator compare the rows after a position, before when reverse.
+func (s Sort) operator(reverse bool) string {
+	if s.Desc != reverse {
+		return "<"
+	}
+
+	return ">"
+}
+
+func validate(sorts []Sort) error {
+	for _, s := range sorts {
+		if !field.MatchString(s.Field) {
+			return errors.Errorf("sort field %q is not allowed", s.Field)
+		}
+	}
+
+	return nil
+}
+
+// sortKey identify sorts, a cursor is only valid for the sort it was
+// created with.
+func sortKey(sorts []Sort) string {
+	parts := make([]string, 0, len(sorts))
+	for _, s := range sorts {
+		parts = append(parts, s.sql(false))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// position decode the cursor of request, nil for the first page.
+func (p *Paginator) position(sorts []Sort, request *Request) (*cursor, []interface{}, error) {
+	if len(sorts) == 0 {
+		return nil, nil, errors.New("keyset pagination require a sort")
+	}
+
+	if err := validate(sorts); err != nil {
+		return nil, nil, err
+	}
+
+	if request.Cursor == "" {
+		return nil, nil, nil
+	}
+
+	c, err := p.decode(request.Cursor, sortKey(sorts))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(c.Values) != len(sorts) {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, 0, len(c.Values))
+	for _, v := range c.Values {
+		parsed, err := v.parse()
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		values = append(values, parsed)
+	}
+
+	return c, values, nil
+}
+
+// sqlAfter return the condition of the rows after values in sorts order,
+// before when reverse, e.g. (a > ?) OR (a = ? AND b > ?).
+func sqlAfter(sorts []Sort, values []interface{}, reverse bool) (string, []interface{}) {
+	ors := make([]string, 0, len(sorts))
+	args := make([]interface{}, 0)
+
+	for i, s := range sorts {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, sorts[j].Field+" = ?")
+			args = append(args, values[j])
+		}
+
+		ands = append(ands, s.Field+" "+s.operator(reverse)+" ?")
+		args = append(args, values[i])
+
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	return strings.Join(ors, " OR "), args
+}
+
+// mongoAfter is sqlAfter as a mongo filter.
+func mongoAfter(sorts []Sort, values []interface{}, reverse bool) bson.M {
+	ors := make(bson.A, 0, len(sorts))
+
+	for i, s := range sorts {
+		condition := bson.M{}
+		for j := 0; j < i; j++ {
+			condition[sorts[j].Field] = values[j]
+		}
+
+		operator := "$gt"
+		if s.operator(reverse) == "<" {
+			operator = "$lt"
+		}
+		condition[s.Field] = bson.M{operator: values[i]}
+
+		ors = append(ors, condition)
+	}
+
+	return bson.M{"$or": ors}
+}
+
+func mongoSort(sorts []Sort, reverse bool) bson.D {
+	d := make(bson.D, 0, len(sorts))
+	for _, s := range sorts {
+		direction := 1
+		if s.Desc != reverse {
+			direction = -1
+		}
+		d = append(d, bson.E{Key: s.Field, Value: direction})
+	}
+
+	return d
+}
+
+// page trim the extra row loaded to know if there is a page after, restore
+// the sort order of a page loaded backward and create the cursors of the
+// pages around.
+func (p *Paginator) page(sorts []Sort, size int, c *cursor, results interface{}, naming naming) (*Page, error) {
+	rows := reflect.ValueOf(results).Elem()
+
+	more := rows.Len() > size
+	if more {
+		rows.Set(rows.Slice(0, size))
+	}
+
+	prev := c != nil && c.Prev
+	if prev {
+		for l, r := 0, rows.Len()-1; l < r; l, r = l+1, r-1 {
+			left, right := rows.Index(l).Interface(), rows.Index(r).Interface()
+			rows.Index(l).Set(reflect.ValueOf(right))
+			rows.Index(r).Set(reflect.ValueOf(left))
+		}
+	}
+
+	page := &Page{Items: rows.Interface()}
+	if rows.Len() == 0 {
+		return page, nil
+	}
+
+	var err error
+
+	// - a page loaded backward always come from the page after it
+	if more || prev {
+		if page.NextCursor, err = p.cursor(sorts, rows.Index(rows.Len()-1), false, naming); err != nil {
+			return nil, err
+		}
+	}
+
+	if (more && prev) || (c != nil && !prev) {
+		if page.PrevCursor, err = p.cursor(sorts, rows.Index(0), true, naming); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+func (p *Paginator) cursor(sorts []Sort, row reflect.Value, prev bool, naming naming) (string, error) {
+	c := &cursor{Sort: sortKey(sorts), Prev: prev, Values: make([]value, 0, len(sorts))}
+
+	for _, s := range sorts {
+		path := []string{s.Field[strings.LastIndex(s.Field, ".")+1:]}
+		if naming.nested {
+			path = strings.Split(s.Field, ".")
+		}
+
+		v, ok := fieldValue(row, path, naming)
+		if !ok {
+			return "", errors.Errorf("sort field %s not found in %s", s.Field, row.Type())
+		}
+
+		encoded, err := newValue(v)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, encoded)
+	}
+
+	return p.encode(c)
+}
+
+// fieldValue return the value of the field of row at path, embedded structs
+// included.
+func fieldValue(row reflect.Value, path []string, naming naming) (interface{}, bool) {
+	row = reflect.Indirect(row)
+	if row.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	for i := 0; i < row.NumField(); i++ {
+		f := row.Type().Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		if naming.name(f) == path[0] || f.Name == path[0] {
+			value := reflect.Indirect(row.Field(i))
+			if !value.IsValid() {
+				return nil, false
+			}
+
+			if len(path) > 1 {
+				return fieldValue(value, path[1:], naming)
+			}
+			return value.Interface(), true
+		}
+
+		if f.Anonymous {
+			if v, ok := fieldValue(row.Field(i), path, naming); ok {
+				return v, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// sqlName is the gorm column of f.
+func sqlName(f reflect.StructField) string {
+	for _, setting := range strings.Split(f.Tag.Get("gorm"), ";") {
+		parts := strings.SplitN(setting, ":", 2)
+		if len(parts) == 2 && strings.EqualFold(strings.TrimSpace(parts[0]), "column") {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+
+	return gorm.ToColumnName(f.Name)
+}
+
+// bsonName is the document field of f.
+func bsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("bson"), ",")[0]; name != "" {
+		return name
+	}
+
+	return strings.ToLower(f.Name)
+}
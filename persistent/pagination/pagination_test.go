@@ -0,0 +1,198 @@
+package pagination
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	assert2 "github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+)
+
+type event struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	Seq     int64              `gorm:"column:sequence"`
+	At      time.Time          `bson:"happened_at" gorm:"column:happened_at"`
+	Payload string
+}
+
+type (
+	customer struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		Address address            `bson:"address"`
+	}
+
+	address struct {
+		City string `bson:"city"`
+	}
+
+	entry struct {
+		ID int64     `gorm:"primary_key;column:sequence"`
+		At time.Time `gorm:"column:happened_at"`
+	}
+)
+
+func Test_Cursor(t *testing.T) {
+	p := New([]byte("secret"))
+	sorts := []Sort{{Field: "happened_at", Desc: true}, {Field: "sequence"}}
+
+	row := event{Seq: 1<<60 + 1, At: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)}
+	encoded, err := p.cursor(sorts, reflect.ValueOf(row), false, sqlNaming)
+	assert2.NoError(t, err)
+
+	t.Run("when cursor is valid", func(t *testing.T) {
+		c, values, err := p.position(sorts, &Request{Cursor: encoded})
+		assert2.NoError(t, err)
+		assert2.False(t, c.Prev)
+		assert2.Equal(t, []interface{}{row.At, row.Seq}, values)
+	})
+
+	t.Run("when cursor is tampered", func(t *testing.T) {
+		_, _, err := p.position(sorts, &Request{Cursor: "x" + encoded})
+		assert2.Equal(t, ErrInvalidCursor, err)
+	})
+
+	t.Run("when cursor is signed with another secret", func(t *testing.T) {
+		_, _, err := New([]byte("other")).position(sorts, &Request{Cursor: encoded})
+		assert2.Equal(t, ErrInvalidCursor, err)
+	})
+
+	t.Run("when cursor belong to another sort", func(t *testing.T) {
+		_, _, err := p.position([]Sort{{Field: "sequence"}}, &Request{Cursor: encoded})
+		assert2.Equal(t, ErrInvalidCursor, err)
+	})
+
+	t.Run("when sort field is not allowed", func(t *testing.T) {
+		_, _, err := p.position([]Sort{{Field: "id; drop table events"}}, &Request{})
+		assert2.Error(t, err)
+	})
+
+	t.Run("when document sort field is nested", func(t *testing.T) {
+		nested := []Sort{{Field: "address.city"}, {Field: "_id"}}
+		row := customer{ID: primitive.NewObjectID(), Address: address{City: "Medan"}}
+
+		encoded, err := p.cursor(nested, reflect.ValueOf(row), false, bsonNaming)
+		assert2.NoError(t, err)
+
+		_, values, err := p.position(nested, &Request{Cursor: encoded})
+		assert2.NoError(t, err)
+		assert2.Equal(t, []interface{}{"Medan", row.ID}, values)
+
+		_, err = p.cursor([]Sort{{Field: "address.zip"}}, reflect.ValueOf(row), false, bsonNaming)
+		assert2.Error(t, err)
+	})
+}
+
+func Test_After(t *testing.T) {
+	sorts := []Sort{{Field: "happened_at", Desc: true}, {Field: "id"}}
+
+	t.Run("when paging forward", func(t *testing.T) {
+		where, args := sqlAfter(sorts, []interface{}{"t", 7}, false)
+		assert2.Equal(t, "(happened_at < ?) OR (happened_at = ? AND id > ?)", where)
+		assert2.Equal(t, []interface{}{"t", "t", 7}, args)
+	})
+
+	t.Run("when paging backward", func(t *testing.T) {
+		where, _ := sqlAfter(sorts, []interface{}{"t", 7}, true)
+		assert2.Equal(t, "(happened_at > ?) OR (happened_at = ? AND id < ?)", where)
+
+		filter := mongoAfter(sorts, []interface{}{"t", 7}, true)
+		assert2.Equal(t, bson.M{"$or": bson.A{
+			bson.M{"happened_at": bson.M{"$gt": "t"}},
+			bson.M{"happened_at": "t", "id": bson.M{"$lt": 7}},
+		}}, filter)
+		assert2.Equal(t, bson.D{{Key: "happened_at", Value: 1}, {Key: "id", Value: -1}}, mongoSort(sorts, true))
+	})
+}
+
+func Test_Page(t *testing.T) {
+	p := New([]byte("secret"))
+	sorts := []Sort{{Field: "_id"}}
+	rows := func(n int) *[]event {
+		r := make([]event, 0, n)
+		for i := 0; i < n; i++ {
+			r = append(r, event{ID: primitive.NewObjectID()})
+		}
+		return &r
+	}
+
+	t.Run("when first page has more rows", func(t *testing.T) {
+		page, err := p.page(sorts, 2, nil, rows(3), bsonNaming)
+		assert2.NoError(t, err)
+		assert2.Len(t, page.Items, 2)
+		assert2.NotEmpty(t, page.NextCursor)
+		assert2.Empty(t, page.PrevCursor)
+	})
+
+	t.Run("when last page is loaded forward", func(t *testing.T) {
+		page, err := p.page(sorts, 2, &cursor{}, rows(1), bsonNaming)
+		assert2.NoError(t, err)
+		assert2.Empty(t, page.NextCursor)
+		assert2.NotEmpty(t, page.PrevCursor)
+	})
+
+	t.Run("when page is loaded backward", func(t *testing.T) {
+		results := rows(2)
+		first, last := (*results)[0].ID, (*results)[1].ID
+
+		page, err := p.page(sorts, 2, &cursor{Prev: true}, results, bsonNaming)
+		assert2.NoError(t, err)
+		assert2.Equal(t, []event{{ID: last}, {ID: first}}, page.Items)
+		assert2.NotEmpty(t, page.NextCursor)
+		assert2.Empty(t, page.PrevCursor)
+	})
+}
+
+func Test_Keyset(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert2.NoError(t, err)
+	db.DB().SetMaxOpenConns(1)
+
+	orm := &persistent.Impl{Database: db, DB: db.DB()}
+	defer orm.Close()
+
+	assert2.NoError(t, orm.CreateTable(&entry{}))
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for seq := int64(1); seq <= 5; seq++ {
+		// - two entries share each time, the sequence break the tie
+		assert2.NoError(t, orm.Create(&entry{ID: seq, At: start.Add(time.Duration(seq/2) * time.Hour)}))
+	}
+
+	p := New([]byte("secret"))
+	sorts := []Sort{{Field: "happened_at", Desc: true}, {Field: "sequence"}}
+	load := func(cursor string) (*Page, []int64) {
+		var entries []entry
+		page, err := p.Keyset(orm, sorts, &Request{Size: 2, Cursor: cursor}, &entries)
+		assert2.NoError(t, err)
+
+		seqs := make([]int64, 0, len(entries))
+		for _, e := range entries {
+			seqs = append(seqs, e.ID)
+		}
+		return page, seqs
+	}
+
+	first, seqs := load("")
+	assert2.Equal(t, []int64{4, 5}, seqs)
+	assert2.Empty(t, first.PrevCursor)
+
+	second, seqs := load(first.NextCursor)
+	assert2.Equal(t, []int64{2, 3}, seqs)
+
+	last, seqs := load(second.NextCursor)
+	assert2.Equal(t, []int64{1}, seqs)
+	assert2.Empty(t, last.NextCursor)
+
+	back, seqs := load(last.PrevCursor)
+	assert2.Equal(t, []int64{2, 3}, seqs)
+	assert2.NotEmpty(t, back.NextCursor)
+
+	back, seqs = load(back.PrevCursor)
+	assert2.Equal(t, []int64{4, 5}, seqs)
+	assert2.Empty(t, back.PrevCursor)
+}
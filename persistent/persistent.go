@@ -1,13 +1,10 @@
 package persistent
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
-	"fmt"
 	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
 	"github.com/AndreeJait/GO-ANDREE-UTILITIES/util"
-	"reflect"
-	"strings"
 	"time"
 
 
@@ -17,13 +14,19 @@ import (
 )
 
 const (
+	// Deprecated: postgres only, BulkUpsert build its statement per dialect,
+	// see upsertQuery in bulk.go.
 	UpsertQuery string = `insert into %s (%s) 
 		values %s 
 		on conflict (%s) 
 			do update set %s`
-	DeleteQuery        string = `delete from %s where %s`
+	// Deprecated: BulkDelete build its statement per dialect with bound
+	// values, see deleteQuery in bulk.go.
+	DeleteQuery string = `delete from %s where %s`
+	// Deprecated: values are bound, not inlined, see bulk.go.
 	RawVarcharTemplate string = `%s%s%s`
-	ExcludedQuery      string = ` "%s" = excluded."%s" `
+	// Deprecated: postgres only, see upsertQuery in bulk.go.
+	ExcludedQuery string = ` "%s" = excluded."%s" `
 )
 
 type (
@@ -40,31 +43,70 @@ type (
 		Set(string, interface{}) ORM
 		Error() error
 
+		// WithContext return an ORM running every statement with ctx, so
+		// cancelling ctx or reaching its deadline abort the running query.
+		// The conditions chained so far are kept.
+		WithContext(context.Context) ORM
+		// Context return the ctx bound by WithContext, context.Background
+		// when unbound.
+		Context() context.Context
+
 		Where(interface{}, ...interface{}) ORM
 		First(interface{}) error
+		FirstWithContext(context.Context, interface{}) error
 		All(interface{}) error
+		AllWithContext(context.Context, interface{}) error
+		// Count return the rows matching the conditions, in the table of the
+		// given model or results when Table was not called.
+		Count(interface{}) (int64, error)
 		Order(interface{}) ORM
 		Limit(interface{}) ORM
 		Offset(interface{}) ORM
 
 		Create(interface{}) error
+		// Update save every column of the object. When its model has a
+		// version column, the row is only updated if its version did not
+		// change and the version is incremented, else ErrStaleObject.
 		Update(interface{}) error
 		Delete(interface{}) error
-		BulkDelete(string, []interface{}) error
+		// BulkDelete delete rows by their key columns, the primary key when no
+		// column is given. It does not commit, call it on a Begin ORM to
+		// delete every chunk atomically.
+		BulkDelete(string, []interface{}, ...string) error
 		SoftDelete(interface{}) error
 
 		// Exec is used to execute sql Create, Update or Delete
 		Exec(string, ...interface{}) error
+		ExecWithContext(context.Context, string, ...interface{}) error
 
 		// RawSql is used to execute Select
 		RawSqlWithObject(string, interface{}, ...interface{}) error
 		RawSql(string, ...interface{}) (*sql.Rows, error)
-
-		//Bulk Upsert
-		BulkUpsert(string, int, []interface{}) error
-
-		//Search
+		RawSqlWithContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+
+		// BulkUpsert insert rows by statements of chunk size rows, rows whose
+		// primary key exist are updated. The chunk size is capped by the
+		// placeholders the dialect can bind, zero use the cap. It return the
+		// rows affected, mysql count 2 for an updated row. Rows with a
+		// version column are only updated when their version match, see
+		// ErrStaleObject. The chunks written before a stale one stay
+		// applied, so versioned rows must be upserted within
+		// WithTransaction. Mysql does not report which rows are stale, so
+		// there versioned rows are upserted one per statement.
+		BulkUpsert(string, int, []interface{}) (int64, error)
+
+		// Search select the rows of a table matching every criteria, with
+		// the Query of NewQuery. The table and fields are quoted with the
+		// dialect, so postgres match them case-sensitively: a mixed-case
+		// name created unquoted is folded to lower case and must be given
+		// in lower case.
 		Search(string, []string, []Criteria, interface{}) error
+		SearchWithContext(context.Context, string, []string, []Criteria, interface{}) error
+
+		// SearchQuery find the rows of the query and return the total of rows
+		// matching its conditions, regardless of its limit and offset.
+		SearchQuery(*Query, interface{}) (int64, error)
+		SearchQueryWithContext(context.Context, *Query, interface{}) (int64, error)
 
 		HasTable(string) bool
 
@@ -76,8 +118,12 @@ type (
 
 		Table(string) ORM
 		Begin() ORM
+		// BeginTx begin a transaction with the isolation level and read only
+		// mode of opts, nil use the database defaults.
+		BeginTx(*sql.TxOptions) ORM
 		Commit() error
 		Rollback() error
+		InTransaction() bool
 
 		UnderlyingDB() *sql.DB
 	}
@@ -87,17 +133,30 @@ type (
 		Err      error
 		Logger   logs.Logger
 		DB       *sql.DB
+		LogMode  bool
+
+		ctx   context.Context
+		hooks []QueryHook
+
+		// scopes replay the chained conditions when the connection is bound
+		// to a context.
+		scopes []func(*gorm.DB) *gorm.DB
 	}
 
+	// singularProbe is the model of singularTable.
+	singularProbe struct{}
+
 	Option struct {
 		MaxIdleConnection, MaxOpenConnection int
 		ConnMaxLifetime                      time.Duration
 		LogMode                              bool
+		// Hooks observe every statement, see NewSlowQueryLog.
+		Hooks []QueryHook
 	}
 )
 
 func (o *Impl) Ping() error {
-	return o.Database.DB().Ping()
+	return o.DB.PingContext(o.Context())
 }
 
 func (o *Impl) Close() error {
@@ -109,17 +168,43 @@ func (o *Impl) Close() error {
 }
 
 func (o *Impl) Set(key string, value interface{}) ORM {
-	db := o.Database.Set(key, value)
-	return &Impl{Database: db, Err: db.Error, Logger: o.Logger, DB: db.DB()}
+	return o.chain(func(db *gorm.DB) *gorm.DB {
+		return db.Set(key, value)
+	})
 }
 
 func (o *Impl) Error() error {
 	return o.Err
 }
 
+func (o *Impl) WithContext(ctx context.Context) ORM {
+	bound := o.bind(ctx)
+	bound.ctx = ctx
+
+	return bound
+}
+
+func (o *Impl) Context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+
+	return o.ctx
+}
+
+// Use return an ORM running every statement between hooks, after the hooks
+// already used. Transactions begun from it use them too.
+func (o *Impl) Use(hooks ...QueryHook) ORM {
+	copied := o.clone(o.Database, o.scopes)
+	copied.hooks = append(append(make([]QueryHook, 0, len(o.hooks)+len(hooks)), o.hooks...), hooks...)
+
+	return copied.bind(o.Context())
+}
+
 func (o *Impl) Where(query interface{}, args ...interface{}) ORM {
-	db := o.Database.Where(query, args...)
-	return &Impl{Database: db, Err: db.Error, Logger: o.Logger, DB: db.DB()}
+	return o.chain(func(db *gorm.DB) *gorm.DB {
+		return db.Where(query, args...)
+	})
 }
 
 func (o *Impl) First(object interface{}) error {
@@ -136,6 +221,10 @@ func (o *Impl) First(object interface{}) error {
 	return nil
 }
 
+func (o *Impl) FirstWithContext(ctx context.Context, object interface{}) error {
+	return o.WithContext(ctx).First(object)
+}
+
 func (o *Impl) All(object interface{}) error {
 	res := o.Database.Find(object)
 
@@ -146,19 +235,36 @@ func (o *Impl) All(object interface{}) error {
 	return nil
 }
 
+func (o *Impl) AllWithContext(ctx context.Context, object interface{}) error {
+	return o.WithContext(ctx).All(object)
+}
+
+func (o *Impl) Count(model interface{}) (int64, error) {
+	var total int64
+
+	if err := o.Database.Model(model).Count(&total).Error; err != nil {
+		return 0, errors.Wrapf(err, "failed to count %s", model)
+	}
+
+	return total, nil
+}
+
 func (o *Impl) Order(args interface{}) ORM {
-	db := o.Database.Order(args)
-	return &Impl{Database: db, Err: db.Error, Logger: o.Logger, DB: db.DB()}
+	return o.chain(func(db *gorm.DB) *gorm.DB {
+		return db.Order(args)
+	})
 }
 
 func (o *Impl) Limit(args interface{}) ORM {
-	db := o.Database.Limit(args)
-	return &Impl{Database: db, Err: db.Error, Logger: o.Logger, DB: db.DB()}
+	return o.chain(func(db *gorm.DB) *gorm.DB {
+		return db.Limit(args)
+	})
 }
 
 func (o *Impl) Offset(args interface{}) ORM {
-	db := o.Database.Offset(args)
-	return &Impl{Database: db, Err: db.Error, Logger: o.Logger, DB: db.DB()}
+	return o.chain(func(db *gorm.DB) *gorm.DB {
+		return db.Offset(args)
+	})
 }
 
 func (o *Impl) Create(object interface{}) error {
@@ -172,6 +278,11 @@ func (o *Impl) Create(object interface{}) error {
 }
 
 func (o *Impl) Update(object interface{}) error {
+	scope := o.Database.NewScope(object)
+	if version, ok := versionField(scope); ok && !scope.PrimaryKeyZero() {
+		return o.updateVersion(scope, version)
+	}
+
 	res := o.Database.Save(object)
 
 	if err := res.Error; err != nil {
@@ -191,138 +302,34 @@ func (o *Impl) Delete(object interface{}) error {
 	return nil
 }
 
-func (o *Impl) BulkDelete(tableName string, bulkData []interface{}) error {
-
+func (o *Impl) BulkDelete(tableName string, bulkData []interface{}, keys ...string) error {
 	if len(bulkData) == 0 {
 		return errors.New("Bulk delete cannot empty")
 	}
 
-	deleteData := make([]map[string]interface{}, 0)
-
-	var err error
-
-	for i := 0; i < len(bulkData); i++ {
-		data := bulkData[i]
-
-		values := reflect.ValueOf(data)
-		fields := reflect.TypeOf(data)
-
-		fieldNum := reflect.TypeOf(data).NumField()
-
-		temp := make(map[string]interface{})
-		for i := 0; i < fieldNum; i++ {
-			isPrimary := false
-			name := fields.Field(i).Name
-			if tag, ok := fields.Field(i).Tag.Lookup("gorm"); ok {
-				tagParam := strings.Split(tag, ";")
-				for _, param := range tagParam {
-					paramMap := strings.Split(param, ":")
-					if len(paramMap) == 2 {
-						if paramMap[0] == "column" {
-							name = paramMap[1]
-						}
-					} else if len(paramMap) == 1 {
-						if paramMap[0] == "primary_key" {
-							isPrimary = true
-						}
-					}
-				}
-			}
-
-			if isPrimary {
-				temp[name] = values.Field(i).Interface()
-			}
-		}
-
-		deleteData = append(deleteData, temp)
+	columns, rows, err := o.bulkRows(bulkData)
+	if err != nil {
+		return errors.Wrap(err, "error on bulk delete")
 	}
 
-	if len(deleteData) > 0 {
-		err = o.constructBulkDeleteQuery(tableName, deleteData)
-		if err != nil {
-			err = errors.Wrap(err, "error on bulk delete")
-		}
+	query, values, err := o.deleteQuery(tableName, columns, rows, keys)
+	if err != nil {
+		return errors.Wrap(err, "error on bulk delete")
 	}
 
-	return err
-}
-
-func (o *Impl) constructBulkDeleteQuery(tableName string, deleteData []map[string]interface{}) error {
-
-	numericType := map[string]bool{
-		"int8":       true,
-		"uint8":      true,
-		"int16":      true,
-		"uint16":     true,
-		"int32":      true,
-		"uint32":     true,
-		"int64":      true,
-		"uint64":     true,
-		"int":        true,
-		"uint":       true,
-		"uintptr":    true,
-		"float32":    true,
-		"float64":    true,
-		"complex64":  true,
-		"complex128": true,
-		"bool":       true,
-	}
-
-	deleteValues := make([]string, 0)
-
-	for _, row := range deleteData {
-		data := "("
-
-		fieldNum := len(row)
-		countField := 0
-		for name, value := range row {
-			if name == "created_date" || name == "updated_date" {
-				fieldNum = fieldNum - 1
-				continue
-			}
-
-			data += `"` + name + `" = `
-
-			typeName := reflect.TypeOf(value).Name()
-
-			if _, ok := numericType[typeName]; ok {
-				data += fmt.Sprintf("%v", value)
-			} else {
-				if typeName == "Time" {
-					value = value.(time.Time).Format("2006-01-02 15:04:05.999999")
-				} else if typeName == "Jsonb" {
-
-					jsonBData, _ := json.Marshal(value)
-
-					value = fmt.Sprintf("%v", strings.ReplaceAll(string(jsonBData), "'", "`"))
-				}
-
-				if value == nil {
-					data += fmt.Sprintf("%v", value)
-				} else {
-					data += fmt.Sprintf("%s%v%s", `'`, value, `'`)
-				}
-			}
-
-			if countField < fieldNum-1 {
-				data += " and "
-			}
-			countField++
+	chunk := o.bulkChunk(0, len(values[0]))
+	for start := 0; start < len(values); start += chunk {
+		end := start + chunk
+		if end > len(values) {
+			end = len(values)
 		}
-		data += ")"
 
-		deleteValues = append(deleteValues, data)
+		if err := o.Database.Exec(query, bulkKeys(values[start:end])).Error; err != nil {
+			return errors.Wrapf(err, "error on bulk delete of rows %d to %d", start, end-1)
+		}
 	}
 
-	insertQuery := strings.Join(deleteValues, " or ")
-
-	bulkQuery := fmt.Sprintf(DeleteQuery, tableName, insertQuery)
-
-	err := o.Exec(bulkQuery)
-
-	o.Commit()
-
-	return err
+	return nil
 }
 
 func (o *Impl) SoftDelete(object interface{}) error {
@@ -336,9 +343,24 @@ func (o *Impl) SoftDelete(object interface{}) error {
 }
 
 func (o *Impl) Begin() ORM {
-	copied := o.Database.Begin()
-	return &Impl{Database: copied, Err: copied.Error, Logger: o.Logger, DB: copied.DB()}
+	return o.BeginTx(nil)
+}
+
+func (o *Impl) BeginTx(opts *sql.TxOptions) ORM {
+	copied := o.Database.BeginTx(o.Context(), opts)
+	tx := o.clone(copied, o.scopes)
+
+	// - statements of the transaction run with the context it began with
+	//   and between the hooks
+	if o.ctx != nil && copied.Error == nil {
+		return tx.WithContext(o.ctx)
+	}
 
+	if len(o.hooks) > 0 && copied.Error == nil {
+		return tx.bind(o.Context())
+	}
+
+	return tx
 }
 
 func (o *Impl) Rollback() error {
@@ -361,6 +383,15 @@ func (o *Impl) Commit() error {
 	return nil
 }
 
+func (o *Impl) InTransaction() bool {
+	switch o.Database.CommonDB().(type) {
+	case *sql.Tx, *contextTx:
+		return true
+	}
+
+	return false
+}
+
 func (o *Impl) Exec(sql string, args ...interface{}) error {
 	res := o.Database.Exec(sql, args...)
 
@@ -371,6 +402,10 @@ func (o *Impl) Exec(sql string, args ...interface{}) error {
 	return nil
 }
 
+func (o *Impl) ExecWithContext(ctx context.Context, sql string, args ...interface{}) error {
+	return o.WithContext(ctx).Exec(sql, args...)
+}
+
 func (o *Impl) RawSqlWithObject(sql string, object interface{}, args ...interface{}) error {
 	res := o.Database.Raw(sql, args...).Scan(object)
 
@@ -385,25 +420,29 @@ func (o *Impl) RawSql(sql string, args ...interface{}) (*sql.Rows, error) {
 	return o.Database.Raw(sql, args...).Rows()
 }
 
+func (o *Impl) RawSqlWithContext(ctx context.Context, sql string, args ...interface{}) (*sql.Rows, error) {
+	return o.WithContext(ctx).RawSql(sql, args...)
+}
+
 func (o *Impl) Table(tableName string) ORM {
-	copied := o.Database.Table(tableName)
-	return &Impl{Database: copied, Err: copied.Error, Logger: o.Logger, DB: copied.DB()}
+	return o.chain(func(db *gorm.DB) *gorm.DB {
+		return db.Table(tableName)
+	})
 }
 
 func (o *Impl) Search(tableName string, selectField []string, criteria []Criteria, results interface{}) error {
-	var (
-		db = o.Database.Table(tableName)
-	)
+	query := NewQuery(tableName).Select(selectField...)
 
-	if len(selectField) > 0 {
-		db = db.Select(selectField)
+	for _, crit := range criteria {
+		query.Where(Cond(crit.Field, crit.Operator, crit.Value))
 	}
 
-	for _, crit := range criteria {
-		db = db.Where(crit.Field+" "+crit.Operator+" (?)", crit.Value)
+	if err := query.Err(); err != nil {
+		return errors.Wrap(err, "invalid search")
 	}
 
-	res := db.Find(results)
+	filter, page := query.Scopes()
+	res := o.Database.Scopes(filter, page).Find(results)
 
 	if err := res.Error; err != nil {
 		return errors.Wrapf(err, "failed to query %s", results)
@@ -412,186 +451,80 @@ func (o *Impl) Search(tableName string, selectField []string, criteria []Criteri
 	return nil
 }
 
-func (o *Impl) BulkUpsert(tableName string, chunkSize int, bulkData []interface{}) error {
-
-	insertData := make([]map[string]interface{}, 0)
-
-	fieldNames := make([]string, 0)
-	primaryField := make([]string, 0)
-	excludeField := make([]string, 0)
-	shouldGetFieldName := true
-
-	var err error
-
-	for i := 0; i < len(bulkData); i++ {
-
-		if len(insertData) >= chunkSize {
-			//go func bulk upsert background
-
-			itErr := o.constructBulkSearchQuery(tableName, fieldNames, primaryField, excludeField, insertData)
-			if itErr != nil {
-				if err == nil {
-					err = itErr
-				} else {
-					err = errors.New(err.Error() + "/n" + itErr.Error())
-				}
-			}
-			insertData = make([]map[string]interface{}, 0)
-		}
+func (o *Impl) SearchWithContext(ctx context.Context, tableName string, selectField []string, criteria []Criteria, results interface{}) error {
+	return o.WithContext(ctx).Search(tableName, selectField, criteria, results)
+}
 
-		data := bulkData[i]
-
-		values := reflect.ValueOf(data)
-		fields := reflect.TypeOf(data)
-
-		fieldNum := reflect.TypeOf(data).NumField()
-
-		temp := make(map[string]interface{})
-		for i := 0; i < fieldNum; i++ {
-			name := fields.Field(i).Name
-			isPrimary := false
-			if tag, ok := fields.Field(i).Tag.Lookup("gorm"); ok {
-				tagParam := strings.Split(tag, ";")
-				for _, param := range tagParam {
-					paramMap := strings.Split(param, ":")
-					if len(paramMap) == 2 {
-						if paramMap[0] == "column" {
-							name = paramMap[1]
-						}
-					} else if len(paramMap) == 1 {
-						if paramMap[0] == "primary_key" {
-							isPrimary = true
-						}
-					}
-				}
-			}
-
-			if shouldGetFieldName {
-				fieldNames = append(fieldNames, name)
-				if isPrimary {
-					primaryField = append(primaryField, name)
-				} else {
-					excludeField = append(excludeField, name)
-				}
-			}
-			temp[name] = values.Field(i).Interface()
-		}
+func (o *Impl) SearchQuery(query *Query, results interface{}) (int64, error) {
+	if err := query.Err(); err != nil {
+		return 0, errors.Wrap(err, "invalid search")
+	}
 
-		if shouldGetFieldName {
-			shouldGetFieldName = false
-		}
+	var (
+		total        int64
+		filter, page = query.Scopes()
+	)
 
-		insertData = append(insertData, temp)
+	// - counting the results model exclude soft deleted rows as Find does
+	if err := o.Database.Scopes(filter).Model(results).Count(&total).Error; err != nil {
+		return 0, errors.Wrapf(err, "failed to count %s", results)
 	}
 
-	//bulk insert the rest
-	if len(insertData) > 0 {
-		itErr := o.constructBulkSearchQuery(tableName, fieldNames, primaryField, excludeField, insertData)
-		if itErr != nil {
-			if err == nil {
-				err = itErr
-			} else {
-				err = errors.New(err.Error() + "/n" + itErr.Error())
-			}
-		}
+	if err := o.Database.Scopes(filter, page).Find(results).Error; err != nil {
+		return 0, errors.Wrapf(err, "failed to query %s", results)
 	}
 
-	return errors.Wrap(err, "error on bulk insert")
+	return total, nil
 }
 
-func (o *Impl) constructBulkSearchQuery(tableName string, fieldNames, primaryField, excludeField []string, data []map[string]interface{}) error {
-
-	numericType := map[string]bool{
-		"int8":       true,
-		"uint8":      true,
-		"int16":      true,
-		"uint16":     true,
-		"int32":      true,
-		"uint32":     true,
-		"int64":      true,
-		"uint64":     true,
-		"int":        true,
-		"uint":       true,
-		"uintptr":    true,
-		"float32":    true,
-		"float64":    true,
-		"complex64":  true,
-		"complex128": true,
-		"bool":       true,
-	}
-
-	insertValues := make([]string, 0)
+func (o *Impl) SearchQueryWithContext(ctx context.Context, query *Query, results interface{}) (int64, error) {
+	return o.WithContext(ctx).SearchQuery(query, results)
+}
 
-	fieldQuery := ""
-	for k, name := range fieldNames {
-		fieldQuery += fmt.Sprintf(RawVarcharTemplate, `"`, name, `"`)
-		if k < len(fieldNames)-1 {
-			fieldQuery += ", "
-		}
+func (o *Impl) BulkUpsert(tableName string, chunkSize int, bulkData []interface{}) (int64, error) {
+	if len(bulkData) == 0 {
+		return 0, nil
 	}
 
-	primaryQuery := ""
-	for k, name := range primaryField {
-		primaryQuery += fmt.Sprintf(RawVarcharTemplate, `"`, name, `"`)
-		if k < len(primaryField)-1 {
-			primaryQuery += ", "
-		}
+	columns, rows, err := o.bulkRows(bulkData)
+	if err != nil {
+		return 0, errors.Wrap(err, "error on bulk upsert")
 	}
 
-	conflictQuery := ""
-	for k, name := range excludeField {
-		conflictQuery += fmt.Sprintf(ExcludedQuery, name, name)
-		if k < len(excludeField)-1 {
-			conflictQuery += ", "
-		}
+	query, err := o.upsertQuery(tableName, columns)
+	if err != nil {
+		return 0, errors.Wrap(err, "error on bulk upsert")
 	}
 
-	for _, row := range data {
-
-		data := "("
-
-		fieldNum := len(row)
-		countField := 0
-		for _, value := range fieldNames {
-
-			typeName := reflect.TypeOf(row[value]).Name()
-
-			if _, ok := numericType[typeName]; ok {
-				data += fmt.Sprintf("%v", row[value])
-			} else {
-				if typeName == "Time" {
-					row[value] = row[value].(time.Time).Format("2006-01-02 15:04:05.999999")
-				} else if typeName == "Jsonb" {
-
-					jsonBData, _ := json.Marshal(row[value])
+	var (
+		affected int64
+		chunk    = o.bulkChunk(chunkSize, len(columns))
+	)
 
-					row[value] = fmt.Sprintf("%v", strings.ReplaceAll(string(jsonBData), "'", "`"))
-				}
+	// - mysql count a stale row 0 and an updated one 2, only a statement
+	//   of a single row tell its stale rows
+	if hasVersion(columns) && o.Database.Dialect().GetName() == "mysql" {
+		chunk = 1
+	}
 
-				if row[value] == nil {
-					data += fmt.Sprintf("%v", row[value])
-				} else {
-					data += fmt.Sprintf("%s%v%s", `'`, row[value], `'`)
-				}
-			}
+	for start := 0; start < len(rows); start += chunk {
+		end := start + chunk
+		if end > len(rows) {
+			end = len(rows)
+		}
 
-			if countField < fieldNum-1 {
-				data += ", "
-			}
-			countField++
+		res := o.Database.Exec(query, rows[start:end])
+		if err := res.Error; err != nil {
+			return affected, errors.Wrapf(err, "error on bulk upsert of rows %d to %d", start, end-1)
 		}
-		data += ")"
+		affected += res.RowsAffected
 
-		insertValues = append(insertValues, data)
+		if o.bulkStale(columns, end-start, res.RowsAffected) {
+			return affected, &ErrStaleObject{Table: tableName}
+		}
 	}
 
-	insertQuery := strings.Join(insertValues, ", \n")
-
-	bulkQuery := fmt.Sprintf(UpsertQuery, tableName, fieldQuery, insertQuery, primaryQuery, conflictQuery)
-
-	err := o.Exec(bulkQuery)
-
-	return err
+	return affected, nil
 }
 
 func (o *Impl) CreateTable(data interface{}) error {
@@ -617,3 +550,78 @@ func (o *Impl) HasTable(tableName string) bool {
 func (o *Impl) UnderlyingDB() *sql.DB {
 	return o.DB
 }
+
+// - private
+
+// chain apply scope to the current db and keep it so the condition survive
+// WithContext.
+func (o *Impl) chain(scope func(*gorm.DB) *gorm.DB) ORM {
+	scopes := make([]func(*gorm.DB) *gorm.DB, 0, len(o.scopes)+1)
+	scopes = append(scopes, o.scopes...)
+
+	return o.clone(scope(o.Database), append(scopes, scope))
+}
+
+// clone keep the pool of o, gorm DB() panic once the connection is a
+// transaction or is bound to a context.
+func (o *Impl) clone(db *gorm.DB, scopes []func(*gorm.DB) *gorm.DB) *Impl {
+	return &Impl{
+		Database: db,
+		Err:      db.Error,
+		Logger:   o.Logger,
+		DB:       o.DB,
+		LogMode:  o.LogMode,
+		ctx:      o.ctx,
+		hooks:    o.hooks,
+		scopes:   scopes,
+	}
+}
+
+// bind reopen the connection with its statements run with ctx and between
+// the hooks, gorm has no setter for the connection of a DB. The logger, the
+// log mode, SingularTable and BlockGlobalUpdate of o are kept and the
+// chained conditions are replayed. Callbacks are those of
+// gorm.DefaultCallback, the ones registered on the connection itself are
+// not kept.
+func (o *Impl) bind(ctx context.Context) *Impl {
+	dialect := o.Database.Dialect().GetName()
+	h := hooks{dialect: dialect, list: o.hooks}
+
+	common, err := bind(ctx, o.Database.CommonDB(), h)
+	if err != nil {
+		db := o.Database.New()
+		db.AddError(err)
+		return o.clone(db, o.scopes)
+	}
+
+	db, err := gorm.Open(dialect, common)
+	if err != nil {
+		db = o.Database.New()
+		db.AddError(errors.Wrap(err, "failed to bind context"))
+		return o.clone(db, o.scopes)
+	}
+
+	if o.Logger != nil {
+		db.SetLogger(o.Logger)
+	}
+	db.LogMode(o.LogMode)
+	db.SingularTable(singularTable(o.Database))
+	db.BlockGlobalUpdate(o.Database.HasBlockGlobalUpdate())
+
+	// - the gorm create, update and delete report to the hooks of the
+	//   bound connection, see hookCallbacks
+	db = db.Set(hookSetting, &hookState{ctx: ctx, hooks: h})
+
+	for _, scope := range o.scopes {
+		db = scope(db)
+	}
+
+	return o.clone(db, o.scopes)
+}
+
+// singularTable return true if db name the tables of its models singular,
+// gorm has no getter but cache the models per SingularTable, so the table
+// of a model only used here tell it.
+func singularTable(db *gorm.DB) bool {
+	return db.New().NewScope(&singularProbe{}).TableName() == "singular_probe"
+}
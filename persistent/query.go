@@ -0,0 +1,469 @@
+package persistent
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	Asc  = "ASC"
+	Desc = "DESC"
+
+	// likeEscape escape the wildcards of Contains, StartsWith and EndsWith,
+	// backslash is not portable since mysql string literals use it too.
+	likeEscape = "!"
+)
+
+var (
+	// identifier is a column, or a table, optionally qualified by its table.
+	identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+	// operators accepted by Cond and Criteria and their SQL.
+	operators = map[string]string{
+		"=":         "= ?",
+		"!=":        "<> ?",
+		"<>":        "<> ?",
+		"<":         "< ?",
+		"<=":        "<= ?",
+		">":         "> ?",
+		">=":        ">= ?",
+		"in":        "IN (?)",
+		"not in":    "NOT IN (?)",
+		"like":      "LIKE ?",
+		"not like":  "NOT LIKE ?",
+		"ilike":     "ILIKE ?",
+		"not ilike": "NOT ILIKE ?",
+	}
+
+	likeReplacer = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+)
+
+type (
+	// Condition is a node of a where clause, a comparison of a field built
+	// with Cond, Eq, In, IsNull... or a group of conditions built with And
+	// and Or.
+	Condition struct {
+		field    string
+		sql      string
+		values   []interface{}
+		group    string
+		children []*Condition
+		// all is set on the conditions matching every row, like NotIn of an
+		// empty slice.
+		all bool
+		err error
+	}
+
+	// Query is a composable select, fields and tables are validated when
+	// added and quoted with the dialect, so they are case-sensitive on
+	// postgres, values are always bound. Allow restrict the fields the query
+	// can reference, typically to the fields a list endpoint expose.
+	//
+	//	query := persistent.NewQuery("orders").
+	//		Allow("status", "total", "created_at").
+	//		Where(persistent.Or(persistent.Eq("status", "paid"), persistent.IsNull("status"))).
+	//		Where(persistent.Between("total", 10, 100)).
+	//		OrderBy("created_at", persistent.Desc).
+	//		Limit(20).Offset(40)
+	//
+	//	total, err := orm.SearchQuery(query, &orders)
+	Query struct {
+		table   string
+		allowed map[string]bool
+		fields  []string
+		selects []string
+		joins   []join
+		where   []*Condition
+		orders  []string
+		limit   int
+		offset  int
+		err     error
+	}
+
+	join struct {
+		kind, table, left, right string
+	}
+)
+
+// Cond compare field to value with operator, one of =, !=, <>, <, <=, >,
+// >=, in, not in, like, not like, ilike and not ilike.
+func Cond(field, operator string, value interface{}) *Condition {
+	key := strings.ToLower(strings.TrimSpace(operator))
+
+	sql, ok := operators[key]
+	if !ok {
+		return &Condition{err: errors.Errorf("operator %q is not allowed", operator)}
+	}
+
+	return &Condition{field: field, sql: sql, values: []interface{}{value}, all: key == "not in" && empty(value)}
+}
+
+func Eq(field string, value interface{}) *Condition {
+	return Cond(field, "=", value)
+}
+
+func Ne(field string, value interface{}) *Condition {
+	return Cond(field, "<>", value)
+}
+
+func Gt(field string, value interface{}) *Condition {
+	return Cond(field, ">", value)
+}
+
+func Gte(field string, value interface{}) *Condition {
+	return Cond(field, ">=", value)
+}
+
+func Lt(field string, value interface{}) *Condition {
+	return Cond(field, "<", value)
+}
+
+func Lte(field string, value interface{}) *Condition {
+	return Cond(field, "<=", value)
+}
+
+// In match the values of a slice, an empty slice match no row.
+func In(field string, values interface{}) *Condition {
+	return Cond(field, "in", values)
+}
+
+// NotIn exclude the values of a slice, an empty slice exclude nothing so
+// the condition is left out.
+func NotIn(field string, values interface{}) *Condition {
+	return Cond(field, "not in", values)
+}
+
+func IsNull(field string) *Condition {
+	return &Condition{field: field, sql: "IS NULL"}
+}
+
+func NotNull(field string) *Condition {
+	return &Condition{field: field, sql: "IS NOT NULL"}
+}
+
+// Between match from and to inclusive.
+func Between(field string, from, to interface{}) *Condition {
+	return &Condition{field: field, sql: "BETWEEN ? AND ?", values: []interface{}{from, to}}
+}
+
+// Like match a pattern, % and _ are wildcards.
+func Like(field, pattern string) *Condition {
+	return Cond(field, "like", pattern)
+}
+
+// Contains match the values containing s, wildcards in s are matched
+// literally.
+func Contains(field, s string) *Condition {
+	return escapedLike(field, "%"+likeReplacer.Replace(s)+"%")
+}
+
+func StartsWith(field, s string) *Condition {
+	return escapedLike(field, likeReplacer.Replace(s)+"%")
+}
+
+func EndsWith(field, s string) *Condition {
+	return escapedLike(field, "%"+likeReplacer.Replace(s))
+}
+
+// And match when every condition match.
+func And(conditions ...*Condition) *Condition {
+	return &Condition{group: "AND", children: conditions}
+}
+
+// Or match when any condition match.
+func Or(conditions ...*Condition) *Condition {
+	return &Condition{group: "OR", children: conditions}
+}
+
+func NewQuery(table string) *Query {
+	q := &Query{table: table}
+
+	if !identifier.MatchString(table) {
+		q.err = errors.Errorf("table %q is not allowed", table)
+	}
+
+	return q
+}
+
+// Allow restrict the fields of the query to fields, every field the query
+// reference is checked by Err, before or after Allow.
+func (q *Query) Allow(fields ...string) *Query {
+	if q.allowed == nil {
+		q.allowed = make(map[string]bool, len(fields))
+	}
+
+	for _, field := range fields {
+		q.allowed[field] = true
+	}
+
+	return q
+}
+
+// Select the fields to return, every field when empty.
+func (q *Query) Select(fields ...string) *Query {
+	for _, field := range fields {
+		q.check(field)
+	}
+	q.selects = append(q.selects, fields...)
+
+	return q
+}
+
+// Where add conditions, the conditions of every call are ANDed.
+func (q *Query) Where(conditions ...*Condition) *Query {
+	for _, condition := range conditions {
+		q.checkCondition(condition)
+	}
+	q.where = append(q.where, conditions...)
+
+	return q
+}
+
+// Join inner join table on left = right, left and right are qualified
+// fields.
+//
+//	query.Join("users", "users.id", "orders.user_id")
+func (q *Query) Join(table, left, right string) *Query {
+	return q.addJoin("JOIN", table, left, right)
+}
+
+func (q *Query) LeftJoin(table, left, right string) *Query {
+	return q.addJoin("LEFT JOIN", table, left, right)
+}
+
+// OrderBy sort by field in direction, Asc or Desc.
+func (q *Query) OrderBy(field, direction string) *Query {
+	direction = strings.ToUpper(direction)
+	if direction != Asc && direction != Desc {
+		q.fail(errors.Errorf("direction %q is not allowed", direction))
+		return q
+	}
+
+	q.check(field)
+	q.orders = append(q.orders, field+" "+direction)
+
+	return q
+}
+
+func (q *Query) Limit(limit int) *Query {
+	q.limit = limit
+	return q
+}
+
+func (q *Query) Offset(offset int) *Query {
+	q.offset = offset
+	return q
+}
+
+// Err return the first invalid field, table or operator of the query, then
+// the first field not allowed.
+func (q *Query) Err() error {
+	if q.err != nil || q.allowed == nil {
+		return q.err
+	}
+
+	for _, field := range q.fields {
+		if !q.allowed[field] {
+			return errors.Errorf("field %q is not allowed", field)
+		}
+	}
+
+	return nil
+}
+
+// Scopes compile the query to gorm scopes. filter apply the table, the
+// joins and the conditions, and page apply the selected fields, the order,
+// the limit and the offset, so filter alone count the matching rows. Check
+// Err first, the scopes of an invalid query apply nothing.
+func (q *Query) Scopes() (filter, page func(*gorm.DB) *gorm.DB) {
+	filter = func(db *gorm.DB) *gorm.DB {
+		if q.Err() != nil {
+			return db
+		}
+
+		quote := quoter(db)
+		db = db.Table(q.table)
+
+		for _, j := range q.joins {
+			db = db.Joins(fmt.Sprintf("%s %s ON %s = %s", j.kind, quote(j.table), quote(j.left), quote(j.right)))
+		}
+
+		for _, condition := range q.where {
+			sql, values := condition.build(quote)
+			if sql != "" {
+				db = db.Where(sql, values...)
+			}
+		}
+
+		return db
+	}
+
+	page = func(db *gorm.DB) *gorm.DB {
+		if q.Err() != nil {
+			return db
+		}
+
+		quote := quoter(db)
+
+		if len(q.selects) > 0 {
+			fields := make([]string, 0, len(q.selects))
+			for _, field := range q.selects {
+				fields = append(fields, quote(field))
+			}
+			db = db.Select(fields)
+		}
+
+		for _, order := range q.orders {
+			i := strings.LastIndex(order, " ")
+			db = db.Order(quote(order[:i]) + order[i:])
+		}
+
+		if q.limit > 0 {
+			db = db.Limit(q.limit)
+		}
+
+		if q.offset > 0 {
+			db = db.Offset(q.offset)
+		}
+
+		return db
+	}
+
+	return filter, page
+}
+
+// - private
+
+func escapedLike(field, pattern string) *Condition {
+	return &Condition{field: field, sql: "LIKE ? ESCAPE '" + likeEscape + "'", values: []interface{}{pattern}}
+}
+
+func (q *Query) addJoin(kind, table, left, right string) *Query {
+	if !identifier.MatchString(table) {
+		q.fail(errors.Errorf("table %q is not allowed", table))
+		return q
+	}
+
+	q.check(left)
+	q.check(right)
+	q.joins = append(q.joins, join{kind: kind, table: table, left: left, right: right})
+
+	return q
+}
+
+// check fail on a field that is not an identifier, the allowed fields are
+// checked by Err once every field is referenced.
+func (q *Query) check(field string) {
+	if !identifier.MatchString(field) {
+		q.fail(errors.Errorf("field %q is not allowed", field))
+		return
+	}
+
+	q.fields = append(q.fields, field)
+}
+
+func (q *Query) checkCondition(c *Condition) {
+	if c == nil {
+		q.fail(errors.New("condition is nil"))
+		return
+	}
+
+	if c.err != nil {
+		q.fail(c.err)
+		return
+	}
+
+	if c.group != "" {
+		for _, child := range c.children {
+			q.checkCondition(child)
+		}
+		return
+	}
+
+	q.check(c.field)
+}
+
+func (q *Query) fail(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// build return the SQL of the condition and its values, a condition
+// matching every row return an empty SQL.
+func (c *Condition) build(quote func(string) string) (string, []interface{}) {
+	if c.everything() {
+		return "", nil
+	}
+
+	if c.group == "" {
+		return quote(c.field) + " " + c.sql, c.values
+	}
+
+	parts := make([]string, 0, len(c.children))
+	values := make([]interface{}, 0)
+
+	for _, child := range c.children {
+		sql, childValues := child.build(quote)
+		if sql == "" {
+			continue
+		}
+
+		parts = append(parts, sql)
+		values = append(values, childValues...)
+	}
+
+	if len(parts) == 0 {
+		return "", nil
+	}
+
+	return "(" + strings.Join(parts, " "+c.group+" ") + ")", values
+}
+
+// everything return true if c match every row, an empty group does too.
+func (c *Condition) everything() bool {
+	switch c.group {
+	case "":
+		return c.all
+	case "OR":
+		for _, child := range c.children {
+			if child.everything() {
+				return true
+			}
+		}
+		return len(c.children) == 0
+	}
+
+	for _, child := range c.children {
+		if !child.everything() {
+			return false
+		}
+	}
+	return true
+}
+
+// empty return true if value is a slice or an array without element.
+func empty(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	return (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Len() == 0
+}
+
+// quoter quote an identifier with the dialect of db, each part of a
+// qualified identifier is quoted.
+func quoter(db *gorm.DB) func(string) string {
+	dialect := db.Dialect()
+
+	return func(name string) string {
+		parts := strings.Split(name, ".")
+		for i, part := range parts {
+			parts[i] = dialect.Quote(part)
+		}
+
+		return strings.Join(parts, ".")
+	}
+}
@@ -0,0 +1,116 @@
+package persistent
+
+import (
+	"testing"
+
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func Test_ConditionBuild(t *testing.T) {
+	quote := func(name string) string { return `"` + name + `"` }
+
+	t.Run("when conditions are grouped", func(t *testing.T) {
+		sql, values := And(
+			Or(Eq("status", "paid"), IsNull("status")),
+			Between("total", 10, 20),
+			In("id", []int{1, 2}),
+		).build(quote)
+
+		assert2.Equal(t, `(("status" = ? OR "status" IS NULL) AND "total" BETWEEN ? AND ? AND "id" IN (?))`, sql)
+		assert2.Equal(t, []interface{}{"paid", 10, 20, []int{1, 2}}, values)
+	})
+
+	t.Run("when like wildcards are escaped", func(t *testing.T) {
+		sql, values := Contains("note", "50%_off!").build(quote)
+
+		assert2.Equal(t, `"note" LIKE ? ESCAPE '!'`, sql)
+		assert2.Equal(t, []interface{}{"%50!%!_off!!%"}, values)
+	})
+
+	t.Run("when group is empty", func(t *testing.T) {
+		sql, values := Or().build(quote)
+
+		assert2.Empty(t, sql)
+		assert2.Empty(t, values)
+	})
+
+	t.Run("when not in an empty slice, leave the condition out", func(t *testing.T) {
+		sql, values := NotIn("id", []int{}).build(quote)
+		assert2.Empty(t, sql)
+		assert2.Empty(t, values)
+
+		sql, values = And(Eq("status", "paid"), Cond("id", "NOT IN", []string{})).build(quote)
+		assert2.Equal(t, `("status" = ?)`, sql)
+		assert2.Equal(t, []interface{}{"paid"}, values)
+
+		// - any row match the group once one of its conditions match any row
+		sql, _ = Or(Eq("status", "paid"), NotIn("id", [0]int{})).build(quote)
+		assert2.Empty(t, sql)
+
+		sql, values = NotIn("id", []int{1}).build(quote)
+		assert2.Equal(t, `"id" NOT IN (?)`, sql)
+		assert2.Equal(t, []interface{}{[]int{1}}, values)
+	})
+}
+
+func Test_SearchQuery(t *testing.T) {
+	orm := newSqlite(t, &bulkItem{})
+	for id := 1; id <= 3; id++ {
+		assert2.NoError(t, orm.Create(&bulkItem{ID: id}))
+	}
+
+	t.Run("when not in an empty slice, keep every row", func(t *testing.T) {
+		var items []bulkItem
+		total, err := orm.SearchQuery(NewQuery("bulk_items").Where(NotIn("id", []int{})), &items)
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(3), total)
+		assert2.Len(t, items, 3)
+
+		total, err = orm.SearchQuery(NewQuery("bulk_items").Where(NotIn("id", []int{1, 2})), &items)
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(1), total)
+	})
+}
+
+func Test_QueryValidation(t *testing.T) {
+	t.Run("when query is valid", func(t *testing.T) {
+		q := NewQuery("orders").Allow("status", "orders.user_id", "users.id").
+			Join("users", "users.id", "orders.user_id").
+			Where(Eq("status", "paid")).
+			OrderBy("status", "desc")
+
+		assert2.NoError(t, q.Err())
+	})
+
+	t.Run("when field is not allowed", func(t *testing.T) {
+		q := NewQuery("orders").Allow("status").Where(Gt("total", 10))
+		assert2.EqualError(t, q.Err(), `field "total" is not allowed`)
+	})
+
+	t.Run("when field is referenced before Allow", func(t *testing.T) {
+		q := NewQuery("orders").Where(Gt("total", 10)).OrderBy("status", "asc").Allow("status")
+		assert2.EqualError(t, q.Err(), `field "total" is not allowed`)
+
+		q = NewQuery("orders").Where(Gt("total", 10)).Allow("status").Allow("total")
+		assert2.NoError(t, q.Err())
+	})
+
+	t.Run("when field is not an identifier", func(t *testing.T) {
+		q := NewQuery("orders").Select("id, password")
+		assert2.EqualError(t, q.Err(), `field "id, password" is not allowed`)
+	})
+
+	t.Run("when operator is not allowed", func(t *testing.T) {
+		q := NewQuery("orders").Where(Or(Cond("id", "= 1 or 1 =", 1)))
+		assert2.EqualError(t, q.Err(), `operator "= 1 or 1 =" is not allowed`)
+	})
+
+	t.Run("when direction is not allowed", func(t *testing.T) {
+		q := NewQuery("orders").OrderBy("id", "desc; drop table orders")
+		assert2.Error(t, q.Err())
+	})
+
+	t.Run("when table is not an identifier", func(t *testing.T) {
+		assert2.Error(t, NewQuery("orders o").Err())
+	})
+}
@@ -0,0 +1,200 @@
+package persistent
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is the cause of the errors of the lookups matching no row.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+type (
+	// Repository is the CRUD of the rows of table mapped to T, a gorm model
+	// struct. The ORM can be a transaction, or a masterreplica.DB to read
+	// from the replica.
+	//
+	//	orders := persistent.NewRepository[Order](orm, "orders")
+	//	order, err := orders.FindByID(42)
+	Repository[T any] struct {
+		orm   ORM
+		table string
+	}
+
+	// Page is the page of a List and the total of rows matching the query.
+	Page[T any] struct {
+		Items []T   `json:"items"`
+		Total int64 `json:"total"`
+		Page  int   `json:"page"`
+		Size  int   `json:"size"`
+	}
+)
+
+func NewRepository[T any](orm ORM, table string) *Repository[T] {
+	return &Repository[T]{orm: orm, table: table}
+}
+
+// WithTx return the repository of T running on tx, typically within
+// WithTransaction.
+func (r *Repository[T]) WithTx(tx ORM) *Repository[T] {
+	return &Repository[T]{orm: tx, table: r.table}
+}
+
+// FindByID return the row of primary key id, the cause of the error is
+// ErrNotFound when it does not exist.
+func (r *Repository[T]) FindByID(id interface{}) (*T, error) {
+	column, err := primaryColumn(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	entity := new(T)
+	if err := r.orm.Table(r.table).Where(column+" = ?", id).First(entity); err != nil {
+		return nil, err
+	}
+
+	return entity, nil
+}
+
+// FindBy return the rows matching every condition, every row when empty.
+func (r *Repository[T]) FindBy(conditions ...*Condition) ([]T, error) {
+	orm, err := r.where(conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]T, 0)
+	if err := orm.All(&entities); err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *Repository[T]) Create(entity *T) error {
+	return r.orm.Table(r.table).Create(entity)
+}
+
+func (r *Repository[T]) Update(entity *T) error {
+	return r.orm.Table(r.table).Update(entity)
+}
+
+func (r *Repository[T]) Delete(entity *T) error {
+	return r.orm.Table(r.table).Delete(entity)
+}
+
+func (r *Repository[T]) SoftDelete(entity *T) error {
+	return r.orm.Table(r.table).SoftDelete(entity)
+}
+
+// Exists return true if a row match every condition.
+func (r *Repository[T]) Exists(conditions ...*Condition) (bool, error) {
+	total, err := r.Count(conditions...)
+
+	return total > 0, err
+}
+
+// Count return the rows matching every condition.
+func (r *Repository[T]) Count(conditions ...*Condition) (int64, error) {
+	orm, err := r.where(conditions)
+	if err != nil {
+		return 0, err
+	}
+
+	return orm.Count(new(T))
+}
+
+// Query return a query on the table of the repository, to List.
+func (r *Repository[T]) Query() *Query {
+	return NewQuery(r.table)
+}
+
+// List return the page of query, from 1, of size rows and the total of rows
+// matching query. The limit and offset of query are ignored, query is left
+// unchanged so it can list other pages.
+func (r *Repository[T]) List(query *Query, page, size int) (*Page[T], error) {
+	if page < 1 {
+		page = 1
+	}
+
+	if size < 1 {
+		return nil, errors.New("page size must be positive")
+	}
+
+	entities := make([]T, 0, size)
+
+	// - a copy is paged, the conditions are shared but never modified
+	paged := *query
+
+	total, err := r.orm.SearchQuery(paged.Limit(size).Offset((page-1)*size), &entities)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page[T]{Items: entities, Total: total, Page: page, Size: size}, nil
+}
+
+// - private
+
+// where filter the table with conditions, their fields are validated so
+// they are not quoted, the dialect is hidden behind ORM.
+func (r *Repository[T]) where(conditions []*Condition) (ORM, error) {
+	if err := NewQuery(r.table).Where(conditions...).Err(); err != nil {
+		return nil, errors.Wrap(err, "invalid criteria")
+	}
+
+	orm := r.orm.Table(r.table)
+	for _, condition := range conditions {
+		sql, values := condition.build(func(name string) string { return name })
+		if sql != "" {
+			orm = orm.Where(sql, values...)
+		}
+	}
+
+	return orm, nil
+}
+
+// primaryColumn return the column of the primary key of a model, following
+// gorm, the field tagged primary_key or else the ID field.
+func primaryColumn(model reflect.Type) (string, error) {
+	fallback := ""
+
+	for i := 0; i < model.NumField(); i++ {
+		f := model.Field(i)
+
+		column, primary := gorm.ToColumnName(f.Name), false
+		for _, setting := range strings.Split(f.Tag.Get("gorm"), ";") {
+			parts := strings.SplitN(setting, ":", 2)
+			switch strings.ToUpper(strings.TrimSpace(parts[0])) {
+			case "COLUMN":
+				if len(parts) == 2 {
+					column = strings.TrimSpace(parts[1])
+				}
+			case "PRIMARY_KEY":
+				primary = true
+			}
+		}
+
+		if primary {
+			return column, nil
+		}
+
+		if f.Name == "ID" {
+			fallback = column
+		}
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if column, err := primaryColumn(f.Type); err == nil {
+				fallback = column
+			}
+		}
+	}
+
+	if fallback == "" {
+		return "", errors.Errorf("%s has no primary key", model)
+	}
+
+	return fallback, nil
+}
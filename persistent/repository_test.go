@@ -0,0 +1,193 @@
+package persistent
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+type customer struct {
+	gorm.Model
+	Name string
+	Tier string
+}
+
+// newCustomers return the repository of customers named after names, the
+// first ones gold, the others silver.
+func newCustomers(t *testing.T, gold int, names ...string) *Repository[customer] {
+	repo := NewRepository[customer](newSqlite(t, &customer{}), "customers")
+
+	for i, name := range names {
+		tier := "silver"
+		if i < gold {
+			tier = "gold"
+		}
+		assert2.NoError(t, repo.Create(&customer{Name: name, Tier: tier}))
+	}
+
+	return repo
+}
+
+func Test_PrimaryColumn(t *testing.T) {
+	t.Run("when field is tagged primary key", func(t *testing.T) {
+		type order struct {
+			ID     int
+			Number string `gorm:"column:order_number;primary_key"`
+		}
+
+		column, err := primaryColumn(reflect.TypeOf(order{}))
+		assert2.NoError(t, err)
+		assert2.Equal(t, "order_number", column)
+	})
+
+	t.Run("when model has an ID field", func(t *testing.T) {
+		type user struct {
+			ID   int
+			Name string
+		}
+
+		column, err := primaryColumn(reflect.TypeOf(user{}))
+		assert2.NoError(t, err)
+		assert2.Equal(t, "id", column)
+	})
+
+	t.Run("when model embed gorm.Model", func(t *testing.T) {
+		type account struct {
+			gorm.Model
+			Email string
+		}
+
+		column, err := primaryColumn(reflect.TypeOf(account{}))
+		assert2.NoError(t, err)
+		assert2.Equal(t, "id", column)
+	})
+
+	t.Run("when model has no primary key", func(t *testing.T) {
+		type event struct {
+			Name string
+		}
+
+		_, err := primaryColumn(reflect.TypeOf(event{}))
+		assert2.Error(t, err)
+	})
+}
+
+func Test_Repository(t *testing.T) {
+	// - stored count the rows soft deleted too
+	stored := func(t *testing.T, repo *Repository[customer]) int64 {
+		var total int64
+		assert2.NoError(t, repo.orm.(*Impl).Database.Unscoped().Table("customers").Count(&total).Error)
+		return total
+	}
+
+	t.Run("when finding by id, return the row or ErrNotFound", func(t *testing.T) {
+		repo := newCustomers(t, 1, "ana", "bob")
+
+		found, err := repo.FindByID(2)
+		assert2.NoError(t, err)
+		assert2.Equal(t, "bob", found.Name)
+
+		_, err = repo.FindByID(3)
+		assert2.Equal(t, ErrNotFound, errors.Cause(err))
+	})
+
+	t.Run("when finding by conditions, return the matching rows", func(t *testing.T) {
+		repo := newCustomers(t, 2, "ana", "bob", "cid")
+
+		found, err := repo.FindBy(Eq("tier", "gold"))
+		assert2.NoError(t, err)
+		assert2.Len(t, found, 2)
+
+		found, err = repo.FindBy()
+		assert2.NoError(t, err)
+		assert2.Len(t, found, 3)
+
+		_, err = repo.FindBy(Eq("tier; drop table customers", "gold"))
+		assert2.Error(t, err)
+	})
+
+	t.Run("when updating, write the row", func(t *testing.T) {
+		repo := newCustomers(t, 0, "ana")
+
+		found, err := repo.FindByID(1)
+		assert2.NoError(t, err)
+
+		found.Tier = "gold"
+		assert2.NoError(t, repo.Update(found))
+
+		found, err = repo.FindByID(1)
+		assert2.NoError(t, err)
+		assert2.Equal(t, "gold", found.Tier)
+	})
+
+	t.Run("when deleting, remove the row", func(t *testing.T) {
+		repo := newCustomers(t, 0, "ana", "bob")
+
+		found, err := repo.FindByID(1)
+		assert2.NoError(t, err)
+		assert2.NoError(t, repo.Delete(found))
+
+		assert2.Equal(t, int64(1), stored(t, repo))
+	})
+
+	t.Run("when soft deleting, hide the row but keep it", func(t *testing.T) {
+		repo := newCustomers(t, 0, "ana", "bob")
+
+		found, err := repo.FindByID(1)
+		assert2.NoError(t, err)
+		assert2.NoError(t, repo.SoftDelete(found))
+
+		_, err = repo.FindByID(1)
+		assert2.Equal(t, ErrNotFound, errors.Cause(err))
+
+		total, err := repo.Count()
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(1), total)
+
+		assert2.Equal(t, int64(2), stored(t, repo))
+	})
+
+	t.Run("when counting, count the matching rows", func(t *testing.T) {
+		repo := newCustomers(t, 1, "ana", "bob", "cid")
+
+		total, err := repo.Count(In("name", []string{"ana", "bob"}))
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(2), total)
+
+		exists, err := repo.Exists(Eq("tier", "gold"))
+		assert2.NoError(t, err)
+		assert2.True(t, exists)
+
+		exists, err = repo.Exists(Eq("name", "dan"))
+		assert2.NoError(t, err)
+		assert2.False(t, exists)
+	})
+
+	t.Run("when listing, return the page and the total", func(t *testing.T) {
+		repo := newCustomers(t, 3, "ana", "bob", "cid", "dan", "eve")
+		query := repo.Query().Where(Eq("tier", "gold")).OrderBy("name", Asc).Limit(1)
+
+		page, err := repo.List(query, 2, 2)
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(3), page.Total)
+		assert2.Equal(t, 2, page.Page)
+		assert2.Equal(t, 2, page.Size)
+		assert2.Len(t, page.Items, 1)
+		assert2.Equal(t, "cid", page.Items[0].Name)
+
+		// - the query is not consumed, its own limit is kept
+		assert2.Equal(t, 1, query.limit)
+		assert2.Equal(t, 0, query.offset)
+
+		page, err = repo.List(query, 0, 2)
+		assert2.NoError(t, err)
+		assert2.Equal(t, 1, page.Page)
+		assert2.Equal(t, []string{"ana", "bob"}, []string{page.Items[0].Name, page.Items[1].Name})
+
+		_, err = repo.List(query, 1, 0)
+		assert2.Error(t, err)
+	})
+}
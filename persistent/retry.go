@@ -0,0 +1,123 @@
+package persistent
+
+import (
+	"database/sql"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+)
+
+var (
+	// retryablePostgresCodes are serialization_failure and deadlock_detected.
+	retryablePostgresCodes = map[pq.ErrorCode]bool{
+		"40001": true,
+		"40P01": true,
+	}
+
+	// retryableMysqlNumbers are ER_LOCK_DEADLOCK and ER_LOCK_WAIT_TIMEOUT.
+	retryableMysqlNumbers = map[uint16]bool{
+		1213: true,
+		1205: true,
+	}
+)
+
+type (
+	// RetryOption is an exponential backoff policy, MaxAttempts count the
+	// first attempt so 1 or less disable retry. TxOptions begin every
+	// attempt, nil use the database defaults.
+	RetryOption struct {
+		MaxAttempts int
+		MinBackoff  time.Duration
+		MaxBackoff  time.Duration
+		TxOptions   *sql.TxOptions
+		Logger      logs.Logger
+	}
+)
+
+func DefaultRetryOption() *RetryOption {
+	return &RetryOption{
+		MaxAttempts: 5,
+		MinBackoff:  10 * time.Millisecond,
+		MaxBackoff:  time.Second,
+	}
+}
+
+// WithRetryTransaction run block in a transaction with WithTransaction and
+// run it again in a new transaction while it fail with a serialization
+// failure or a deadlock, so block must only have side effects on tx. Within
+// an outer transaction block run once, the whole outer transaction is the
+// one to retry. A nil option use DefaultRetryOption, the backoff stop
+// when orm.Context() is done.
+func WithRetryTransaction(orm ORM, block func(tx ORM) error, option *RetryOption) error {
+	if orm.InTransaction() {
+		return WithTransaction(orm, block)
+	}
+
+	if option == nil {
+		option = DefaultRetryOption()
+	}
+
+	ctx := orm.Context()
+
+	err := WithTransaction(orm, block, option.TxOptions)
+
+	for attempt := 1; attempt < option.MaxAttempts; attempt++ {
+		if !IsRetryable(err) {
+			return err
+		}
+
+		backoff := option.backoff(attempt)
+		if option.Logger != nil {
+			option.Logger.Warningf("transaction attempt %d of %d failed, retrying in %s: %s", attempt, option.MaxAttempts, backoff, err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Wrapf(ctx.Err(), "transaction retry aborted after %d attempts: %s", attempt, err)
+		case <-timer.C:
+		}
+
+		err = WithTransaction(orm, block, option.TxOptions)
+	}
+
+	return err
+}
+
+// IsRetryable return true if the transaction failed because it conflicted
+// with a concurrent one and would succeed when run again.
+func IsRetryable(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case *pq.Error:
+		return retryablePostgresCodes[cause.Code]
+	case *mysql.MySQLError:
+		return retryableMysqlNumbers[cause.Number]
+	}
+
+	return false
+}
+
+// - private
+
+// backoff return the exponential backoff with equal jitter for the given
+// retry, which start from 1.
+func (o *RetryOption) backoff(retry int) time.Duration {
+	if o.MinBackoff <= 0 {
+		return 0
+	}
+
+	d := float64(o.MinBackoff) * math.Pow(2, float64(retry-1))
+	if o.MaxBackoff > 0 && d > float64(o.MaxBackoff) {
+		d = float64(o.MaxBackoff)
+	}
+
+	half := d / 2
+	return time.Duration(half + rand.Float64()*half)
+}
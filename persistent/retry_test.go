@@ -0,0 +1,113 @@
+package persistent
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func Test_IsRetryable(t *testing.T) {
+	t.Run("when error is a serialization failure or a deadlock", func(t *testing.T) {
+		assert2.True(t, IsRetryable(&pq.Error{Code: "40001"}))
+		assert2.True(t, IsRetryable(errors.Wrap(&pq.Error{Code: "40P01"}, "failed to commit")))
+		assert2.True(t, IsRetryable(&mysql.MySQLError{Number: 1213}))
+		assert2.True(t, IsRetryable(errors.WithStack(&mysql.MySQLError{Number: 1205})))
+	})
+
+	t.Run("when error is not transient", func(t *testing.T) {
+		assert2.False(t, IsRetryable(nil))
+		assert2.False(t, IsRetryable(sql.ErrNoRows))
+		assert2.False(t, IsRetryable(&pq.Error{Code: "23505"}))
+		assert2.False(t, IsRetryable(&mysql.MySQLError{Number: 1062}))
+	})
+}
+
+func Test_RetryBackoff(t *testing.T) {
+	option := &RetryOption{MinBackoff: 10, MaxBackoff: 40}
+
+	for retry, max := range []int64{10, 20, 40, 40} {
+		backoff := int64(option.backoff(retry + 1))
+		assert2.True(t, backoff >= max/2 && backoff <= max, "retry %d backoff %d", retry+1, backoff)
+	}
+}
+
+func Test_WithRetryTransaction(t *testing.T) {
+	conflict := &pq.Error{Code: "40001"}
+
+	t.Run("when block conflict, run it again until MaxAttempts", func(t *testing.T) {
+		orm := newSqlite(t, &bulkItem{})
+		calls := 0
+
+		err := WithRetryTransaction(orm, func(tx ORM) error {
+			calls++
+			if err := tx.Create(&bulkItem{ID: 1}); err != nil {
+				return err
+			}
+			return conflict
+		}, &RetryOption{MaxAttempts: 3, MinBackoff: time.Millisecond})
+		assert2.Equal(t, conflict, errors.Cause(err))
+		assert2.Equal(t, 3, calls)
+
+		total, err := orm.Count(&bulkItem{})
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(0), total)
+	})
+
+	t.Run("when block succeed on retry, commit", func(t *testing.T) {
+		orm := newSqlite(t, &bulkItem{})
+		calls := 0
+
+		err := WithRetryTransaction(orm, func(tx ORM) error {
+			calls++
+			if err := tx.Create(&bulkItem{ID: calls}); err != nil {
+				return err
+			}
+			if calls < 2 {
+				return conflict
+			}
+			return nil
+		}, nil)
+		assert2.NoError(t, err)
+		assert2.Equal(t, 2, calls)
+
+		var got bulkItem
+		assert2.NoError(t, orm.First(&got))
+		assert2.Equal(t, 2, got.ID)
+	})
+
+	t.Run("when error is not retryable, run block once", func(t *testing.T) {
+		orm := newSqlite(t, &bulkItem{})
+		calls := 0
+
+		err := WithRetryTransaction(orm, func(tx ORM) error {
+			calls++
+			return sql.ErrNoRows
+		}, nil)
+		assert2.Equal(t, sql.ErrNoRows, errors.Cause(err))
+		assert2.Equal(t, 1, calls)
+	})
+
+	t.Run("when ctx is done, stop the backoff", func(t *testing.T) {
+		orm := newSqlite(t, &bulkItem{})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		calls := 0
+		start := time.Now()
+
+		err := WithRetryTransaction(orm.WithContext(ctx), func(tx ORM) error {
+			calls++
+			cancel()
+			return conflict
+		}, &RetryOption{MaxAttempts: 3, MinBackoff: time.Minute})
+		assert2.Equal(t, context.Canceled, errors.Cause(err))
+		assert2.Equal(t, 1, calls)
+		assert2.True(t, time.Since(start) < 5*time.Second)
+	})
+}
@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// New open the sqlite database at uri, a file path or a go-sqlite3 dsn like
+// file:test.db?_foreign_keys=1. Every connection to an in memory database
+// open its own database, so in memory databases, :memory: or mode=memory,
+// use a single connection kept open, the pool option is ignored.
+func New(uri string, option *persistent.Option, logger logs.Logger) (persistent.ORM, error) {
+	db, err := gorm.Open("sqlite3", uri)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open sqlite connection!")
+	}
+
+	db.SetLogger(logger)
+	db.LogMode(option.LogMode)
+
+	// - closing the connection of an in memory database drop the database
+	if strings.Contains(uri, ":memory:") || strings.Contains(uri, "mode=memory") {
+		db.DB().SetMaxIdleConns(1)
+		db.DB().SetMaxOpenConns(1)
+		db.DB().SetConnMaxLifetime(0)
+	} else {
+		db.DB().SetMaxIdleConns(option.MaxIdleConnection)
+		db.DB().SetMaxOpenConns(option.MaxOpenConnection)
+		db.DB().SetConnMaxLifetime(option.ConnMaxLifetime)
+	}
+
+	orm := &persistent.Impl{Database: db, Logger: logger, DB: db.DB(), LogMode: option.LogMode}
+	if len(option.Hooks) > 0 {
+		return orm.Use(option.Hooks...), nil
+	}
+
+	return orm, nil
+}
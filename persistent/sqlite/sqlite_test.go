@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/persistent"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/util/migration"
+)
+
+type stock struct {
+	Warehouse string `gorm:"primary_key"`
+	SKU       string `gorm:"column:sku;primary_key"`
+	Quantity  int
+}
+
+func newORM(t *testing.T) persistent.ORM {
+	logger, err := logs.DefaultLog()
+	assert2.NoError(t, err)
+
+	orm, err := New(":memory:", &persistent.Option{}, logger)
+	assert2.NoError(t, err)
+
+	assert2.NoError(t, orm.Ping())
+	assert2.NoError(t, orm.CreateTable(&stock{}))
+
+	return orm
+}
+
+func Test_Bulk(t *testing.T) {
+	orm := newORM(t)
+	defer orm.Close()
+
+	rows := make([]interface{}, 0)
+	for i := 0; i < 500; i++ {
+		rows = append(rows, stock{Warehouse: "w" + string(rune('a'+i%2)), SKU: string(rune('a'+i/26%26)) + string(rune('a'+i%26)), Quantity: i})
+	}
+
+	t.Run("when rows are upserted", func(t *testing.T) {
+		affected, err := orm.BulkUpsert("stocks", 0, rows)
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(500), affected)
+
+		affected, err = orm.BulkUpsert("stocks", 10, []interface{}{stock{Warehouse: "wa", SKU: "aa", Quantity: -1}})
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(1), affected)
+
+		updated := stock{}
+		assert2.NoError(t, orm.Where("warehouse = ? AND sku = ?", "wa", "aa").First(&updated))
+		assert2.Equal(t, -1, updated.Quantity)
+	})
+
+	t.Run("when rows are deleted within a transaction", func(t *testing.T) {
+		err := persistent.WithTransaction(orm, func(tx persistent.ORM) error {
+			if err := tx.BulkDelete("stocks", rows[:300]); err != nil {
+				return err
+			}
+			return errors.New("rollback")
+		})
+		assert2.EqualError(t, err, "rollback")
+
+		total, err := orm.Count(&[]stock{})
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(500), total)
+
+		assert2.NoError(t, orm.BulkDelete("stocks", rows[:300]))
+
+		total, err = orm.Count(&[]stock{})
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(200), total)
+	})
+}
+
+func Test_Migration(t *testing.T) {
+	orm := newORM(t)
+	defer orm.Close()
+
+	logger, _ := logs.DefaultLog()
+	tool, err := migration.NewSqlMigration(orm, map[int64]*migration.Script{
+		1: {Up: "CREATE TABLE carts (id integer primary key)", Down: "DROP TABLE carts", UsingTransaction: true},
+		2: {Up: "ALTER TABLE carts ADD COLUMN owner text", Down: "ALTER TABLE carts DROP COLUMN owner"},
+	}, logger)
+	assert2.NoError(t, err)
+
+	assert2.NoError(t, tool.Initialize())
+	assert2.NoError(t, tool.Up())
+	assert2.NoError(t, tool.Check())
+	assert2.True(t, orm.HasTable("carts"))
+
+	assert2.NoError(t, tool.Truncate())
+	assert2.Error(t, tool.Check())
+}
+
+type invoice struct {
+	ID      int `gorm:"primary_key"`
+	Total   int
+	Version int `gorm:"version"`
+}
+
+func Test_OptimisticLocking(t *testing.T) {
+	orm := newORM(t)
+	defer orm.Close()
+
+	assert2.NoError(t, orm.CreateTable(&invoice{}))
+	assert2.NoError(t, orm.Create(&invoice{ID: 1, Total: 10, Version: 1}))
+
+	t.Run("when updating a stale object, keep the row", func(t *testing.T) {
+		first, second := invoice{}, invoice{}
+		assert2.NoError(t, orm.Where("id = ?", 1).First(&first))
+		assert2.NoError(t, orm.Where("id = ?", 1).First(&second))
+
+		first.Total = 20
+		assert2.NoError(t, orm.Update(&first))
+		assert2.Equal(t, 2, first.Version)
+
+		second.Total = 30
+		err := orm.Update(&second)
+		assert2.True(t, persistent.IsStaleObject(err))
+		assert2.Equal(t, 1, second.Version)
+
+		saved := invoice{}
+		assert2.NoError(t, orm.Where("id = ?", 1).First(&saved))
+		assert2.Equal(t, invoice{ID: 1, Total: 20, Version: 2}, saved)
+	})
+
+	t.Run("when upserting stale rows, skip them", func(t *testing.T) {
+		affected, err := orm.BulkUpsert("invoices", 0, []interface{}{invoice{ID: 1, Total: 40, Version: 2}, invoice{ID: 2, Total: 5}})
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(2), affected)
+
+		_, err = orm.BulkUpsert("invoices", 0, []interface{}{invoice{ID: 1, Total: 50, Version: 2}, invoice{ID: 3}})
+		assert2.True(t, persistent.IsStaleObject(err))
+
+		saved := invoice{}
+		assert2.NoError(t, orm.Where("id = ?", 1).First(&saved))
+		assert2.Equal(t, invoice{ID: 1, Total: 40, Version: 3}, saved)
+	})
+}
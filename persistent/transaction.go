@@ -0,0 +1,87 @@
+package persistent
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// savepoints number the savepoints of nested transactions.
+var savepoints uint64
+
+// WithTransaction run block in a transaction, committed when block return
+// nil and rolled back when it return an error or panic, the panic is then
+// propagated. When orm is already a transaction the block run within a
+// savepoint instead, so rolling it back keep the work of the outer block,
+// option only apply to the outermost transaction.
+//
+//	err := persistent.WithTransaction(orm, func(tx persistent.ORM) error {
+//		return tx.Create(&order)
+//	}, &sql.TxOptions{Isolation: sql.LevelSerializable})
+func WithTransaction(orm ORM, block func(tx ORM) error, option ...*sql.TxOptions) error {
+	if orm.InTransaction() {
+		return withSavepoint(orm, block)
+	}
+
+	var opts *sql.TxOptions
+	if len(option) > 0 {
+		opts = option[0]
+	}
+
+	tx := orm.BeginTx(opts)
+	if err := tx.Error(); err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := block(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Wrapf(rbErr, "failed to rollback after %s", err)
+		}
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit")
+	}
+
+	return nil
+}
+
+// - private
+
+func withSavepoint(tx ORM, block func(tx ORM) error) error {
+	name := fmt.Sprintf("sp_%d", atomic.AddUint64(&savepoints, 1))
+
+	if err := tx.Exec("SAVEPOINT " + name); err != nil {
+		return errors.Wrapf(err, "failed to create savepoint %s", name)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+			panic(r)
+		}
+	}()
+
+	if err := block(tx); err != nil {
+		if rbErr := tx.Exec("ROLLBACK TO SAVEPOINT " + name); rbErr != nil {
+			return errors.Wrapf(rbErr, "failed to rollback to savepoint %s after %s", name, err)
+		}
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Exec("RELEASE SAVEPOINT " + name); err != nil {
+		return errors.Wrapf(err, "failed to release savepoint %s", name)
+	}
+
+	return nil
+}
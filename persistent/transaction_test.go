@@ -0,0 +1,127 @@
+package persistent
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+// newSqlite return an ORM of an in memory database with the tables of
+// models.
+func newSqlite(t *testing.T, models ...interface{}) ORM {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert2.NoError(t, err)
+	db.DB().SetMaxOpenConns(1)
+
+	orm := &Impl{Database: db, DB: db.DB()}
+	t.Cleanup(func() {
+		_ = orm.Close()
+	})
+
+	for _, model := range models {
+		assert2.NoError(t, orm.CreateTable(model))
+	}
+
+	return orm
+}
+
+func Test_WithTransaction(t *testing.T) {
+	failure := errors.New("failure")
+
+	exists := func(t *testing.T, orm ORM, id int) bool {
+		total, err := orm.Where("id = ?", id).Count(&bulkItem{})
+		assert2.NoError(t, err)
+		return total > 0
+	}
+
+	t.Run("when block succeed, commit", func(t *testing.T) {
+		orm := newSqlite(t, &bulkItem{})
+
+		err := WithTransaction(orm, func(tx ORM) error {
+			return tx.Create(&bulkItem{ID: 1})
+		})
+		assert2.NoError(t, err)
+		assert2.True(t, exists(t, orm, 1))
+	})
+
+	t.Run("when block fail, rollback", func(t *testing.T) {
+		orm := newSqlite(t, &bulkItem{})
+
+		err := WithTransaction(orm, func(tx ORM) error {
+			if err := tx.Create(&bulkItem{ID: 1}); err != nil {
+				return err
+			}
+			return failure
+		})
+		assert2.Equal(t, failure, errors.Cause(err))
+		assert2.False(t, exists(t, orm, 1))
+	})
+
+	t.Run("when nested block fail, keep the work of the outer block", func(t *testing.T) {
+		orm := newSqlite(t, &bulkItem{})
+
+		err := WithTransaction(orm, func(tx ORM) error {
+			if err := tx.Create(&bulkItem{ID: 1}); err != nil {
+				return err
+			}
+
+			nested := WithTransaction(tx, func(tx ORM) error {
+				if err := tx.Create(&bulkItem{ID: 2}); err != nil {
+					return err
+				}
+				return failure
+			})
+			assert2.Equal(t, failure, errors.Cause(nested))
+
+			return WithTransaction(tx, func(tx ORM) error {
+				return tx.Create(&bulkItem{ID: 3})
+			})
+		})
+		assert2.NoError(t, err)
+
+		assert2.True(t, exists(t, orm, 1))
+		assert2.False(t, exists(t, orm, 2))
+		assert2.True(t, exists(t, orm, 3))
+	})
+
+	t.Run("when block panic, rollback and panic again", func(t *testing.T) {
+		orm := newSqlite(t, &bulkItem{})
+
+		assert2.PanicsWithValue(t, "boom", func() {
+			_ = WithTransaction(orm, func(tx ORM) error {
+				if err := tx.Create(&bulkItem{ID: 1}); err != nil {
+					return err
+				}
+				panic("boom")
+			})
+		})
+		assert2.False(t, exists(t, orm, 1))
+	})
+
+	t.Run("when nested block panic, rollback the savepoint and panic again", func(t *testing.T) {
+		orm := newSqlite(t, &bulkItem{})
+
+		err := WithTransaction(orm, func(tx ORM) error {
+			if err := tx.Create(&bulkItem{ID: 1}); err != nil {
+				return err
+			}
+
+			assert2.PanicsWithValue(t, "boom", func() {
+				_ = WithTransaction(tx, func(tx ORM) error {
+					if err := tx.Create(&bulkItem{ID: 2}); err != nil {
+						return err
+					}
+					panic("boom")
+				})
+			})
+
+			return nil
+		})
+		assert2.NoError(t, err)
+
+		assert2.True(t, exists(t, orm, 1))
+		assert2.False(t, exists(t, orm, 2))
+	})
+}
@@ -0,0 +1,98 @@
+package persistent
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+// ErrStaleObject is the error of an Update, or a BulkUpsert, of a model with
+// a version column, `gorm:"version"`, when the row was updated since it was
+// read, its version does not match anymore. Reload the row and retry.
+//
+//	type Order struct {
+//		ID      int `gorm:"primary_key"`
+//		Total   int
+//		Version int `gorm:"version"`
+//	}
+type ErrStaleObject struct {
+	Table string
+	// Version is the version the update expected, zero for a BulkUpsert.
+	Version int64
+}
+
+func (e *ErrStaleObject) Error() string {
+	if e.Version == 0 {
+		return fmt.Sprintf("stale object in %s: rows were updated since read", e.Table)
+	}
+
+	return fmt.Sprintf("stale object in %s: version %d was updated since read", e.Table, e.Version)
+}
+
+// IsStaleObject return true if the cause of err is an ErrStaleObject.
+func IsStaleObject(err error) bool {
+	_, ok := errors.Cause(err).(*ErrStaleObject)
+	return ok
+}
+
+// - private
+
+// versionField return the version field of the model of scope, if any.
+func versionField(scope *gorm.Scope) (*gorm.Field, bool) {
+	for _, field := range scope.Fields() {
+		if _, ok := field.TagSettingsGet("VERSION"); ok && field.IsNormal && !field.IsIgnored {
+			return field, true
+		}
+	}
+
+	return nil, false
+}
+
+func versionOf(field *gorm.Field) (int64, error) {
+	value := reflect.Indirect(field.Field)
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint()), nil
+	}
+
+	return 0, errors.Errorf("version %s is a %s, not an integer", field.Name, value.Type())
+}
+
+// updateVersion update every column of object where its version did not
+// change and increment it, the version of object is kept on failure.
+func (o *Impl) updateVersion(scope *gorm.Scope, version *gorm.Field) error {
+	current, err := versionOf(version)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{})
+	for _, field := range scope.Fields() {
+		if field.IsNormal && !field.IsIgnored && !field.IsPrimaryKey && field.DBName != version.DBName {
+			values[field.DBName] = field.Field.Interface()
+		}
+	}
+	values[version.DBName] = current + 1
+
+	res := o.Database.Model(scope.Value).Where(scope.Quote(version.DBName)+" = ?", current).Updates(values)
+	if res.Error != nil || res.RowsAffected == 0 {
+		if err := version.Set(current); err != nil {
+			return errors.Wrap(err, "failed to restore version")
+		}
+	}
+
+	if err := res.Error; err != nil {
+		return errors.Wrapf(err, "failed to update object %+v", scope.Value)
+	}
+
+	if res.RowsAffected == 0 {
+		return &ErrStaleObject{Table: scope.TableName(), Version: current}
+	}
+
+	return nil
+}
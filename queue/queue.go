@@ -0,0 +1,334 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	andretime "github.com/AndreeJait/GO-ANDREE-UTILITIES/util/andreTime"
+)
+
+const (
+	DefaultVisibilityTimeout = 30 * time.Second
+	DefaultMaxAttempts       = 5
+	DefaultMinBackoff        = time.Second
+	DefaultMaxBackoff        = time.Hour
+
+	// MinVisibilityTimeout is the precision of the schedule, its scores are
+	// in milliseconds.
+	MinVisibilityTimeout = time.Millisecond
+)
+
+var (
+	// ErrLostOwnership is returned when a job is acknowledged, failed or
+	// extended after it was requeued past its visibility timeout, the job
+	// may have been handed to another worker meanwhile.
+	ErrLostOwnership = errors.New("job is no longer owned by this worker")
+)
+
+type (
+	Job struct {
+		ID          string          `json:"id"`
+		Payload     json.RawMessage `json:"payload"`
+		MaxAttempts int             `json:"max_attempts"`
+		EnqueuedAt  time.Time       `json:"enqueued_at"`
+		LastError   string          `json:"last_error,omitempty"`
+
+		// Attempts is the number of times the job has been claimed,
+		// including the current one.
+		Attempts int `json:"-"`
+		// Token identify the claim, only its holder can ack, fail or
+		// extend the job.
+		Token string `json:"-"`
+	}
+
+	// Option configure a Queue, a job is redelivered when it is not
+	// acknowledged within VisibilityTimeout, and failed jobs are retried
+	// with exponential backoff between MinBackoff and MaxBackoff until
+	// MaxAttempts then moved to the dead letter list. Time is the clock of
+	// the schedule, the real one by default. A zero VisibilityTimeout use
+	// DefaultVisibilityTimeout, a negative one or one below
+	// MinVisibilityTimeout is rejected.
+	Option struct {
+		Name              string
+		VisibilityTimeout time.Duration
+		MaxAttempts       int
+		MinBackoff        time.Duration
+		MaxBackoff        time.Duration
+		Time              andretime.AndreTime
+	}
+
+	Queue interface {
+		Enqueue(payload interface{}) (*Job, error)
+		EnqueueIn(delay time.Duration, payload interface{}) (*Job, error)
+		EnqueueAt(at time.Time, payload interface{}) (*Job, error)
+
+		// Claim take up to limit due jobs and hide them from other workers
+		// for the visibility timeout.
+		Claim(limit int) ([]*Job, error)
+		// Extend push the visibility timeout of a running job.
+		Extend(job *Job) error
+		Ack(job *Job) error
+		// Fail schedule a retry with backoff or dead letter the job once it
+		// reaches its max attempts.
+		Fail(job *Job, cause error) error
+		// Requeue make in-flight jobs past their visibility timeout due again.
+		Requeue() (int64, error)
+
+		// DeadLetters return up to limit jobs of the dead letter list, the
+		// latest first, none when limit is not positive.
+		DeadLetters(limit int) ([]*Job, error)
+		Option() Option
+	}
+
+	queue struct {
+		cache  cache.Cache
+		option Option
+
+		jobs, ready, inflight, attempts, tokens, dead string
+	}
+)
+
+func New(c cache.Cache, option *Option) (Queue, error) {
+	if c == nil {
+		return nil, errors.New("cache is required!")
+	}
+
+	if option.Name == "" {
+		return nil, errors.New("queue name is required!")
+	}
+
+	o := *option
+	if o.VisibilityTimeout == 0 {
+		o.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if o.VisibilityTimeout < MinVisibilityTimeout {
+		return nil, errors.Errorf("visibility timeout %s is below %s!", o.VisibilityTimeout, MinVisibilityTimeout)
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultMaxAttempts
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = DefaultMinBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = DefaultMaxBackoff
+	}
+	if o.Time == nil {
+		o.Time = andretime.NewRealTime()
+	}
+
+	prefix := fmt.Sprintf("{queue:%s}", o.Name)
+
+	return &queue{
+		cache:    c,
+		option:   o,
+		jobs:     prefix + ":jobs",
+		ready:    prefix + ":ready",
+		inflight: prefix + ":inflight",
+		attempts: prefix + ":attempts",
+		tokens:   prefix + ":tokens",
+		dead:     prefix + ":dead",
+	}, nil
+}
+
+// Decode unmarshal the job payload into object.
+func (j *Job) Decode(object interface{}) error {
+	if err := json.Unmarshal(j.Payload, object); err != nil {
+		return errors.Wrapf(err, "failed to decode payload of job %s", j.ID)
+	}
+
+	return nil
+}
+
+func (q *queue) Enqueue(payload interface{}) (*Job, error) {
+	return q.EnqueueAt(q.option.Time.Now(), payload)
+}
+
+func (q *queue) EnqueueIn(delay time.Duration, payload interface{}) (*Job, error) {
+	return q.EnqueueAt(q.option.Time.Now().Add(delay), payload)
+}
+
+func (q *queue) EnqueueAt(at time.Time, payload interface{}) (*Job, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal payload")
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate job id")
+	}
+
+	job := &Job{ID: id, Payload: raw, MaxAttempts: q.option.MaxAttempts, EnqueuedAt: q.option.Time.Now()}
+
+	body, err := json.Marshal(job)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal job")
+	}
+
+	if _, err := q.cache.Eval(enqueueScript, []string{q.jobs, q.ready}, id, body, score(at)); err != nil {
+		return nil, errors.Wrapf(err, "failed to enqueue job to %s", q.option.Name)
+	}
+
+	return job, nil
+}
+
+func (q *queue) Claim(limit int) ([]*Job, error) {
+	now := q.option.Time.Now()
+	deadline := now.Add(q.option.VisibilityTimeout)
+
+	token, err := newID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate claim token")
+	}
+
+	res, err := q.cache.Eval(claimScript, []string{q.ready, q.inflight, q.jobs, q.attempts, q.tokens},
+		score(now), score(deadline), limit, token)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to claim jobs from %s", q.option.Name)
+	}
+
+	values, _ := res.([]interface{})
+	jobs := make([]*Job, 0, len(values)/3)
+
+	for i := 0; i+2 < len(values); i += 3 {
+		body, _ := values[i+1].(string)
+
+		job := &Job{}
+		if err := json.Unmarshal([]byte(body), job); err != nil {
+			return jobs, errors.Wrapf(err, "failed to unmarshal job %v", values[i])
+		}
+
+		attempts, _ := values[i+2].(int64)
+		job.Attempts = int(attempts)
+		job.Token = token
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func (q *queue) Extend(job *Job) error {
+	deadline := q.option.Time.Now().Add(q.option.VisibilityTimeout)
+
+	res, err := q.cache.Eval(extendScript, []string{q.inflight, q.tokens}, job.ID, job.Token, score(deadline))
+	if err != nil {
+		return errors.Wrapf(err, "failed to extend job %s", job.ID)
+	}
+
+	return owned(res)
+}
+
+func (q *queue) Ack(job *Job) error {
+	res, err := q.cache.Eval(ackScript, []string{q.inflight, q.jobs, q.attempts, q.tokens}, job.ID, job.Token)
+	if err != nil {
+		return errors.Wrapf(err, "failed to ack job %s", job.ID)
+	}
+
+	return owned(res)
+}
+
+func (q *queue) Fail(job *Job, cause error) error {
+	if cause != nil {
+		job.LastError = cause.Error()
+	}
+
+	body, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal job")
+	}
+
+	var res interface{}
+
+	if job.Attempts >= job.MaxAttempts {
+		res, err = q.cache.Eval(deadScript, []string{q.inflight, q.jobs, q.attempts, q.dead, q.tokens}, job.ID, job.Token, body)
+	} else {
+		runAt := q.option.Time.Now().Add(q.backoff(job.Attempts))
+		res, err = q.cache.Eval(retryScript, []string{q.inflight, q.ready, q.jobs, q.tokens}, job.ID, job.Token, body, score(runAt))
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to fail job %s", job.ID)
+	}
+
+	return owned(res)
+}
+
+func (q *queue) Requeue() (int64, error) {
+	res, err := q.cache.Eval(requeueScript, []string{q.inflight, q.ready, q.tokens}, score(q.option.Time.Now()))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to requeue jobs of %s", q.option.Name)
+	}
+
+	count, _ := res.(int64)
+	return count, nil
+}
+
+func (q *queue) DeadLetters(limit int) ([]*Job, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	res, err := q.cache.Eval(deadLettersScript, []string{q.dead}, limit)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get dead letters of %s", q.option.Name)
+	}
+
+	values, _ := res.([]interface{})
+	jobs := make([]*Job, 0, len(values))
+
+	for _, value := range values {
+		body, _ := value.(string)
+
+		job := &Job{}
+		if err := json.Unmarshal([]byte(body), job); err != nil {
+			return jobs, errors.Wrap(err, "failed to unmarshal dead letter")
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func (q *queue) Option() Option {
+	return q.option
+}
+
+// - private
+
+func (q *queue) backoff(attempts int) time.Duration {
+	d := float64(q.option.MinBackoff) * math.Pow(2, float64(attempts-1))
+	if d > float64(q.option.MaxBackoff) {
+		return q.option.MaxBackoff
+	}
+
+	return time.Duration(d)
+}
+
+func owned(res interface{}) error {
+	if n, _ := res.(int64); n == 0 {
+		return ErrLostOwnership
+	}
+
+	return nil
+}
+
+func score(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
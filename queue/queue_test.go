@@ -0,0 +1,215 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redistest"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/queue"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newQueue(t *testing.T, option *queue.Option) (*clock, queue.Queue) {
+	_, c := redistest.NewClient(t)
+
+	now := &clock{now: time.Date(2022, time.March, 1, 10, 0, 0, 0, time.UTC)}
+	option.Name = "emails"
+	option.Time = now
+
+	q, err := queue.New(c, option)
+	assert2.NoError(t, err)
+
+	return now, q
+}
+
+func Test_Queue(t *testing.T) {
+
+	t.Run("when job is claimed and acked", func(t *testing.T) {
+		_, q := newQueue(t, &queue.Option{})
+
+		enqueued, err := q.Enqueue(map[string]string{"to": "andree"})
+		assert2.NoError(t, err)
+
+		jobs, err := q.Claim(10)
+		assert2.NoError(t, err)
+		assert2.Len(t, jobs, 1)
+		assert2.Equal(t, enqueued.ID, jobs[0].ID)
+		assert2.Equal(t, 1, jobs[0].Attempts)
+		assert2.NotEmpty(t, jobs[0].Token)
+
+		var payload map[string]string
+		assert2.NoError(t, jobs[0].Decode(&payload))
+		assert2.Equal(t, map[string]string{"to": "andree"}, payload)
+
+		jobs2, err := q.Claim(10)
+		assert2.NoError(t, err)
+		assert2.Empty(t, jobs2)
+
+		assert2.NoError(t, q.Ack(jobs[0]))
+		assert2.Equal(t, queue.ErrLostOwnership, q.Ack(jobs[0]))
+	})
+
+	t.Run("when job is delayed", func(t *testing.T) {
+		now, q := newQueue(t, &queue.Option{})
+
+		_, err := q.EnqueueIn(time.Minute, "later")
+		assert2.NoError(t, err)
+
+		jobs, err := q.Claim(10)
+		assert2.NoError(t, err)
+		assert2.Empty(t, jobs)
+
+		now.now = now.now.Add(time.Minute)
+
+		jobs, err = q.Claim(10)
+		assert2.NoError(t, err)
+		assert2.Len(t, jobs, 1)
+	})
+
+	t.Run("when job fails it is retried with backoff", func(t *testing.T) {
+		now, q := newQueue(t, &queue.Option{MinBackoff: time.Second, MaxBackoff: 3 * time.Second})
+
+		_, err := q.Enqueue("retry")
+		assert2.NoError(t, err)
+
+		for attempt, backoff := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+			jobs, err := q.Claim(10)
+			assert2.NoError(t, err)
+			assert2.Len(t, jobs, 1)
+			assert2.Equal(t, attempt+1, jobs[0].Attempts)
+
+			assert2.NoError(t, q.Fail(jobs[0], errors.New("smtp down")))
+
+			now.now = now.now.Add(backoff - time.Millisecond)
+			jobs, err = q.Claim(10)
+			assert2.NoError(t, err)
+			assert2.Empty(t, jobs)
+
+			now.now = now.now.Add(time.Millisecond)
+		}
+
+		jobs, err := q.Claim(10)
+		assert2.NoError(t, err)
+		assert2.Len(t, jobs, 1)
+		assert2.Equal(t, "smtp down", jobs[0].LastError)
+	})
+
+	t.Run("when job reaches its max attempts it is dead lettered", func(t *testing.T) {
+		now, q := newQueue(t, &queue.Option{MaxAttempts: 2, MinBackoff: time.Second})
+
+		enqueued, err := q.Enqueue("dead")
+		assert2.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			jobs, err := q.Claim(10)
+			assert2.NoError(t, err)
+			assert2.Len(t, jobs, 1)
+			assert2.NoError(t, q.Fail(jobs[0], errors.New("invalid address")))
+
+			now.now = now.now.Add(time.Hour)
+		}
+
+		jobs, err := q.Claim(10)
+		assert2.NoError(t, err)
+		assert2.Empty(t, jobs)
+
+		dead, err := q.DeadLetters(10)
+		assert2.NoError(t, err)
+		assert2.Len(t, dead, 1)
+		assert2.Equal(t, enqueued.ID, dead[0].ID)
+		assert2.Equal(t, "invalid address", dead[0].LastError)
+
+		dead, err = q.DeadLetters(0)
+		assert2.NoError(t, err)
+		assert2.Empty(t, dead)
+	})
+
+	t.Run("when job is not acked within the visibility timeout", func(t *testing.T) {
+		now, q := newQueue(t, &queue.Option{VisibilityTimeout: time.Minute})
+
+		_, err := q.Enqueue("slow")
+		assert2.NoError(t, err)
+
+		jobs, err := q.Claim(10)
+		assert2.NoError(t, err)
+		assert2.Len(t, jobs, 1)
+		late := jobs[0]
+
+		now.now = now.now.Add(59 * time.Second)
+		requeued, err := q.Requeue()
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(0), requeued)
+
+		assert2.NoError(t, q.Extend(late))
+
+		now.now = now.now.Add(59 * time.Second)
+		requeued, err = q.Requeue()
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(0), requeued)
+
+		now.now = now.now.Add(time.Second)
+		requeued, err = q.Requeue()
+		assert2.NoError(t, err)
+		assert2.Equal(t, int64(1), requeued)
+
+		jobs, err = q.Claim(10)
+		assert2.NoError(t, err)
+		assert2.Len(t, jobs, 1)
+		assert2.Equal(t, 2, jobs[0].Attempts)
+		assert2.NotEqual(t, late.Token, jobs[0].Token)
+
+		assert2.Equal(t, queue.ErrLostOwnership, q.Ack(late))
+		assert2.Equal(t, queue.ErrLostOwnership, q.Fail(late, errors.New("timeout")))
+		assert2.Equal(t, queue.ErrLostOwnership, q.Extend(late))
+
+		assert2.NoError(t, q.Ack(jobs[0]))
+	})
+}
+
+type shortQueue struct {
+	queue.Queue
+}
+
+func (q shortQueue) Option() queue.Option {
+	return queue.Option{Name: "short", VisibilityTimeout: time.Nanosecond}
+}
+
+func Test_VisibilityTimeout(t *testing.T) {
+	_, c := redistest.NewClient(t)
+
+	t.Run("when zero, use the default", func(t *testing.T) {
+		q, err := queue.New(c, &queue.Option{Name: "emails"})
+		assert2.NoError(t, err)
+		assert2.Equal(t, queue.DefaultVisibilityTimeout, q.Option().VisibilityTimeout)
+	})
+
+	t.Run("when negative or below the precision, reject it", func(t *testing.T) {
+		_, err := queue.New(c, &queue.Option{Name: "emails", VisibilityTimeout: -time.Second})
+		assert2.Error(t, err)
+
+		_, err = queue.New(c, &queue.Option{Name: "emails", VisibilityTimeout: time.Nanosecond})
+		assert2.Error(t, err)
+	})
+
+	t.Run("when the queue of a worker is below the precision, reject it", func(t *testing.T) {
+		handler := func(ctx context.Context, job *queue.Job) error { return nil }
+
+		logger, err := logs.DefaultLog()
+		assert2.NoError(t, err)
+
+		_, err = queue.NewWorker(shortQueue{}, handler, &queue.WorkerOption{}, logger)
+		assert2.Error(t, err)
+	})
+}
@@ -0,0 +1,98 @@
+package queue
+
+// Every script touch keys sharing the `{queue:<name>}` hash tag so they can
+// run on redis cluster and on the sharded client.
+//
+// A claim store a random token per in-flight job, the scripts acting on a
+// claimed job compare it so a worker whose job was requeued and claimed
+// again cannot ack, fail or extend the new claim.
+const (
+	// KEYS: jobs, ready - ARGV: id, job, run at
+	enqueueScript = `
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+return 1
+`
+
+	// KEYS: ready, inflight, jobs, attempts, tokens - ARGV: now, deadline,
+	// limit, token
+	claimScript = `
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+local claimed = {}
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[1], id)
+	local job = redis.call('HGET', KEYS[3], id)
+	if job then
+		redis.call('ZADD', KEYS[2], ARGV[2], id)
+		redis.call('HSET', KEYS[5], id, ARGV[4])
+		local attempts = redis.call('HINCRBY', KEYS[4], id, 1)
+		table.insert(claimed, id)
+		table.insert(claimed, job)
+		table.insert(claimed, attempts)
+	end
+end
+return claimed
+`
+
+	// KEYS: inflight, jobs, attempts, tokens - ARGV: id, token
+	ackScript = `
+if redis.call('HGET', KEYS[4], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('HDEL', KEYS[4], ARGV[1])
+return 1
+`
+
+	// KEYS: inflight, ready, jobs, tokens - ARGV: id, token, job, run at
+	retryScript = `
+if redis.call('HGET', KEYS[4], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[4], ARGV[1])
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[3])
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1])
+return 1
+`
+
+	// KEYS: inflight, jobs, attempts, dead, tokens - ARGV: id, token, job
+	deadScript = `
+if redis.call('HGET', KEYS[5], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('HDEL', KEYS[5], ARGV[1])
+redis.call('LPUSH', KEYS[4], ARGV[3])
+return 1
+`
+
+	// KEYS: inflight, tokens - ARGV: id, token, deadline
+	extendScript = `
+if redis.call('HGET', KEYS[2], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+return 1
+`
+
+	// KEYS: inflight, ready, tokens - ARGV: now
+	requeueScript = `
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('HDEL', KEYS[3], id)
+	redis.call('ZADD', KEYS[2], ARGV[1], id)
+end
+return #ids
+`
+
+	// KEYS: dead - ARGV: limit
+	deadLettersScript = `
+return redis.call('LRANGE', KEYS[1], 0, ARGV[1] - 1)
+`
+)
@@ -0,0 +1,233 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/logs"
+)
+
+const DefaultPollInterval = time.Second
+
+type (
+	// Handler process a job, returning an error schedule a retry.
+	Handler func(ctx context.Context, job *Job) error
+
+	WorkerOption struct {
+		Concurrency  int
+		PollInterval time.Duration
+	}
+
+	// Worker run Handler for the jobs of a Queue with a bounded number of
+	// concurrent jobs. Running jobs have their visibility timeout extended
+	// until they finish.
+	Worker struct {
+		queue   Queue
+		handler Handler
+		option  WorkerOption
+		logger  logs.Logger
+
+		slots  chan struct{}
+		stop   chan struct{}
+		once   sync.Once
+		poller sync.WaitGroup
+		jobs   sync.WaitGroup
+
+		ctx    context.Context
+		cancel context.CancelFunc
+	}
+)
+
+func NewWorker(q Queue, handler Handler, option *WorkerOption, logger logs.Logger) (*Worker, error) {
+	if q == nil {
+		return nil, errors.New("queue is required!")
+	}
+
+	if handler == nil {
+		return nil, errors.New("handler is required!")
+	}
+
+	if logger == nil {
+		return nil, errors.New("logger is required!")
+	}
+
+	// - the heartbeat extend the jobs every half visibility timeout
+	if timeout := q.Option().VisibilityTimeout; timeout < MinVisibilityTimeout {
+		return nil, errors.Errorf("visibility timeout %s of queue %s is below %s!", timeout, q.Option().Name, MinVisibilityTimeout)
+	}
+
+	o := *option
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = DefaultPollInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Worker{
+		queue:   q,
+		handler: handler,
+		option:  o,
+		logger:  logger,
+		slots:   make(chan struct{}, o.Concurrency),
+		stop:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// Start poll the queue in background until Shutdown is called.
+func (w *Worker) Start() {
+	w.poller.Add(1)
+	go w.poll()
+}
+
+// Shutdown stop claiming new jobs and wait for the running ones. When ctx
+// is done first, running jobs get their context canceled and are left to
+// be redelivered after their visibility timeout.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	w.poller.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		w.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		return errors.Wrap(ctx.Err(), "worker shutdown before jobs finished")
+	}
+}
+
+// - private
+
+func (w *Worker) poll() {
+	defer w.poller.Done()
+
+	ticker := time.NewTicker(w.option.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.queue.Requeue(); err != nil {
+			w.logger.Errorf("failed to requeue expired jobs: %s", err)
+		}
+
+		for w.claim() {
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim fill the free slots and return true if every slot got a job, in
+// which case more jobs may be waiting.
+func (w *Worker) claim() bool {
+	free := cap(w.slots) - len(w.slots)
+	if free == 0 {
+		select {
+		case <-w.stop:
+			return false
+		case w.slots <- struct{}{}:
+			<-w.slots
+			return true
+		}
+	}
+
+	select {
+	case <-w.stop:
+		return false
+	default:
+	}
+
+	jobs, err := w.queue.Claim(free)
+	if err != nil {
+		w.logger.Errorf("failed to claim jobs: %s", err)
+		return false
+	}
+
+	for _, job := range jobs {
+		w.slots <- struct{}{}
+		w.jobs.Add(1)
+		go w.run(job)
+	}
+
+	return len(jobs) == free
+}
+
+func (w *Worker) run(job *Job) {
+	defer func() {
+		<-w.slots
+		w.jobs.Done()
+	}()
+
+	if job.Attempts > job.MaxAttempts {
+		w.fail(job, errors.New("max attempts exceeded after visibility timeout"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(w.ctx)
+	defer cancel()
+
+	go w.heartbeat(ctx, job)
+
+	if err := w.handle(ctx, job); err != nil {
+		w.fail(job, err)
+		return
+	}
+
+	if err := w.queue.Ack(job); err != nil {
+		w.logger.Errorf("failed to ack job %s: %s", job.ID, err)
+	}
+}
+
+func (w *Worker) handle(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("job panic: %v", r))
+		}
+	}()
+
+	return w.handler(ctx, job)
+}
+
+func (w *Worker) fail(job *Job, cause error) {
+	w.logger.Errorf("job %s failed on attempt %d: %s", job.ID, job.Attempts, cause)
+
+	if err := w.queue.Fail(job, cause); err != nil {
+		w.logger.Errorf("failed to reschedule job %s: %s", job.ID, err)
+	}
+}
+
+func (w *Worker) heartbeat(ctx context.Context, job *Job) {
+	ticker := time.NewTicker(w.queue.Option().VisibilityTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.queue.Extend(job); err != nil {
+				w.logger.Errorf("failed to extend job %s: %s", job.ID, err)
+			}
+		}
+	}
+}
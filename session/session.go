@@ -0,0 +1,482 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache"
+	andretime "github.com/AndreeJait/GO-ANDREE-UTILITIES/util/andreTime"
+)
+
+const (
+	DefaultPrefix = "session"
+	DefaultTTL    = 30 * time.Minute
+
+	idBytes = 32
+
+	fieldUserID     = "_user_id"
+	fieldCreatedAt  = "_created_at"
+	fieldLastSeenAt = "_last_seen_at"
+	dataPrefix      = "data:"
+
+	// updateScript set fields of the session and slide its expiry, only
+	// while it exist so an expired session is not recreated partially.
+	// KEYS: session - ARGV: ttl in milliseconds, field, value...
+	updateScript = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+for i = 2, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 1
+`
+
+	// refreshScript update the index entry of a session, only while it is
+	// indexed so an evicted or revoked session is not listed again.
+	// KEYS: index - ARGV: id, entry, ttl in milliseconds
+	refreshScript = `
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`
+
+	// indexScript add a session to the index of its user. The entries are
+	// `<created at>:<expire at>`, the expired ones are dropped and, past
+	// the limit, the oldest sessions are evicted and returned, or 0 is
+	// returned when they must not be evicted.
+	// KEYS: index - ARGV: now, limit, evict, id, entry, ttl in milliseconds
+	indexScript = `
+local entries = redis.call('HGETALL', KEYS[1])
+local live = {}
+for i = 1, #entries, 2 do
+	local created, expireAt = string.match(entries[i + 1], '^(%d+):(%d+)$')
+	if expireAt and tonumber(expireAt) > tonumber(ARGV[1]) then
+		table.insert(live, {id = entries[i], created = tonumber(created)})
+	else
+		redis.call('HDEL', KEYS[1], entries[i])
+	end
+end
+local evicted = {}
+local limit = tonumber(ARGV[2])
+if limit > 0 and #live >= limit then
+	if ARGV[3] ~= '1' then
+		return 0
+	end
+	table.sort(live, function(l, r) return l.created < r.created end)
+	for i = 1, #live - limit + 1 do
+		redis.call('HDEL', KEYS[1], live[i].id)
+		table.insert(evicted, live[i].id)
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[4], ARGV[5])
+redis.call('PEXPIRE', KEYS[1], ARGV[6])
+return evicted
+`
+)
+
+var (
+	ErrNotFound = errors.New("session not found")
+
+	// ErrLimitReached is returned by Create when the user has MaxPerUser
+	// sessions and EvictOldest is disabled.
+	ErrLimitReached = errors.New("concurrent session limit reached")
+)
+
+type (
+	Session struct {
+		ID         string
+		UserID     string
+		Data       map[string]string
+		CreatedAt  time.Time
+		LastSeenAt time.Time
+	}
+
+	// Option configure a Store. Sessions expire after TTL of inactivity,
+	// every Get slide the expiry, and never outlive AbsoluteTTL when set.
+	// MaxPerUser limit the concurrent sessions of a user, Create either
+	// revoke the oldest ones (EvictOldest) or fail with ErrLimitReached.
+	Option struct {
+		Prefix      string
+		TTL         time.Duration
+		AbsoluteTTL time.Duration
+		MaxPerUser  int
+		EvictOldest bool
+		Time        andretime.AndreTime
+	}
+
+	// Store keep a session in a hash `<prefix>:<id>` and index the session
+	// ids of a user in the hash `<prefix>:user:<user id>`. The scripts
+	// touch a single key so the store run on redis cluster too, the limit
+	// is enforced atomically on the index.
+	Store interface {
+		Create(userID string, data map[string]string) (*Session, error)
+		// Get return the session and refresh its sliding expiry.
+		Get(id string) (*Session, error)
+		// Set update data fields of the session.
+		Set(id string, data map[string]string) error
+		// Regenerate move the session to a new id and revoke the old one,
+		// call it on login and privilege changes to prevent session fixation.
+		Regenerate(id string) (*Session, error)
+		Revoke(id string) error
+
+		// List return the live sessions of a user, oldest first.
+		List(userID string) ([]*Session, error)
+		RevokeAll(userID string) error
+	}
+
+	store struct {
+		cache  cache.Cache
+		option Option
+	}
+)
+
+func New(c cache.Cache, option *Option) (Store, error) {
+	if c == nil {
+		return nil, errors.New("cache is required!")
+	}
+
+	o := *option
+	if o.Prefix == "" {
+		o.Prefix = DefaultPrefix
+	}
+	if o.TTL <= 0 {
+		o.TTL = DefaultTTL
+	}
+	if o.Time == nil {
+		o.Time = andretime.NewRealTime()
+	}
+
+	return &store{cache: c, option: o}, nil
+}
+
+func (s *store) Create(userID string, data map[string]string) (*Session, error) {
+	if userID == "" {
+		return nil, errors.New("user id is required!")
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.option.Time.Now()
+	session := &Session{ID: id, UserID: userID, Data: data, CreatedAt: now, LastSeenAt: now}
+
+	if err := s.save(session, s.option.MaxPerUser); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *store) Get(id string) (*Session, error) {
+	session, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	session.LastSeenAt = s.option.Time.Now()
+
+	ttl := s.ttl(session)
+	if ttl <= 0 {
+		_ = s.Revoke(id)
+		return nil, ErrNotFound
+	}
+
+	if err := s.update(id, ttl, fieldLastSeenAt, format(session.LastSeenAt)); err != nil {
+		return nil, err
+	}
+
+	// a session slid past the index expiry must stay listed
+	res, err := s.cache.Eval(refreshScript, []string{s.userKey(session.UserID)}, id, s.entry(session, ttl), s.indexTTL().Milliseconds())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to refresh sessions index of user %s", session.UserID)
+	}
+
+	// - evicted meanwhile, its hash is being removed
+	if n, _ := res.(int64); n == 0 {
+		_ = s.cache.Remove(s.key(id))
+		return nil, ErrNotFound
+	}
+
+	return session, nil
+}
+
+func (s *store) Set(id string, data map[string]string) error {
+	session, err := s.read(id)
+	if err != nil {
+		return err
+	}
+
+	ttl := s.ttl(session)
+	if ttl <= 0 {
+		return ErrNotFound
+	}
+
+	fields := make([]string, 0, 2*len(data))
+	for field, value := range data {
+		fields = append(fields, dataPrefix+field, value)
+	}
+
+	return s.update(id, ttl, fields...)
+}
+
+func (s *store) Regenerate(id string) (*Session, error) {
+	session, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	regenerated := *session
+	regenerated.ID = next
+	regenerated.LastSeenAt = s.option.Time.Now()
+
+	if err := s.save(&regenerated, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.Revoke(id); err != nil {
+		return nil, err
+	}
+
+	return &regenerated, nil
+}
+
+func (s *store) Revoke(id string) error {
+	values, err := s.cache.HGetAll(s.key(id))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get session %s", id)
+	}
+
+	if err := s.cache.Remove(s.key(id)); err != nil {
+		return errors.Wrapf(err, "failed to revoke session %s", id)
+	}
+
+	if userID := values[fieldUserID]; userID != "" {
+		if err := s.cache.HDel(s.userKey(userID), id); err != nil {
+			return errors.Wrapf(err, "failed to unindex session %s", id)
+		}
+	}
+
+	return nil
+}
+
+func (s *store) List(userID string) ([]*Session, error) {
+	ids, err := s.cache.HGetAll(s.userKey(userID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list sessions of user %s", userID)
+	}
+
+	sessions := make([]*Session, 0, len(ids))
+	expired := make([]string, 0)
+
+	for id := range ids {
+		session, err := s.read(id)
+		if err == ErrNotFound {
+			expired = append(expired, id)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if len(expired) > 0 {
+		if err := s.cache.HDel(s.userKey(userID), expired...); err != nil {
+			return nil, errors.Wrapf(err, "failed to prune sessions of user %s", userID)
+		}
+	}
+
+	sort.Slice(sessions, func(l, r int) bool {
+		return sessions[l].CreatedAt.Before(sessions[r].CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+func (s *store) RevokeAll(userID string) error {
+	ids, err := s.cache.HGetAll(s.userKey(userID))
+	if err != nil {
+		return errors.Wrapf(err, "failed to list sessions of user %s", userID)
+	}
+
+	for id := range ids {
+		if err := s.cache.Remove(s.key(id)); err != nil {
+			return errors.Wrapf(err, "failed to revoke session %s", id)
+		}
+	}
+
+	if err := s.cache.Remove(s.userKey(userID)); err != nil {
+		return errors.Wrapf(err, "failed to revoke sessions of user %s", userID)
+	}
+
+	return nil
+}
+
+// - private
+
+func (s *store) key(id string) string {
+	return s.option.Prefix + ":" + id
+}
+
+func (s *store) userKey(userID string) string {
+	return s.option.Prefix + ":user:" + userID
+}
+
+// ttl return the sliding expiry of the session, capped by AbsoluteTTL.
+func (s *store) ttl(session *Session) time.Duration {
+	if s.option.AbsoluteTTL <= 0 {
+		return s.option.TTL
+	}
+
+	remaining := session.CreatedAt.Add(s.option.AbsoluteTTL).Sub(s.option.Time.Now())
+	if remaining < s.option.TTL {
+		return remaining
+	}
+
+	return s.option.TTL
+}
+
+// indexTTL keep the user index as long as its longest possible session.
+func (s *store) indexTTL() time.Duration {
+	if s.option.AbsoluteTTL > s.option.TTL {
+		return s.option.AbsoluteTTL
+	}
+
+	return s.option.TTL
+}
+
+// entry return the index entry of session, see indexScript.
+func (s *store) entry(session *Session, ttl time.Duration) string {
+	return format(session.CreatedAt) + ":" + format(session.LastSeenAt.Add(ttl))
+}
+
+// update set fields of an existing session and slide its expiry to ttl.
+func (s *store) update(id string, ttl time.Duration, fields ...string) error {
+	args := make([]interface{}, 0, 1+len(fields))
+	args = append(args, ttl.Milliseconds())
+	for _, field := range fields {
+		args = append(args, field)
+	}
+
+	res, err := s.cache.Eval(updateScript, []string{s.key(id)}, args...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update session %s", id)
+	}
+
+	if n, _ := res.(int64); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// save write session then index it, making room for it when limit is set.
+// The hash is written first so a concurrent List does not prune the entry.
+func (s *store) save(session *Session, limit int) error {
+	fields := map[string]interface{}{
+		fieldUserID:     session.UserID,
+		fieldCreatedAt:  format(session.CreatedAt),
+		fieldLastSeenAt: format(session.LastSeenAt),
+	}
+
+	for field, value := range session.Data {
+		fields[dataPrefix+field] = value
+	}
+
+	ttl := s.ttl(session)
+	if err := s.cache.HMSetWithExpiration(s.key(session.ID), fields, ttl); err != nil {
+		return errors.Wrapf(err, "failed to save session of user %s", session.UserID)
+	}
+
+	evict := "0"
+	if s.option.EvictOldest {
+		evict = "1"
+	}
+
+	res, err := s.cache.Eval(indexScript, []string{s.userKey(session.UserID)},
+		format(s.option.Time.Now()), limit, evict, session.ID, s.entry(session, ttl), s.indexTTL().Milliseconds())
+	if err != nil {
+		_ = s.cache.Remove(s.key(session.ID))
+		return errors.Wrapf(err, "failed to index session of user %s", session.UserID)
+	}
+
+	evicted, ok := res.([]interface{})
+	if !ok {
+		_ = s.cache.Remove(s.key(session.ID))
+		return ErrLimitReached
+	}
+
+	for _, id := range evicted {
+		if err := s.cache.Remove(s.key(id.(string))); err != nil {
+			return errors.Wrapf(err, "failed to evict session %s", id)
+		}
+	}
+
+	return nil
+}
+
+// read return ErrNotFound when the session does not exist or expired.
+func (s *store) read(id string) (*Session, error) {
+	values, err := s.cache.HGetAll(s.key(id))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get session %s", id)
+	}
+
+	if len(values) == 0 {
+		return nil, ErrNotFound
+	}
+
+	session := &Session{
+		ID:         id,
+		UserID:     values[fieldUserID],
+		Data:       make(map[string]string),
+		CreatedAt:  parse(values[fieldCreatedAt]),
+		LastSeenAt: parse(values[fieldLastSeenAt]),
+	}
+
+	for field, value := range values {
+		if strings.HasPrefix(field, dataPrefix) {
+			session.Data[strings.TrimPrefix(field, dataPrefix)] = value
+		}
+	}
+
+	return session, nil
+}
+
+func format(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func parse(value string) time.Time {
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return time.Unix(0, n)
+}
+
+func newID() (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate session id")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,187 @@
+package session_test
+
+import (
+	"testing"
+	"time"
+
+	assert2 "github.com/stretchr/testify/assert"
+
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/cache/redistest"
+	"github.com/AndreeJait/GO-ANDREE-UTILITIES/session"
+)
+
+func newStore(t *testing.T, option *session.Option) (*redistest.Server, session.Store) {
+	s, c := redistest.NewClient(t)
+
+	store, err := session.New(c, option)
+	assert2.NoError(t, err)
+
+	return s, store
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func Test_Store(t *testing.T) {
+
+	t.Run("when session is idle longer than ttl", func(t *testing.T) {
+		s, store := newStore(t, &session.Option{TTL: time.Minute})
+
+		created, err := store.Create("user-1", map[string]string{"role": "admin"})
+		assert2.NoError(t, err)
+
+		s.FastForward(50 * time.Second)
+
+		got, err := store.Get(created.ID)
+		assert2.NoError(t, err)
+		assert2.Equal(t, "user-1", got.UserID)
+		assert2.Equal(t, map[string]string{"role": "admin"}, got.Data)
+
+		s.FastForward(50 * time.Second)
+
+		_, err = store.Get(created.ID)
+		assert2.NoError(t, err)
+
+		s.FastForward(time.Minute)
+
+		_, err = store.Get(created.ID)
+		assert2.Equal(t, session.ErrNotFound, err)
+	})
+
+	t.Run("when session is regenerated", func(t *testing.T) {
+		_, store := newStore(t, &session.Option{})
+
+		created, err := store.Create("user-1", map[string]string{"cart": "1"})
+		assert2.NoError(t, err)
+
+		regenerated, err := store.Regenerate(created.ID)
+		assert2.NoError(t, err)
+		assert2.NotEqual(t, created.ID, regenerated.ID)
+
+		_, err = store.Get(created.ID)
+		assert2.Equal(t, session.ErrNotFound, err)
+
+		got, err := store.Get(regenerated.ID)
+		assert2.NoError(t, err)
+		assert2.Equal(t, map[string]string{"cart": "1"}, got.Data)
+
+		sessions, err := store.List("user-1")
+		assert2.NoError(t, err)
+		assert2.Len(t, sessions, 1)
+	})
+
+	t.Run("when user reach the session limit", func(t *testing.T) {
+		_, store := newStore(t, &session.Option{MaxPerUser: 2})
+
+		for i := 0; i < 2; i++ {
+			_, err := store.Create("user-1", nil)
+			assert2.NoError(t, err)
+		}
+
+		_, err := store.Create("user-1", nil)
+		assert2.Equal(t, session.ErrLimitReached, err)
+	})
+
+	t.Run("when oldest session is evicted", func(t *testing.T) {
+		_, store := newStore(t, &session.Option{MaxPerUser: 2, EvictOldest: true})
+
+		oldest, err := store.Create("user-1", nil)
+		assert2.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err := store.Create("user-1", nil)
+			assert2.NoError(t, err)
+		}
+
+		_, err = store.Get(oldest.ID)
+		assert2.Equal(t, session.ErrNotFound, err)
+
+		sessions, err := store.List("user-1")
+		assert2.NoError(t, err)
+		assert2.Len(t, sessions, 2)
+	})
+
+	t.Run("when expired session is updated", func(t *testing.T) {
+		s, store := newStore(t, &session.Option{TTL: time.Minute})
+
+		created, err := store.Create("user-1", map[string]string{"role": "admin"})
+		assert2.NoError(t, err)
+
+		assert2.NoError(t, store.Set(created.ID, map[string]string{"cart": "2"}))
+
+		got, err := store.Get(created.ID)
+		assert2.NoError(t, err)
+		assert2.Equal(t, map[string]string{"role": "admin", "cart": "2"}, got.Data)
+
+		s.FastForward(2 * time.Minute)
+
+		err = store.Set(created.ID, map[string]string{"cart": "3"})
+		assert2.Equal(t, session.ErrNotFound, err)
+		assert2.NotContains(t, s.Keys(0), "session:"+created.ID)
+	})
+
+	t.Run("when sessions are created concurrently", func(t *testing.T) {
+		_, store := newStore(t, &session.Option{MaxPerUser: 2})
+
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			go func() {
+				_, err := store.Create("user-1", nil)
+				errs <- err
+			}()
+		}
+
+		created := 0
+		for i := 0; i < 10; i++ {
+			if err := <-errs; err == nil {
+				created++
+			} else {
+				assert2.Equal(t, session.ErrLimitReached, err)
+			}
+		}
+		assert2.Equal(t, 2, created)
+
+		sessions, err := store.List("user-1")
+		assert2.NoError(t, err)
+		assert2.Len(t, sessions, 2)
+	})
+
+	t.Run("when expired sessions are below the limit", func(t *testing.T) {
+		now := &clock{now: time.Now()}
+		_, store := newStore(t, &session.Option{TTL: time.Minute, MaxPerUser: 1, Time: now})
+
+		_, err := store.Create("user-1", nil)
+		assert2.NoError(t, err)
+
+		_, err = store.Create("user-1", nil)
+		assert2.Equal(t, session.ErrLimitReached, err)
+
+		now.now = now.now.Add(2 * time.Minute)
+
+		_, err = store.Create("user-1", nil)
+		assert2.NoError(t, err)
+	})
+
+	t.Run("when every session of a user is revoked", func(t *testing.T) {
+		_, store := newStore(t, &session.Option{})
+
+		first, err := store.Create("user-1", nil)
+		assert2.NoError(t, err)
+
+		other, err := store.Create("user-2", nil)
+		assert2.NoError(t, err)
+
+		assert2.NoError(t, store.RevokeAll("user-1"))
+
+		_, err = store.Get(first.ID)
+		assert2.Equal(t, session.ErrNotFound, err)
+
+		_, err = store.Get(other.ID)
+		assert2.NoError(t, err)
+	})
+}
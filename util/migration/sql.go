@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"fmt"
 	"sort"
 
@@ -21,6 +22,16 @@ func NewSqlMigration(orm persistent.ORM, migrations map[int64]*Script, logger lo
 	return &sql{orm: orm, migrations: migrations, logger: logger}, nil
 }
 
+// NewSqlMigrationWithContext create a migration tool running every statement
+// with ctx, cancelling ctx abort the running script.
+func NewSqlMigrationWithContext(ctx context.Context, orm persistent.ORM, migrations map[int64]*Script, logger logs.Logger) (Tool, error) {
+	if orm == nil {
+		return nil, errors.New("orm is required!")
+	}
+
+	return NewSqlMigration(orm.WithContext(ctx), migrations, logger)
+}
+
 func (s *sql) Up() error {
 	if err := isMigrationTableExists(s); err != nil {
 		return err
@@ -80,15 +91,8 @@ func (s *sql) Up() error {
 		s.logger.Infof("%s executing migration version %d", UpTag, version)
 
 		if script.UsingTransaction {
-			err := s.WithTransaction(func(tx persistent.ORM) error {
-				rows, err := tx.RawSql(script.Up)
-
-				if err != nil {
-					return errors.WithStack(err)
-				}
-
-				if err := rows.Close(); err != nil {
-					s.logger.Errorf("failed to close rows", err)
+			err := persistent.WithTransaction(s.orm, func(tx persistent.ORM) error {
+				if err := tx.Exec(script.Up); err != nil {
 					return errors.WithStack(err)
 				}
 
@@ -108,22 +112,16 @@ func (s *sql) Up() error {
 			continue
 		}
 
-		// - raw sql to exec multiple statements
+		// - exec the script, it may hold multiple statements
 		var (
 			tx = s.orm
 		)
 
-		rows, err := tx.RawSql(script.Up)
-
-		if err != nil {
+		if err := tx.Exec(script.Up); err != nil {
 			s.logger.Errorf("%s failed to execute migration script %d: %s", UpTag, version, err)
 			return errors.WithStack(err)
 		}
 
-		if err := rows.Close(); err != nil {
-			s.logger.Errorf("failed to close rows", err)
-		}
-
 		if err := tx.Exec("INSERT INTO "+TableName+" VALUES(?)", version); err != nil {
 			s.logger.Errorf("%s failed to execute migration script %d: %s", UpTag, version, err)
 			return errors.WithStack(err)
@@ -175,19 +173,12 @@ func (s *sql) Down() error {
 	s.logger.Infof("%s begin down migration %d version", DownTag, version)
 
 	if script.UsingTransaction {
-		err := s.WithTransaction(func(tx persistent.ORM) error {
-			rows, err := tx.RawSql(script.Down)
-
-			if err != nil {
+		err := persistent.WithTransaction(s.orm, func(tx persistent.ORM) error {
+			if err := tx.Exec(script.Down); err != nil {
 				s.logger.Errorf("%s failed to execute migration down script with version %d: %s", DownTag, version, err)
 				return errors.WithStack(err)
 			}
 
-			if err := rows.Close(); err != nil {
-				s.logger.Errorf("failed to close rows", err)
-				return errors.WithStack(err)
-			}
-
 			if err := tx.Exec("DELETE FROM migrations WHERE version >= ?", version); err != nil {
 				s.logger.Errorf("%s failed to execute delete migration script %d: %s", DownTag, version, err)
 				return errors.WithStack(err)
@@ -206,19 +197,11 @@ func (s *sql) Down() error {
 	}
 
 	// - execute migrations script
-	rows, err := s.orm.RawSql(script.Down)
-
-	if err != nil {
+	if err := s.orm.Exec(script.Down); err != nil {
 		s.logger.Errorf("%s failed to execute migration down script with version %d", DownTag, version)
 		return errors.WithStack(err)
 	}
 
-	defer func() {
-		if err := rows.Close(); err != nil {
-			s.logger.Errorf("failed to close rows", err)
-		}
-	}()
-
 	// - remove version greater than before from migrations table
 	if err := s.orm.Exec("DELETE FROM migrations WHERE version >= ?", version); err != nil {
 		s.logger.Errorf("%s failed to execute delete migration script %+v", DownTag, version)
@@ -253,7 +236,7 @@ func (s *sql) Truncate() error {
 		return err
 	}
 
-	if err := s.orm.Exec("TRUNCATE TABLE " + TableName); err != nil {
+	if err := s.orm.Exec("DELETE FROM " + TableName); err != nil {
 		return errors.New(fmt.Sprintf("failed to truncate %s", TableName))
 	}
 
@@ -266,7 +249,7 @@ func (s *sql) Initialize() error {
 		return nil
 	}
 
-	err := s.WithTransaction(func(tx persistent.ORM) error {
+	err := persistent.WithTransaction(s.orm, func(tx persistent.ORM) error {
 		query := `CREATE TABLE migrations(version bigint not null)`
 
 		if err := tx.Exec(query); err != nil {
@@ -412,22 +395,3 @@ func getLatestMigrationVersionFromDatabase(s *sql) (int64, error) {
 
 	return version, nil
 }
-
-func (s *sql) WithTransaction(block func(tx persistent.ORM) error) error {
-	var (
-		txn = s.orm.Begin()
-	)
-
-	if err := block(txn); err != nil {
-		if err := txn.Rollback(); err != nil {
-			return errors.Wrap(err, "failed to rollback")
-		}
-		return errors.WithStack(err)
-	}
-
-	if err := txn.Commit(); err != nil {
-		return errors.Wrap(err, "failed to commit")
-	}
-
-	return nil
-}